@@ -0,0 +1,116 @@
+// Package api is the models of the goemd HTTP API shared by the handlers
+// and the client.
+package api
+
+// Query parameters of the API.
+const (
+	// QuerySubDir is the sub directory listed, "new" or "cur".
+	QuerySubDir = "sub_dir"
+	// QueryOffset is the number of the mails skipped.
+	QueryOffset = "offset"
+	// QueryLimit is the maximum number of the mails returned.
+	QueryLimit = "limit"
+	// QueryPrefer is the body preferred among the alternatives, "html" or "plain".
+	QueryPrefer = "prefer"
+	// QueryRemote allows the remote resources in the HTML body if it is "true".
+	QueryRemote = "remote"
+)
+
+// HeaderTotalCount is the response header with the number of all the mails
+// listed regardless of the offset and the limit.
+const HeaderTotalCount = "X-Total-Count"
+
+// Error codes in ErrorResponse.
+const (
+	CodeNotFound         = "not_found"
+	CodeNotMaildir       = "not_maildir"
+	CodeInvalidKey       = "invalid_key"
+	CodeUnknownSubDir    = "unknown_sub_dir"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeUnauthorized     = "unauthorized"
+	CodePermissionDenied = "permission_denied"
+	CodeInternal         = "internal"
+)
+
+// ErrorCodes is all the error codes.
+var ErrorCodes = []string{
+	CodeNotFound,
+	CodeNotMaildir,
+	CodeInvalidKey,
+	CodeUnknownSubDir,
+	CodeInvalidParameter,
+	CodeUnauthorized,
+	CodePermissionDenied,
+	CodeInternal,
+}
+
+// ErrorResponse is the body of the error responses.
+type ErrorResponse struct {
+	// Error is the message for the humans.
+	Error string `json:"Error"`
+	// Code is one of ErrorCodes for the programs, which is kept stable.
+	Code string `json:"code"`
+}
+
+// Quota is the quota usage of a maildir.
+type Quota struct {
+	BytesLimit    int64 `json:"bytes_limit"`
+	MessagesLimit int64 `json:"messages_limit"`
+	Bytes         int64 `json:"bytes"`
+	Messages      int64 `json:"messages"`
+	Exceeded      bool  `json:"exceeded"`
+}
+
+// Maildir is the summary of a maildir.
+type Maildir struct {
+	Name    string `json:"name"`
+	Total   int    `json:"total"`
+	Unread  int    `json:"unread"`
+	Flagged int    `json:"flagged"`
+	Size    int64  `json:"size"`
+	// Quota is null if the maildir has no quota.
+	Quota *Quota `json:"quota"`
+}
+
+// Address is the mail address.
+type Address struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// Envelope is the header fields parsed for the clients.
+type Envelope struct {
+	From    []Address `json:"from"`
+	To      []Address `json:"to"`
+	Cc      []Address `json:"cc"`
+	Bcc     []Address `json:"bcc"`
+	ReplyTo []Address `json:"reply_to"`
+	// Date is in RFC 3339, null if the Date field is missing or malformed.
+	Date       *string  `json:"date"`
+	MessageID  string   `json:"message_id"`
+	References []string `json:"references"`
+}
+
+// MailSummary is the mail in the list of a maildir.
+type MailSummary struct {
+	Key     string `json:"key"`
+	Subject string `json:"subject"`
+	Size    int64  `json:"size"`
+	// Headers are the header fields with the encoded-words decoded.
+	Headers map[string][]string `json:"headers"`
+	Envelope
+}
+
+// Mail is the mail with the body.
+type Mail struct {
+	Key     string `json:"key"`
+	Subject string `json:"subject"`
+	// Body is the body as it is in the message.
+	Body string `json:"body"`
+	// Text is the body as the plain text.
+	Text string `json:"text"`
+	// HTML is the sanitized HTML body, empty unless it is preferred.
+	HTML    string              `json:"html,omitempty"`
+	Headers map[string][]string `json:"headers"`
+	Envelope
+}
@@ -8,6 +8,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/tennashi/goem"
 	"github.com/tennashi/goem/server"
@@ -15,20 +18,64 @@ import (
 )
 
 func Run(ctx context.Context, args []string, outStream, errStream io.Writer) int {
-	cfgFlag := flag.String("c", "", "config path")
-	flag.Parse()
-	config := goem.NewConfig(*cfgFlag)
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(errStream)
+	cfgFlag := flags.String("c", "", "config path")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
 
 	log.SetPrefix("[goemd] ")
 	log.SetOutput(errStream)
 
+	switch sub := flags.Args(); {
+	case len(sub) == 2 && sub[0] == "config" && sub[1] == "check":
+		return checkConfig(*cfgFlag, outStream, errStream)
+	case len(sub) > 0:
+		fmt.Fprintf(errStream, "unknown command: %v\n", strings.Join(sub, " "))
+		return 2
+	}
+
+	config, err := goem.LoadConfig(*cfgFlag)
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			log.Println(line)
+		}
+		return 1
+	}
+	account, err := config.Account(config.Server.Account)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	s, err := server.New(config, account)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	stopFilter, err := startFilter(ctx, config, account)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
 	var eg *errgroup.Group
 	eg, ctx = errgroup.WithContext(ctx)
+	reload := make(chan struct{}, 1)
 	eg.Go(func() error {
-		return server.Run(ctx, config)
+		return s.Run(ctx)
 	})
 	eg.Go(func() error {
-		return Signal(ctx)
+		return Signal(ctx, reload)
+	})
+	if config.Path != "" {
+		eg.Go(func() error {
+			return WatchConfig(ctx, config.Path, watchInterval, reload)
+		})
+	}
+	eg.Go(func() error {
+		return Reload(ctx, s, config, stopFilter, reload)
 	})
 	eg.Go(func() error {
 		<-ctx.Done()
@@ -42,16 +89,183 @@ func Run(ctx context.Context, args []string, outStream, errStream io.Writer) int
 	return 0
 }
 
-func Signal(ctx context.Context) error {
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt)
+// watchInterval is the interval WatchConfig checks the configuration file.
+const watchInterval = 2 * time.Second
+
+// Signal returns on SIGINT or SIGTERM, and requests reloading on SIGHUP.
+func Signal(ctx context.Context, reload chan<- struct{}) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("signal closing")
+			return nil
+		case sig := <-c:
+			if sig != syscall.SIGHUP {
+				return fmt.Errorf("signal received: %v", sig.String())
+			}
+			log.Printf("signal received: %v", sig.String())
+			request(reload)
+		}
+	}
+}
+
+// WatchConfig requests reloading when the modification time of the
+// configuration file changes.
+func WatchConfig(ctx context.Context, path string, interval time.Duration, reload chan<- struct{}) error {
+	last := modTime(path)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		if m := modTime(path); !m.Equal(last) {
+			log.Printf("config %v changed", path)
+			last = m
+			request(reload)
+		}
+	}
+}
 
+// modTime returns the modification time of the file, or the zero time if it
+// doesn't exist while the editor replaces it.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// request requests reloading unless a request is pending.
+func request(reload chan<- struct{}) {
 	select {
-	case <-ctx.Done():
-		log.Println("signal closing")
-		signal.Reset()
-		return nil
-	case sig := <-c:
-		return fmt.Errorf("signal received: %v", sig.String())
+	case reload <- struct{}{}:
+	default:
+	}
+}
+
+// Reload reloads the configuration on the requests and swaps it into the
+// server and the filter. The configuration with the errors is not used.
+func Reload(ctx context.Context, s *server.Server, config *goem.Config, stopFilter func(), reload <-chan struct{}) error {
+	defer func() { stopFilter() }()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-reload:
+		}
+
+		newConfig, err := goem.LoadConfig(config.Path)
+		if err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				log.Printf("reload: %v", line)
+			}
+			continue
+		}
+		account, err := newConfig.Account(newConfig.Server.Account)
+		if err != nil {
+			log.Printf("reload: %v", err)
+			continue
+		}
+		// the script is loaded before swapping to keep the old one if broken.
+		f, err := loadFilter(newConfig, account)
+		if err != nil {
+			log.Printf("reload: %v", err)
+			continue
+		}
+		if err := s.Reload(newConfig, account); err != nil {
+			log.Printf("reload: %v", err)
+			continue
+		}
+		stopFilter()
+		stopFilter = watchFilter(ctx, f, newConfig)
+
+		diff := newConfig.Diff(config)
+		if len(diff) == 0 {
+			log.Println("config reloaded: no change")
+		}
+		for _, d := range diff {
+			log.Printf("config reloaded: %v", d)
+		}
+		config = newConfig
+	}
+}
+
+// startFilter runs the sieve filter on the configured maildirs of the
+// account, and returns the function stopping it.
+func startFilter(ctx context.Context, config *goem.Config, account *goem.Account) (func(), error) {
+	f, err := loadFilter(config, account)
+	if err != nil {
+		return nil, err
 	}
+	return watchFilter(ctx, f, config), nil
+}
+
+// loadFilter returns the filter of the configuration, nil if no script is
+// configured.
+func loadFilter(config *goem.Config, account *goem.Account) (*goem.Filter, error) {
+	if config.Filter.Script == "" {
+		return nil, nil
+	}
+	f, err := goem.LoadFilter(goem.NewMaildirRoot(account.RootDir), config.Filter.Script)
+	if err != nil {
+		return nil, err
+	}
+	config.ConfigureFilter(f, account)
+	return f, nil
+}
+
+// watchFilter runs the filter until the returned function is called.
+func watchFilter(ctx context.Context, f *goem.Filter, config *goem.Config) func() {
+	if f == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		log.Printf("filter watching %v", config.Filter.Folders)
+		f.Watch(ctx, config.Filter.Folders, time.Duration(config.Filter.Interval)*time.Second)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// checkConfig loads the configuration and prints the errors in it, or the
+// summary of the values goemd runs with.
+func checkConfig(path string, outStream, errStream io.Writer) int {
+	config, err := goem.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintln(errStream, err)
+		return 1
+	}
+	// goemd can't run without the account served.
+	account, err := config.Account(config.Server.Account)
+	if err != nil {
+		fmt.Fprintln(errStream, err)
+		return 1
+	}
+	if config.Path == "" {
+		fmt.Fprintln(outStream, "no config file: ok")
+	} else {
+		fmt.Fprintf(outStream, "%v: ok\n", config.Path)
+	}
+	fmt.Fprintf(outStream, "port: %v\n", config.Server.Port)
+	if config.Server.CertFile != "" {
+		fmt.Fprintf(outStream, "tls: %v\n", config.Server.CertFile)
+	}
+	fmt.Fprintf(outStream, "account: %v (%v)\n", account.Name, account.RootDir)
+	if config.Filter.Script != "" {
+		fmt.Fprintf(outStream, "filter: %v every %vs\n", config.Filter.Script, config.Filter.Interval)
+	}
+	return 0
 }
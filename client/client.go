@@ -0,0 +1,178 @@
+// Package client is the client of the goemd HTTP API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tennashi/goem/api"
+)
+
+// Client is the client of goemd.
+type Client struct {
+	endpoint string
+	token    string
+	// HTTPClient is the client sending the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// New returns the client of goemd at the endpoint such as
+// "https://mail.example.com:8080". The token is sent as the bearer token
+// unless it is empty.
+func New(endpoint, token string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint: %v", endpoint)
+	}
+	return &Client{endpoint: strings.TrimSuffix(endpoint, "/"), token: token}, nil
+}
+
+// Maildirs returns the maildirs under the root.
+func (c *Client) Maildirs(ctx context.Context) ([]api.Maildir, error) {
+	var ret []api.Maildir
+	if _, err := c.getJSON(ctx, "/maildir/", nil, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Mails returns all the mails in the sub directory ("new" or "cur") of the
+// maildir by a request. Use ListMails for the large maildirs.
+func (c *Client) Mails(ctx context.Context, dir, subDir string) ([]api.MailSummary, error) {
+	ms, _, err := c.MailsPage(ctx, dir, subDir, 0, 0)
+	return ms, err
+}
+
+// MailsPage returns the mails in the sub directory of the maildir skipping
+// offset mails, at most limit mails if limit is positive, and the number of
+// all the mails. The number is -1 if the server does not report it.
+func (c *Client) MailsPage(ctx context.Context, dir, subDir string, offset, limit int) ([]api.MailSummary, int, error) {
+	q := url.Values{}
+	if subDir != "" {
+		q.Set(api.QuerySubDir, subDir)
+	}
+	if offset > 0 {
+		q.Set(api.QueryOffset, strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		q.Set(api.QueryLimit, strconv.Itoa(limit))
+	}
+	var ret []api.MailSummary
+	h, err := c.getJSON(ctx, "/maildir/"+segment(dir), q, &ret)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := strconv.Atoi(h.Get(api.HeaderTotalCount))
+	if err != nil {
+		total = -1
+	}
+	return ret, total, nil
+}
+
+// MailOptions is the options of Mail.
+type MailOptions struct {
+	// PreferHTML returns the sanitized HTML body if the mail has it.
+	PreferHTML bool
+	// AllowRemote keeps the remote resources in the HTML body.
+	AllowRemote bool
+}
+
+// Mail returns the mail of the key in the maildir.
+func (c *Client) Mail(ctx context.Context, dir, key string, opt MailOptions) (*api.Mail, error) {
+	q := url.Values{}
+	if opt.PreferHTML {
+		q.Set(api.QueryPrefer, "html")
+	}
+	if opt.AllowRemote {
+		q.Set(api.QueryRemote, "true")
+	}
+	ret := &api.Mail{}
+	if _, err := c.getJSON(ctx, mailPath(dir, key), q, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Part returns the decoded content of the MIME part and its media type.
+// The caller must close it.
+func (c *Client) Part(ctx context.Context, dir, key, partID string) (io.ReadCloser, string, error) {
+	res, err := c.get(ctx, mailPath(dir, key)+"/parts/"+segment(partID), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return res.Body, res.Header.Get("Content-Type"), nil
+}
+
+// Raw returns the mail of the key as it is on the disk.
+// The caller must close it.
+func (c *Client) Raw(ctx context.Context, dir, key string) (io.ReadCloser, error) {
+	res, err := c.get(ctx, mailPath(dir, key)+"/raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func mailPath(dir, key string) string {
+	return "/maildir/" + segment(dir) + "/" + segment(key)
+}
+
+// segment escapes the path segment in the default way of net/url, keeping
+// "," and ":" in the keys, because the router matches the escaped path if
+// it is escaped in the other way.
+func segment(s string) string {
+	return (&url.URL{Path: s}).EscapedPath()
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, q url.Values, v interface{}) (http.Header, error) {
+	res, err := c.get(ctx, path, q)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, err
+	}
+	return res.Header, nil
+}
+
+// get sends the GET request and returns the response of the status 2xx.
+// The other responses are returned as *Error.
+func (c *Client) get(ctx context.Context, path string, q url.Values) (*http.Response, error) {
+	u := c.endpoint + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 == 2 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	return nil, decodeError(res)
+}
@@ -0,0 +1,227 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
+	"github.com/tennashi/goem/client"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/server"
+)
+
+const testMail = "From: Alice <alice@example.com>\r\nSubject: hello\r\nDate: Tue, 01 Oct 2019 12:00:00 +0000\r\n\r\nbody\r\n"
+
+func setup(t *testing.T, token string, n int) (*httptest.Server, maildir.Key, func()) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "goem-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := maildir.Create(filepath.Join(root, "INBOX"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := md.Deliver(strings.NewReader(testMail), maildir.DeliverOption{SubDir: maildir.SubDirCur, Flags: []string{"S"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < n; i++ {
+		if _, err := md.Deliver(strings.NewReader(testMail), maildir.DeliverOption{SubDir: maildir.SubDirCur}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := httptest.NewServer(server.NewRouter(goem.NewMaildirRoot(root), token))
+	return ts, k, func() {
+		ts.Close()
+		os.RemoveAll(root)
+	}
+}
+
+func Test_Client(t *testing.T) {
+	ts, k, cleanup := setup(t, "secret", 1)
+	defer cleanup()
+	c, err := client.New(ts.URL+"/", "secret")
+	if err != nil {
+		t.Fatalf("should not be error for %v but %v", ts.URL, err)
+	}
+	ctx := context.Background()
+
+	mds, err := c.Maildirs(ctx)
+	if err != nil {
+		t.Fatalf("should not be error for Maildirs but %v", err)
+	}
+	if len(mds) != 1 || mds[0].Name != "INBOX" || mds[0].Total != 1 {
+		t.Fatalf("\n\tgot: %+v\n\twant: INBOX with a mail", mds)
+	}
+
+	ms, err := c.Mails(ctx, "INBOX", "cur")
+	if err != nil {
+		t.Fatalf("should not be error for Mails but %v", err)
+	}
+	if len(ms) != 1 || ms[0].Key != k.String() || ms[0].Size != int64(len(testMail)) || ms[0].From[0].Name != "Alice" {
+		t.Fatalf("\n\tgot: %+v\n\twant: %v", ms, k)
+	}
+
+	m, err := c.Mail(ctx, "INBOX", k.String(), client.MailOptions{})
+	if err != nil {
+		t.Fatalf("should not be error for Mail but %v", err)
+	}
+	if m.Subject != "hello" || m.Text != "body\r\n" {
+		t.Fatalf("\n\tgot: %+v\n\twant: hello", m)
+	}
+
+	r, err := c.Raw(ctx, "INBOX", k.String())
+	if err != nil {
+		t.Fatalf("should not be error for Raw but %v", err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testMail {
+		t.Fatalf("\n\tgot: %q\n\twant: %q", b, testMail)
+	}
+}
+
+func Test_Client_error(t *testing.T) {
+	ts, _, cleanup := setup(t, "secret", 1)
+	defer cleanup()
+
+	cases := map[string]struct {
+		token      string
+		dir        string
+		subDir     string
+		wantStatus int
+		wantCode   string
+	}{
+		"(invalid)no token": {
+			dir:        "INBOX",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   api.CodeUnauthorized,
+		},
+		"(invalid)wrong token": {
+			token:      "wrong",
+			dir:        "INBOX",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   api.CodeUnauthorized,
+		},
+		"(invalid)no maildir": {
+			token:      "secret",
+			dir:        "nowhere",
+			wantStatus: http.StatusNotFound,
+			wantCode:   api.CodeNotFound,
+		},
+		"(invalid)sub dir": {
+			token:      "secret",
+			dir:        "INBOX",
+			subDir:     "tmp",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   api.CodeUnknownSubDir,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, err := client.New(ts.URL, tt.token)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", ts.URL, err)
+			}
+			_, err = c.Mails(context.Background(), tt.dir, tt.subDir)
+			e, ok := err.(*client.Error)
+			if !ok {
+				t.Fatalf("should be *client.Error but %v", err)
+			}
+			if e.StatusCode != tt.wantStatus || e.Code != tt.wantCode || e.Message == "" {
+				t.Fatalf("\n\tgot: %+v\n\twant: %v %v", e, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func Test_Client_ListMails(t *testing.T) {
+	ts, _, cleanup := setup(t, "", 5)
+	defer cleanup()
+	c, err := client.New(ts.URL, "")
+	if err != nil {
+		t.Fatalf("should not be error for %v but %v", ts.URL, err)
+	}
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		pageSize int
+	}{
+		"(valid)default page size": {},
+		"(valid)page size 1":       {pageSize: 1},
+		"(valid)page size 2":       {pageSize: 2},
+		"(valid)page size 5":       {pageSize: 5},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			it := c.ListMails("INBOX", client.ListOptions{PageSize: tt.pageSize})
+			keys := map[string]bool{}
+			for it.Next(ctx) {
+				keys[it.Mail().Key] = true
+			}
+			if err := it.Err(); err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.pageSize, err)
+			}
+			if len(keys) != 5 || it.Total() != 5 {
+				t.Fatalf("\n\tgot: %v, %v\n\twant: 5, 5", len(keys), it.Total())
+			}
+		})
+	}
+}
+
+func Test_Client_errorBody(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		"(valid)json": {
+			contentType: "application/json",
+			body:        `{"Error":"not found"}`,
+			want:        "goemd: not found",
+		},
+		"(valid)text": {
+			contentType: "text/plain; charset=utf-8",
+			body:        "bad gateway\n",
+			want:        "goemd: bad gateway",
+		},
+		"(valid)empty": {
+			want: "goemd: 502 Bad Gateway",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(http.StatusBadGateway)
+				io.WriteString(w, tt.body)
+			}))
+			defer ts.Close()
+			c, err := client.New(ts.URL, "")
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", ts.URL, err)
+			}
+			_, err = c.Maildirs(context.Background())
+			if client.StatusCode(err) != http.StatusBadGateway || err.Error() != tt.want {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/tennashi/goem/api"
+)
+
+// maxErrorBody is the size of the error response body read.
+const maxErrorBody = 64 << 10
+
+// Error is the error response of goemd.
+type Error struct {
+	StatusCode int
+	// Message is the message in the {"Error": ...} body, or the body itself
+	// if it is not JSON, for example from a proxy.
+	Message string
+	// Code is one of api.ErrorCodes, or "" if the body is not JSON.
+	Code string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("goemd: %v %v", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("goemd: %v", e.Message)
+}
+
+func decodeError(res *http.Response) error {
+	e := &Error{StatusCode: res.StatusCode}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	if err != nil {
+		return e
+	}
+	mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	var body api.ErrorResponse
+	if mt == "application/json" && json.Unmarshal(b, &body) == nil {
+		e.Message, e.Code = body.Error, body.Code
+		return e
+	}
+	e.Message = strings.TrimSpace(string(b))
+	return e
+}
+
+// StatusCode returns the status code of the error response, or 0 if err is
+// not an error response of goemd.
+func StatusCode(err error) int {
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// Code returns the code of the error response, or "" if err is not an
+// error response of goemd.
+func Code(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return ""
+}
+
+// IsNotFound reports whether err is the response of 404 Not Found.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether err is the response of 401 Unauthorized,
+// which is returned for the missing or wrong token.
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
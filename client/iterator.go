@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+
+	"github.com/tennashi/goem/api"
+)
+
+// DefaultPageSize is the number of the mails requested at once by MailIterator.
+const DefaultPageSize = 100
+
+// ListOptions is the options of ListMails.
+type ListOptions struct {
+	// SubDir is "new" or "cur", "cur" if empty.
+	SubDir string
+	// PageSize is the number of the mails requested at once,
+	// DefaultPageSize if zero.
+	PageSize int
+}
+
+// MailIterator iterates the mails in a maildir requesting them page by page.
+//
+//	it := c.ListMails("INBOX", client.ListOptions{})
+//	for it.Next(ctx) {
+//		m := it.Mail()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type MailIterator struct {
+	c      *Client
+	dir    string
+	opt    ListOptions
+	page   []api.MailSummary
+	i      int
+	offset int
+	total  int
+	done   bool
+	err    error
+}
+
+// ListMails returns the iterator of the mails in the maildir.
+func (c *Client) ListMails(dir string, opt ListOptions) *MailIterator {
+	if opt.PageSize <= 0 {
+		opt.PageSize = DefaultPageSize
+	}
+	return &MailIterator{c: c, dir: dir, opt: opt, i: -1, total: -1}
+}
+
+// Next advances to the next mail, requesting the next page if needed.
+// It returns false at the end or on the error.
+func (it *MailIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.i+1 < len(it.page) {
+		it.i++
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	page, total, err := it.c.MailsPage(ctx, it.dir, it.opt.SubDir, it.offset, it.opt.PageSize)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page, it.i, it.total = page, 0, total
+	it.offset += len(page)
+	// the servers not paginating return all the mails at once.
+	if len(page) < it.opt.PageSize || total >= 0 && it.offset >= total {
+		it.done = true
+	}
+	return len(page) > 0
+}
+
+// Mail returns the current mail.
+func (it *MailIterator) Mail() api.MailSummary {
+	return it.page[it.i]
+}
+
+// Total returns the number of all the mails reported by the last response,
+// or -1 before the first page or if the server does not report it.
+func (it *MailIterator) Total() int {
+	return it.total
+}
+
+// Err returns the error stopping the iteration.
+func (it *MailIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,29 @@
+package goem
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+)
+
+func handleAccounts(c *cli.Context) error {
+	cfg := loadedConfig(c)
+	def, err := cfg.Account("")
+	if err != nil {
+		return &exitError{code: exitConfig, err: err}
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "DEFAULT\tNAME\tROOT\tADDRESSES")
+	for i := range cfg.Accounts {
+		a := &cfg.Accounts[i]
+		mark := ""
+		if a == def {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", mark, a.Name, a.RootDir, strings.Join(a.Addresses(), ","))
+	}
+	return w.Flush()
+}
@@ -1,22 +1,243 @@
 package goem
 
-import "github.com/urfave/cli"
+import (
+	"github.com/tennashi/goem/maildir"
+	"github.com/urfave/cli"
+)
 
 var commands = []cli.Command{
 	list,
 	show,
+	folders,
+	accounts,
+	importMbox,
+	exportMbox,
+	filter,
+	deliver,
+	syncIMAP,
+	fetch,
+	mdsyncCmd,
+	fsck,
+	quota,
+	tuiCmd,
 }
 
 var list = cli.Command{
-	Name:    "list",
-	Aliases: []string{"l"},
-	Usage:   "List mails",
-	Action:  handleList,
+	Name:      "list",
+	Aliases:   []string{"l"},
+	Usage:     "List mails in FOLDER, the Maildir set by --maildir or INBOX",
+	ArgsUsage: "[FOLDER]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "subdir",
+			Value: "all",
+			Usage: "List mails in `SUBDIR` (new, cur or all)",
+		},
+		cli.BoolFlag{
+			Name:  "unread",
+			Usage: "List only unread mails",
+		},
+		cli.BoolFlag{
+			Name:  "flagged",
+			Usage: "List only flagged mails",
+		},
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "List mails dated after `TIME` (2006-01-02, RFC 3339 or duration such as 24h)",
+		},
+		cli.IntFlag{
+			Name:  "offset",
+			Usage: "Skip the first `N` mails",
+		},
+		cli.IntFlag{
+			Name:  "limit",
+			Usage: "List at most `N` mails",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "Print each mail with Go `TEMPLATE` such as '{{.Key}} {{.Subject}}'",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print mails as a JSON array",
+		},
+		cli.BoolFlag{
+			Name:  "jsonl",
+			Usage: "Print mails as JSON lines",
+		},
+	},
+	Action: handleList,
 }
 
 var show = cli.Command{
-	Name:    "show",
-	Aliases: []string{"s"},
-	Usage:   "Show mail",
-	Action:  handleShow,
+	Name:      "show",
+	Aliases:   []string{"s"},
+	Usage:     "Show mail of KEY in FOLDER, the Maildir set by --maildir or INBOX",
+	ArgsUsage: "KEY",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "folder, f",
+			Usage: "Show mail in `FOLDER`",
+		},
+		cli.BoolFlag{
+			Name:  "raw",
+			Usage: "Show the message as it is on the disk",
+		},
+	},
+	Action: handleShow,
+}
+
+var folders = cli.Command{
+	Name:    "folders",
+	Aliases: []string{"f"},
+	Usage:   "List Maildirs with message counts",
+	Action:  handleFolders,
+}
+
+var accounts = cli.Command{
+	Name:   "accounts",
+	Usage:  "List the accounts with their roots and addresses",
+	Action: handleAccounts,
+}
+
+var mboxFormatFlag = cli.StringFlag{
+	Name:  "format",
+	Value: "mboxrd",
+	Usage: "mbox `FORMAT` (mboxo, mboxrd or mboxcl2)",
+}
+
+var importMbox = cli.Command{
+	Name:      "import",
+	Usage:     "Import mails from mbox FILE, MH folder or directory of .eml files into FOLDER",
+	ArgsUsage: "FILE FOLDER",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Value: "mboxrd",
+			Usage: "source `FORMAT` (mboxo, mboxrd, mboxcl2, mh or eml)",
+		},
+	},
+	Action: handleImport,
+}
+
+var exportMbox = cli.Command{
+	Name:      "export",
+	Usage:     "Export mails in FOLDER into mbox FILE",
+	ArgsUsage: "FOLDER [FILE]",
+	Flags:     []cli.Flag{mboxFormatFlag},
+	Action:    handleExport,
+}
+
+var filter = cli.Command{
+	Name:      "filter",
+	Usage:     "Filter mails in new of FOLDERs with the sieve script",
+	ArgsUsage: "FOLDER...",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "script, s",
+			Usage: "Load sieve script from `FILE`",
+		},
+		cli.BoolFlag{
+			Name:  "watch, w",
+			Usage: "Keep filtering mails arriving in new",
+		},
+		cli.IntFlag{
+			Name:  "interval",
+			Value: 30,
+			Usage: "Polling interval in `SECONDS` with --watch",
+		},
+	},
+	Action: handleFilter,
+}
+
+var deliver = cli.Command{
+	Name:  "deliver",
+	Usage: "Deliver a mail read from stdin into FOLDER",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "folder, f",
+			Value: "INBOX",
+			Usage: "Deliver into `FOLDER`, the inbox of the account by default",
+		},
+		cli.StringFlag{
+			Name:  "sender",
+			Usage: "Envelope sender `ADDRESS` for Return-Path",
+		},
+		cli.StringFlag{
+			Name:  "recipient",
+			Usage: "Envelope recipient `ADDRESS` for Delivered-To",
+		},
+		cli.StringFlag{
+			Name:  "script, s",
+			Usage: "Filter with sieve script `FILE`",
+		},
+		cli.BoolFlag{
+			Name:  "no-filter",
+			Usage: "Do not filter with the configured sieve script",
+		},
+		cli.StringFlag{
+			Name:  "quota",
+			Value: "enforce",
+			Usage: "`POLICY` for the delivery exceeding the quota (enforce or warn)",
+		},
+	},
+	Action: handleDeliver,
+}
+
+var syncIMAP = cli.Command{
+	Name:      "sync",
+	Usage:     "Synchronize IMAP ACCOUNTs into the Maildirs",
+	ArgsUsage: "[ACCOUNT...]",
+	Action:    handleSync,
+}
+
+var fetch = cli.Command{
+	Name:      "fetch",
+	Usage:     "Fetch new mails of POP3 ACCOUNTs into the Maildirs",
+	ArgsUsage: "[ACCOUNT...]",
+	Action:    handleFetch,
+}
+
+var mdsyncCmd = cli.Command{
+	Name:      "mdsync",
+	Usage:     "Synchronize the Maildirs under the root with those under DIR",
+	ArgsUsage: "DIR",
+	Action:    handleMdsync,
+}
+
+var fsck = cli.Command{
+	Name:      "fsck",
+	Usage:     "Check FOLDERs, or all Maildirs, for broken files",
+	ArgsUsage: "[FOLDER...]",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "repair",
+			Usage: "Repair the problems instead of only reporting them",
+		},
+		cli.DurationFlag{
+			Name:  "stale-age",
+			Value: maildir.DefaultStaleAge,
+			Usage: "Remove files in tmp older than `DURATION`",
+		},
+	},
+	Action: handleFsck,
+}
+
+var quota = cli.Command{
+	Name:      "quota",
+	Usage:     "Show the quota usage of FOLDERs, or all Maildirs",
+	ArgsUsage: "[FOLDER...]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "set",
+			Usage: "Set `QUOTA` such as 1000000S,1000C to FOLDERs, empty to remove",
+		},
+	},
+	Action: handleQuota,
+}
+
+var tuiCmd = cli.Command{
+	Name:   "tui",
+	Usage:  "Browse the Maildirs in the terminal",
+	Action: handleTUI,
 }
@@ -1,34 +1,33 @@
 package goem
 
 import (
-	"os"
-	"path/filepath"
-
-	"github.com/pelletier/go-toml"
+	"github.com/tennashi/goem"
+	"github.com/urfave/cli"
 )
 
-type config struct {
-	Maildir string `toml:"maildir"`
+// loadedConfig returns the configuration loaded before the command runs.
+func loadedConfig(c *cli.Context) *goem.Config {
+	if cfg, ok := c.App.Metadata["config"].(*goem.Config); ok {
+		return cfg
+	}
+	return &goem.Config{}
 }
 
-func loadConfig(path string) (*config, error) {
-	if path == "" {
-		cd, err := os.UserConfigDir()
-		if err != nil {
-			return nil, err
-		}
-		path = filepath.Join(cd, "goem", "config.toml")
-	}
-	f, err := os.Open(path)
+// selectedAccount returns the account selected by --account, or the default
+// account.
+func selectedAccount(c *cli.Context) (*goem.Account, error) {
+	a, err := loadedConfig(c).Account(c.GlobalString("account"))
 	if err != nil {
-		return nil, err
+		return nil, &exitError{code: exitConfig, err: err}
 	}
-	defer f.Close()
+	return a, nil
+}
 
-	cfg := &config{}
-	if err := toml.NewDecoder(f).Decode(cfg); err != nil {
-		return nil, err
+// optionalAccount returns the account selected as selectedAccount does, or
+// nil if no account is configured and --account is not given.
+func optionalAccount(c *cli.Context) (*goem.Account, error) {
+	if len(loadedConfig(c).Accounts) == 0 && c.GlobalString("account") == "" {
+		return nil, nil
 	}
-
-	return cfg, nil
+	return selectedAccount(c)
 }
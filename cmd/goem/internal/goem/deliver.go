@@ -0,0 +1,90 @@
+package goem
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/shellpath"
+	"github.com/urfave/cli"
+)
+
+func handleDeliver(c *cli.Context) error {
+	cfg := loadedConfig(c)
+	if err := localOnly(c); err != nil {
+		return deliverError(exitUsage, err)
+	}
+	if c.NArg() > 0 {
+		return deliverError(exitUsage, fmt.Errorf("unexpected arguments: %v", c.Args()))
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return deliverError(exitConfig, err)
+	}
+	account, err := optionalAccount(c)
+	if err != nil {
+		return deliverError(exitConfig, err)
+	}
+	mdr := goem.NewMaildirRoot(rootDir)
+	switch c.String("quota") {
+	case "enforce":
+	case "warn":
+		mdr.IgnoreQuota = true
+	default:
+		return deliverError(exitUsage, fmt.Errorf("unknown quota policy: %v", c.String("quota")))
+	}
+
+	var f *goem.Filter
+	script := c.String("script")
+	if script == "" && !c.Bool("no-filter") {
+		script = cfg.Filter.Script
+	}
+	if script != "" {
+		f, err = goem.LoadFilter(mdr, shellpath.Resolve(script))
+		if err != nil {
+			fmt.Fprintln(c.App.ErrWriter, "filter disabled:", err)
+		} else {
+			cfg.ConfigureFilter(f, account)
+		}
+	}
+
+	folder := c.String("folder")
+	if !c.IsSet("folder") && account != nil {
+		folder = account.Folders.Inbox
+	}
+	env := goem.Envelope{
+		From: c.String("sender"),
+		To:   c.String("recipient"),
+	}
+	key, err := mdr.Deliver(folder, stdin(c), env)
+	switch {
+	case err == goem.ErrEmptyMessage:
+		return deliverError(exitDataErr, err)
+	case err == maildir.ErrQuotaExceeded:
+		// temporary so that the MTA retries after the user cleans up.
+		return deliverError(exitTempFail, err)
+	case os.IsPermission(err):
+		return deliverError(exitNoPerm, err)
+	case err != nil:
+		return deliverError(exitTempFail, err)
+	}
+
+	if mdr.IgnoreQuota {
+		if u, err := mdr.Quota(folder); err == nil && u != nil && u.Exceeded() {
+			fmt.Fprintf(c.App.ErrWriter, "deliver: warning: %v is over quota\n", folder)
+		}
+	}
+
+	if f != nil {
+		// the message is already safe in new, so a failing filter only leaves it there.
+		if err := f.Apply(folder, key, &env); err != nil {
+			fmt.Fprintln(c.App.ErrWriter, "filter:", err)
+		}
+	}
+	return nil
+}
+
+func deliverError(code int, err error) error {
+	return &exitError{code: code, err: fmt.Errorf("deliver: %v", err)}
+}
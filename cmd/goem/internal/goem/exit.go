@@ -0,0 +1,47 @@
+package goem
+
+import (
+	"fmt"
+
+	"github.com/tennashi/goem"
+)
+
+// Exit statuses from sysexits.h used by deliver so that MTAs can retry,
+// by fsck to report the problems remaining, and by the other commands for
+// the wrong usage and the configuration.
+const (
+	exitUsage    = 64
+	exitDataErr  = 65
+	exitTempFail = 75
+	exitNoPerm   = 77
+	exitConfig   = 78
+)
+
+// exitError is the error with the exit status of goem.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// usageError returns the error of the wrong arguments or flags.
+func usageError(format string, a ...interface{}) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, a...)}
+}
+
+// exitCode returns the exit status for the error returned by the command.
+func exitCode(err error) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	switch goem.Code(err) {
+	case goem.CodeInvalidKey, goem.CodeUnknownSubDir:
+		return exitUsage
+	case goem.CodePermissionDenied:
+		return exitNoPerm
+	}
+	return 1
+}
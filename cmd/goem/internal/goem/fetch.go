@@ -0,0 +1,51 @@
+package goem
+
+import (
+	"fmt"
+
+	"github.com/tennashi/goem/pop3"
+	"github.com/urfave/cli"
+)
+
+func handleFetch(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	cfg := loadedConfig(c)
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return err
+	}
+
+	accounts := cfg.POP3
+	if c.NArg() > 0 {
+		accounts = nil
+		for _, name := range c.Args() {
+			a, ok := findPOP3Account(cfg.POP3, name)
+			if !ok {
+				return usageError("account %v doesn't exist", name)
+			}
+			accounts = append(accounts, a)
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	for _, a := range accounts {
+		n, err := pop3.NewFetcher(a, rootDir).Fetch(ctx)
+		fmt.Fprintf(c.App.Writer, "%v: %v mails fetched\n", a.Name, n)
+		if err != nil {
+			return fmt.Errorf("%v: %v", a.Name, err)
+		}
+	}
+	return nil
+}
+
+func findPOP3Account(accounts []pop3.Account, name string) (pop3.Account, bool) {
+	for _, a := range accounts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return pop3.Account{}, false
+}
@@ -0,0 +1,62 @@
+package goem
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/shellpath"
+	"github.com/urfave/cli"
+)
+
+func handleFilter(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	cfg := loadedConfig(c)
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return err
+	}
+
+	script := c.String("script")
+	if script == "" {
+		script = cfg.Filter.Script
+	}
+	if script == "" {
+		return &exitError{code: exitConfig, err: errors.New("sieve script doesn't set")}
+	}
+	folders := []string(c.Args())
+	if len(folders) == 0 {
+		folders = cfg.Filter.Folders
+	}
+	if len(folders) == 0 {
+		return usageError("folder is required")
+	}
+
+	account, err := optionalAccount(c)
+	if err != nil {
+		return err
+	}
+	f, err := goem.LoadFilter(goem.NewMaildirRoot(rootDir), shellpath.Resolve(script))
+	if err != nil {
+		return err
+	}
+	cfg.ConfigureFilter(f, account)
+
+	if c.Bool("watch") {
+		ctx, cancel := interruptContext()
+		defer cancel()
+		return f.Watch(ctx, folders, time.Duration(c.Int("interval"))*time.Second)
+	}
+
+	for _, folder := range folders {
+		n, err := f.FilterNew(folder)
+		fmt.Fprintf(c.App.Writer, "%v: %v mails filtered\n", folder, n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
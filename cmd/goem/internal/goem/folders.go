@@ -0,0 +1,26 @@
+package goem
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+)
+
+func handleFolders(c *cli.Context) error {
+	st, err := rootStore(c)
+	if err != nil {
+		return err
+	}
+	mds, err := st.Maildirs()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTOTAL\tUNREAD\tFLAGGED\tSIZE")
+	for _, md := range mds {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", md.Name, md.Total, md.Unread, md.Flagged, md.Size)
+	}
+	return w.Flush()
+}
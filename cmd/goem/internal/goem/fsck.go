@@ -0,0 +1,58 @@
+package goem
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/urfave/cli"
+)
+
+func handleFsck(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return err
+	}
+
+	folders := []string(c.Args())
+	if len(folders) == 0 {
+		mds, err := goem.NewMaildirRoot(rootDir).Maildirs()
+		if err != nil {
+			return err
+		}
+		for _, md := range mds {
+			folders = append(folders, md.Name)
+		}
+	}
+
+	opt := maildir.CheckOption{
+		Repair:   c.Bool("repair"),
+		StaleAge: c.Duration("stale-age"),
+	}
+	remaining := 0
+	for _, folder := range folders {
+		md, err := maildir.New(filepath.Join(rootDir, folder))
+		if err != nil {
+			return err
+		}
+		problems, err := md.Check(opt)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Fprintf(c.App.Writer, "%v/%v\n", folder, p)
+			if !p.Repaired {
+				remaining++
+			}
+		}
+	}
+	if remaining > 0 {
+		err := fmt.Errorf("%v problems found", remaining)
+		return &exitError{code: exitDataErr, err: err}
+	}
+	return nil
+}
@@ -1,34 +1,46 @@
 package goem
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
+	"github.com/tennashi/goem"
 	"github.com/tennashi/goem/shellpath"
 	"github.com/urfave/cli"
 )
 
 type Goem struct {
+	in     io.Reader
 	out    io.Writer
 	errOut io.Writer
 }
 
-func NewGoem(out, errOut io.Writer) *Goem {
+func NewGoem(in io.Reader, out, errOut io.Writer) *Goem {
 	return &Goem{
+		in:     in,
 		out:    out,
 		errOut: errOut,
 	}
 }
 
-func (g *Goem) Run() int {
-	if err := g.run(); err != nil {
-		return 1
+// Run runs goem with the command line arguments and returns the exit status.
+// The error of the command is written to errOut.
+func (g *Goem) Run(args []string) int {
+	if err := g.run(args); err != nil {
+		fmt.Fprintln(g.errOut, "goem:", err)
+		return exitCode(err)
 	}
 	return 0
 }
 
-func (g *Goem) run() error {
+func (g *Goem) run(args []string) error {
 	app := cli.NewApp()
 	app.Name = "goem"
 	app.Usage = UsageText
@@ -36,11 +48,12 @@ func (g *Goem) run() error {
 	app.Email = "yuya.gt@gmail.com"
 	app.Writer = g.out
 	app.ErrWriter = g.errOut
+	app.Metadata = map[string]interface{}{"stdin": g.in}
 	app.Commands = commands
 	app.Flags = globalFlags
 	app.Before = setConfig
 
-	return app.Run(os.Args)
+	return app.Run(args)
 }
 
 var globalFlags = []cli.Flag{
@@ -52,19 +65,129 @@ var globalFlags = []cli.Flag{
 		Name:  "maildir, m",
 		Usage: "Load Maildir from `DIR`",
 	},
+	cli.StringFlag{
+		Name:  "root, r",
+		Usage: "Load Maildirs under `DIR`",
+	},
+	cli.StringFlag{
+		Name:  "account, a",
+		Usage: "Use the account `NAME` instead of the default account",
+	},
+	cli.StringFlag{
+		Name:  "remote",
+		Usage: "Read mails from goemd at `URL` instead of the local Maildirs",
+	},
+	cli.BoolFlag{
+		Name:  "local",
+		Usage: "Use the local Maildirs even if the remote goemd is configured",
+	},
 }
 
 const UsageText = `Usage: goem`
 
 func setConfig(c *cli.Context) error {
 	cfgPath := c.GlobalString("config")
-	cfg, err := loadConfig(shellpath.Resolve(cfgPath))
+	if cfgPath != "" {
+		cfgPath = shellpath.Resolve(cfgPath)
+	}
+	cfg, err := goem.LoadConfig(cfgPath)
 	if err != nil {
-		fmt.Println("config error: ", err)
-		return nil
+		// each error of ConfigErrors is on its own line.
+		lines := strings.Split(err.Error(), "\n")
+		msg := "config error: " + strings.Join(lines, "\ngoem: config error: ")
+		// cli writes the error of Before with the help, but Run writes it
+		// to errOut instead.
+		c.App.Writer = ioutil.Discard
+		return &exitError{code: exitConfig, err: errors.New(msg)}
 	}
+	c.App.Metadata["config"] = cfg
 	if !c.GlobalIsSet("maildir") {
 		c.GlobalSet("maildir", cfg.Maildir)
 	}
 	return nil
 }
+
+// stdin returns the input of goem.
+func stdin(c *cli.Context) io.Reader {
+	if r, ok := c.App.Metadata["stdin"].(io.Reader); ok {
+		return r
+	}
+	return os.Stdin
+}
+
+// rootPath returns the root set by --root, or the root of the account.
+func rootPath(c *cli.Context) (string, error) {
+	if rootDir := c.GlobalString("root"); rootDir != "" {
+		return shellpath.Resolve(rootDir), nil
+	}
+	a, err := optionalAccount(c)
+	if err != nil {
+		return "", err
+	}
+	if a == nil {
+		return "", &exitError{code: exitConfig, err: errors.New("root doesn't set")}
+	}
+	return a.RootDir, nil
+}
+
+// selectFolder returns the root and the name of the folder: the folder
+// under the root if it is given, otherwise the maildir set by --maildir or
+// the inbox of the account under the root.
+func selectFolder(c *cli.Context, folder string) (string, string, error) {
+	if folder == "" && c.GlobalString("maildir") != "" {
+		mdPath, err := filepath.Abs(shellpath.Resolve(c.GlobalString("maildir")))
+		if err != nil {
+			return "", "", err
+		}
+		return filepath.Dir(mdPath), filepath.Base(mdPath), nil
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return "", "", err
+	}
+	if folder != "" {
+		return rootDir, folder, nil
+	}
+	folder, err = inboxFolder(c)
+	if err != nil {
+		return "", "", err
+	}
+	return rootDir, folder, nil
+}
+
+// inboxFolder returns the inbox of the account selected, or the default
+// inbox if no account is configured.
+func inboxFolder(c *cli.Context) (string, error) {
+	a, err := optionalAccount(c)
+	if err != nil {
+		return "", err
+	}
+	if a == nil {
+		return goem.DefaultFolders.Inbox, nil
+	}
+	return a.Folders.Inbox, nil
+}
+
+func folderPath(c *cli.Context, folder string) (string, error) {
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rootDir, folder), nil
+}
+
+// interruptContext returns the context canceled on the interrupt signal.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sig)
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,623 @@
+package goem_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+	cmd "github.com/tennashi/goem/cmd/goem/internal/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/server"
+)
+
+// setupRoot copies the fixture Maildirs in testdata/root into a temporary
+// directory and returns the config file and the root.
+func setupRoot(t *testing.T) (string, string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goem-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	err = filepath.Walk("testdata/root", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel("testdata/root", path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(root, rel)
+		if !info.IsDir() {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(dst, b, 0600)
+		}
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		if filepath.Dir(rel) != "." || rel == "." {
+			return nil
+		}
+		// git does not keep the empty sub directories of the maildirs.
+		for _, s := range []string{"cur", "new", "tmp"} {
+			if err := os.MkdirAll(filepath.Join(dst, s), 0700); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(cfg, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, root, func() { os.RemoveAll(dir) }
+}
+
+type result struct {
+	code   int
+	out    string
+	errOut string
+}
+
+func run(cfg, stdin string, args ...string) result {
+	var out, errOut bytes.Buffer
+	g := cmd.NewGoem(strings.NewReader(stdin), &out, &errOut)
+	code := g.Run(append([]string{"goem", "--config", cfg}, args...))
+	return result{code: code, out: out.String(), errOut: errOut.String()}
+}
+
+func Test_Goem_Run(t *testing.T) {
+	cases := map[string]struct {
+		// args are the arguments after "goem --config FILE --root DIR".
+		args   []string
+		noRoot bool
+		// maildir is the folder given by --maildir instead of --root.
+		maildir  string
+		stdin    string
+		wantCode int
+		wantOut  []string
+		wantErr  string
+	}{
+		"(valid)list": {
+			args: []string{"list"},
+			wantOut: []string{
+				"KEY", "1570000000.M1P100Q1.example:2,S", "1570000100.M2P100Q1.example:2,FS",
+				"1570000200.M3P100Q1.example", "Alice", "日本語",
+			},
+		},
+		"(valid)list unread with format": {
+			args:    []string{"list", "--unread", "--format", "{{.Subject}} {{.SubDir}}"},
+			wantOut: []string{"日本語 new\n"},
+		},
+		"(valid)list flagged as jsonl": {
+			args:    []string{"list", "--flagged", "--jsonl"},
+			wantOut: []string{`"key":"1570000100.M2P100Q1.example:2,FS"`, `"flags":"FS"`, `"flagged":true`},
+		},
+		"(valid)list since": {
+			args:    []string{"list", "--since", "2019-10-02T13:00:00Z", "--format", "{{.From}}"},
+			wantOut: []string{"Carol\n"},
+		},
+		"(valid)list by maildir": {
+			args:    []string{"list", "--subdir", "new", "--format", "{{.Folder}}"},
+			maildir: "INBOX",
+			wantOut: []string{"INBOX\n"},
+		},
+		"(valid)show": {
+			args:    []string{"show", "1570000100.M2P100Q1.example:2,FS"},
+			wantOut: []string{"From: bob@example.com\n", "Subject: flagged\n", "plain body"},
+		},
+		"(valid)show raw": {
+			args:    []string{"show", "--raw", "--folder", "INBOX", "1570000200.M3P100Q1.example"},
+			wantOut: []string{"Subject: =?UTF-8?B?5pel5pys6Kqe?=\n"},
+		},
+		"(valid)folders": {
+			args:    []string{"folders"},
+			wantOut: []string{"INBOX", "3"},
+		},
+		"(valid)deliver": {
+			args:  []string{"deliver", "--folder", "INBOX"},
+			stdin: "Subject: delivered\n\nbody\n",
+		},
+		"(invalid)list exclusive outputs": {
+			args:     []string{"list", "--json", "--jsonl"},
+			wantCode: 64,
+			wantErr:  "exclusive",
+		},
+		"(invalid)list unknown folder": {
+			args:     []string{"list", "nowhere"},
+			wantCode: 1,
+			wantErr:  "nowhere doesn't exist",
+		},
+		"(invalid)show without key": {
+			args:     []string{"show"},
+			wantCode: 64,
+			wantErr:  "key is required",
+		},
+		"(invalid)show unknown key": {
+			args:     []string{"show", "1.M1P1Q1.nowhere"},
+			wantCode: 1,
+			wantErr:  "1.M1P1Q1.nowhere doesn't exist in INBOX",
+		},
+		"(invalid)show invalid key": {
+			args:     []string{"show", "nokey"},
+			wantCode: 64,
+			wantErr:  "invalid key: nokey",
+		},
+		"(invalid)deliver empty message": {
+			args:     []string{"deliver"},
+			wantCode: 65,
+			wantErr:  "deliver: empty message",
+		},
+		"(invalid)root doesn't set": {
+			args:     []string{"folders"},
+			noRoot:   true,
+			wantCode: 78,
+			wantErr:  "root doesn't set",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, root, cleanup := setupRoot(t)
+			defer cleanup()
+
+			args := tt.args
+			switch {
+			case tt.maildir != "":
+				args = append([]string{"--maildir", filepath.Join(root, tt.maildir)}, args...)
+			case !tt.noRoot:
+				args = append([]string{"--root", root}, args...)
+			}
+			got := run(cfg, tt.stdin, args...)
+			if got.code != tt.wantCode {
+				t.Fatalf("\n\tgot: %v (%v)\n\twant: %v", got.code, got.errOut, tt.wantCode)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(got.out, want) {
+					t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.out, want)
+				}
+			}
+			if !strings.Contains(got.errOut, tt.wantErr) {
+				t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.errOut, tt.wantErr)
+			}
+			if tt.wantErr == "" && got.errOut != "" {
+				t.Fatalf("should not write errors but %v", got.errOut)
+			}
+		})
+	}
+}
+
+func Test_Goem_Run_deliverAndList(t *testing.T) {
+	cfg, root, cleanup := setupRoot(t)
+	defer cleanup()
+
+	if got := run(cfg, "Subject: delivered\n\nbody\n", "--root", root, "deliver"); got.code != 0 {
+		t.Fatalf("should not be error for deliver but %v", got.errOut)
+	}
+	got := run(cfg, "", "--root", root, "list", "--subdir", "new", "--format", "{{.Subject}}")
+	if got.code != 0 {
+		t.Fatalf("should not be error for list but %v", got.errOut)
+	}
+	if want := "日本語\ndelivered\n"; got.out != want {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got.out, want)
+	}
+}
+
+func Test_Goem_Run_list(t *testing.T) {
+	cfg, root, cleanup := setupRoot(t)
+	defer cleanup()
+
+	cases := map[string]struct {
+		args     []string
+		wantCode int
+		want     string
+		wantErr  string
+	}{
+		"(valid)all": {
+			want: "first\nflagged\n日本語\n",
+		},
+		"(valid)offset and limit": {
+			args: []string{"--offset", "1", "--limit", "1"},
+			want: "flagged\n",
+		},
+		"(valid)offset only": {
+			args: []string{"--offset", "2"},
+			want: "日本語\n",
+		},
+		"(valid)offset past the end": {
+			args: []string{"--offset", "5"},
+		},
+		"(valid)limit over the messages": {
+			args: []string{"--limit", "5"},
+			want: "first\nflagged\n日本語\n",
+		},
+		"(valid)cur since": {
+			args: []string{"--subdir", "cur", "--since", "2019-10-02T00:00:00Z"},
+			want: "flagged\n",
+		},
+		"(valid)unread and flagged": {
+			args: []string{"--unread", "--flagged"},
+		},
+		"(invalid)negative offset": {
+			args:     []string{"--offset", "-1"},
+			wantCode: 64,
+			wantErr:  "invalid offset: -1",
+		},
+		"(invalid)negative limit": {
+			args:     []string{"--limit", "-1"},
+			wantCode: 64,
+			wantErr:  "invalid limit: -1",
+		},
+		"(invalid)sub directory": {
+			args:     []string{"--subdir", "tmp"},
+			wantCode: 64,
+			wantErr:  "invalid sub directory: tmp",
+		},
+		"(invalid)since": {
+			args:     []string{"--since", "yesterday"},
+			wantCode: 64,
+			wantErr:  "invalid time: yesterday",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"--root", root, "list", "--format", "{{.Subject}}"}, tt.args...)
+			got := run(cfg, "", args...)
+			if got.code != tt.wantCode {
+				t.Fatalf("\n\tgot: %v (%v)\n\twant: %v", got.code, got.errOut, tt.wantCode)
+			}
+			if got.out != tt.want {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got.out, tt.want)
+			}
+			if !strings.Contains(got.errOut, tt.wantErr) {
+				t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.errOut, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Goem_Run_show(t *testing.T) {
+	cfg, root, cleanup := setupRoot(t)
+	defer cleanup()
+	md, err := maildir.New(filepath.Join(root, "INBOX"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		msg  string
+		want string
+	}{
+		"(valid)truncated multipart": {
+			msg:  "Subject: truncated\r\nContent-Type: multipart/mixed; boundary=b1\r\n\r\n--b1\r\n\r\ntruncated",
+			want: "Subject: truncated\n\n--b1\n\ntruncated",
+		},
+		"(valid)unknown charset": {
+			msg:  "Subject: charset\r\nContent-Type: text/plain; charset=x-unknown\r\n\r\nraw body\r\n",
+			want: "Subject: charset\n\nraw body\n",
+		},
+		"(valid)control characters": {
+			msg:  "Subject: =?UTF-8?Q?a=1B]0;title=07b?=\r\n\r\nline\x1b[2J\tcleared\r\n",
+			want: "Subject: a]0;titleb\n\nline[2J\tcleared\n",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			k, err := md.Deliver(strings.NewReader(tt.msg), maildir.DeliverOption{SubDir: maildir.SubDirCur})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := run(cfg, "", "--root", root, "show", k.String())
+			if got.code != 0 {
+				t.Fatalf("should not be error for %v but %v", k, got.errOut)
+			}
+			if got.out != tt.want {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got.out, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Goem_Run_deliver(t *testing.T) {
+	cfg, root, cleanup := setupRoot(t)
+	defer cleanup()
+	if got := run(cfg, "", "--root", root, "quota", "--set", "10S", "INBOX"); got.code != 0 {
+		t.Fatalf("should not be error for quota but %v", got.errOut)
+	}
+
+	cases := map[string]struct {
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		"(valid)over quota with warn": {
+			args:     []string{"deliver", "--quota", "warn"},
+			wantCode: 0,
+			wantErr:  "INBOX is over quota",
+		},
+		"(invalid)over quota": {
+			args:     []string{"deliver"},
+			wantCode: 75,
+			wantErr:  "deliver: ",
+		},
+		"(invalid)unknown quota policy": {
+			args:     []string{"deliver", "--quota", "ignore"},
+			wantCode: 64,
+			wantErr:  "unknown quota policy: ignore",
+		},
+		"(invalid)arguments": {
+			args:     []string{"deliver", "INBOX"},
+			wantCode: 64,
+			wantErr:  "unexpected arguments",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := run(cfg, "Subject: quota\n\nbody\n", append([]string{"--root", root}, tt.args...)...)
+			if got.code != tt.wantCode {
+				t.Fatalf("\n\tgot: %v (%v)\n\twant: %v", got.code, got.errOut, tt.wantCode)
+			}
+			if !strings.Contains(got.errOut, tt.wantErr) {
+				t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.errOut, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Goem_Run_remote(t *testing.T) {
+	cfg, root, cleanup := setupRoot(t)
+	defer cleanup()
+	ts := httptest.NewServer(server.NewRouter(goem.NewMaildirRoot(root), "secret"))
+	defer ts.Close()
+
+	remoteCfg := filepath.Join(filepath.Dir(cfg), "remote.toml")
+	content := "[remote]\nurl = \"" + ts.URL + "\"\ntoken = \"secret\"\n"
+	if err := ioutil.WriteFile(remoteCfg, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{
+		"(valid)list":      {"list"},
+		"(valid)list json": {"list", "--json", "--subdir", "cur"},
+		"(valid)show":      {"show", "1570000100.M2P100Q1.example:2,FS"},
+		"(valid)show raw":  {"show", "--raw", "1570000200.M3P100Q1.example"},
+		"(valid)folders":   {"folders"},
+		"(valid)quota":     {"quota", "INBOX"},
+	}
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := run(cfg, "", append([]string{"--root", root}, args...)...)
+			remote := run(remoteCfg, "", args...)
+			if local.code != 0 || remote.code != 0 {
+				t.Fatalf("should not be error for %v but %v, %v", args, local.errOut, remote.errOut)
+			}
+			if remote.out != local.out {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", remote.out, local.out)
+			}
+		})
+	}
+
+	t.Run("(invalid)wrong token", func(t *testing.T) {
+		got := run(cfg, "", "--remote", ts.URL, "list")
+		if got.code != 1 || !strings.Contains(got.errOut, "invalid token") {
+			t.Fatalf("\n\tgot: %v %v\n\twant: 1 invalid token", got.code, got.errOut)
+		}
+	})
+	t.Run("(invalid)local only command", func(t *testing.T) {
+		got := run(remoteCfg, "", "fsck")
+		if got.code != 64 {
+			t.Fatalf("\n\tgot: %v %v\n\twant: 64", got.code, got.errOut)
+		}
+	})
+	t.Run("(valid)local flag", func(t *testing.T) {
+		got := run(remoteCfg, "", "--local", "--root", root, "fsck")
+		if got.code != 0 {
+			t.Fatalf("should not be error for --local but %v", got.errOut)
+		}
+	})
+	t.Run("(valid)configured inbox", func(t *testing.T) {
+		md, err := maildir.Create(filepath.Join(root, "Work"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := md.Deliver(strings.NewReader("Subject: work\n\nbody\n"), maildir.DeliverOption{}); err != nil {
+			t.Fatal(err)
+		}
+		inboxCfg := filepath.Join(filepath.Dir(cfg), "inbox.toml")
+		content := content + "[[accounts]]\nname = \"work\"\nroot_dir = \"" + root + "\"\n[accounts.folders]\ninbox = \"Work\"\n"
+		if err := ioutil.WriteFile(inboxCfg, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, mode := range []string{"--local", "--remote=" + ts.URL} {
+			got := run(inboxCfg, "", mode, "list", "--format", "{{.Subject}}")
+			if got.code != 0 || got.out != "work\n" {
+				t.Fatalf("%v\n\tgot: %v %v (%v)\n\twant: work", mode, got.code, got.out, got.errOut)
+			}
+		}
+	})
+}
+
+func Test_Goem_Run_accounts(t *testing.T) {
+	cfg, root, cleanup := setupRoot(t)
+	defer cleanup()
+	work := filepath.Join(filepath.Dir(cfg), "work")
+	if err := os.Mkdir(work, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	accountsCfg := filepath.Join(filepath.Dir(cfg), "accounts.toml")
+	content := `
+[[accounts]]
+name = "personal"
+root_dir = "` + root + `"
+
+[[accounts.identities]]
+name = "Alice"
+addresses = ["alice@example.com"]
+
+[[accounts]]
+name = "work"
+root_dir = "` + work + `"
+default = true
+
+[accounts.folders]
+inbox = "Work"
+
+[accounts.imap]
+host = "imap.example.com"
+
+[accounts.smtp]
+host = "smtp.example.com"
+`
+	if err := ioutil.WriteFile(accountsCfg, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		args     []string
+		wantCode int
+		wantOut  []string
+		wantErr  string
+	}{
+		"(valid)accounts": {
+			args:    []string{"accounts"},
+			wantOut: []string{"personal", "alice@example.com", "* ", "work"},
+		},
+		"(valid)account folders": {
+			args:    []string{"--account", "personal", "folders"},
+			wantOut: []string{"INBOX"},
+		},
+		"(invalid)unknown account": {
+			args:     []string{"--account", "nowhere", "folders"},
+			wantCode: 78,
+			wantErr:  "account nowhere doesn't exist",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := run(accountsCfg, "", tt.args...)
+			if got.code != tt.wantCode {
+				t.Fatalf("\n\tgot: %v (%v)\n\twant: %v", got.code, got.errOut, tt.wantCode)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(got.out, want) {
+					t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.out, want)
+				}
+			}
+			if !strings.Contains(got.errOut, tt.wantErr) {
+				t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.errOut, tt.wantErr)
+			}
+		})
+	}
+
+	// deliver puts into the inbox of the default account.
+	if got := run(accountsCfg, "Subject: work\n\nbody\n", "deliver"); got.code != 0 {
+		t.Fatalf("should not be error for deliver but %v", got.errOut)
+	}
+	got := run(accountsCfg, "", "folders")
+	if got.code != 0 || !strings.Contains(got.out, "Work") || strings.Contains(got.out, "INBOX") {
+		t.Fatalf("\n\tgot: %v (%v)\n\twant: Work only", got.out, got.errOut)
+	}
+}
+
+func Test_Goem_Run_config(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		wantErr []string
+	}{
+		"(invalid)syntax": {
+			content: "root_dir = \n",
+			wantErr: []string{"bad.toml:2:1: expecting a value"},
+		},
+		"(invalid)unknown key": {
+			content: "root_dir = \"ROOT\"\nroot = \"ROOT\"\n",
+			wantErr: []string{"bad.toml:2:1: root: unknown key"},
+		},
+		"(invalid)port": {
+			content: "root_dir = \"ROOT\"\n[server]\nport = 0\n",
+			wantErr: []string{"bad.toml:3:1: server.port: invalid port: 0"},
+		},
+		"(invalid)port not number": {
+			content: "root_dir = \"ROOT\"\n[server]\nport = \"http\"\n",
+			wantErr: []string{"bad.toml:3:1: server.port: invalid port: http"},
+		},
+		"(invalid)root_dir": {
+			content: "[[accounts]]\nname = \"a\"\nroot_dir = \"ROOT/nowhere\"\n[accounts.imap]\ntls = \"ssl\"\n",
+			wantErr: []string{
+				"bad.toml:3:1: accounts[0].root_dir: ", "nowhere doesn't exist",
+				"bad.toml:4:1: accounts[0].imap.host: host is required",
+				"bad.toml:5:1: accounts[0].imap.tls: ",
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, root, cleanup := setupRoot(t)
+			defer cleanup()
+			bad := filepath.Join(filepath.Dir(cfg), "bad.toml")
+			content := strings.Replace(tt.content, "ROOT", root, -1)
+			if err := ioutil.WriteFile(bad, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// the command doesn't run with the errors even if --root is given.
+			got := run(bad, "", "--root", root, "folders")
+			if got.code != 78 {
+				t.Fatalf("\n\tgot: %v (%v)\n\twant: 78", got.code, got.errOut)
+			}
+			if got.out != "" {
+				t.Fatalf("should not run the command but %v", got.out)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(got.errOut, want) {
+					t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.errOut, want)
+				}
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(got.errOut, "\n"), "\n") {
+				if !strings.HasPrefix(line, "goem: config error: ") {
+					t.Fatalf("\n\tgot: %v\n\twant: goem: config error: ...", line)
+				}
+			}
+		})
+	}
+
+	t.Run("(valid)env", func(t *testing.T) {
+		cfg, root, cleanup := setupRoot(t)
+		defer cleanup()
+		os.Setenv("GOEM_ROOT_DIR", root)
+		defer os.Unsetenv("GOEM_ROOT_DIR")
+
+		got := run(cfg, "", "folders")
+		if got.code != 0 || !strings.Contains(got.out, "INBOX") {
+			t.Fatalf("\n\tgot: %v (%v)\n\twant: INBOX", got.out, got.errOut)
+		}
+	})
+	t.Run("(invalid)env", func(t *testing.T) {
+		cfg, root, cleanup := setupRoot(t)
+		defer cleanup()
+		os.Setenv("GOEM_ROOT_DIR", filepath.Join(root, "nowhere"))
+		defer os.Unsetenv("GOEM_ROOT_DIR")
+
+		got := run(cfg, "", "folders")
+		if got.code != 78 {
+			t.Fatalf("\n\tgot: %v (%v)\n\twant: 78", got.code, got.errOut)
+		}
+		if want := "goem: config error: $GOEM_ROOT_DIR: root_dir: "; !strings.Contains(got.errOut, want) {
+			t.Fatalf("\n\tgot: %v\n\twant: containing %v", got.errOut, want)
+		}
+	})
+}
@@ -1,74 +1,199 @@
 package goem
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
-	"strconv"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
-	"github.com/tennashi/goem/mail"
+	"github.com/tennashi/goem"
 	"github.com/tennashi/goem/maildir"
-	"github.com/tennashi/goem/shellpath"
 	"github.com/urfave/cli"
 )
 
+// listEntry is a row of goem list. It is also the data of --format and the
+// object written by --json and --jsonl.
+type listEntry struct {
+	Key     string    `json:"key"`
+	Folder  string    `json:"folder"`
+	SubDir  string    `json:"subdir"`
+	Date    time.Time `json:"date"`
+	From    string    `json:"from"`
+	Subject string    `json:"subject"`
+	Flags   string    `json:"flags"`
+	Size    int64     `json:"size"`
+	Unread  bool      `json:"unread"`
+	Flagged bool      `json:"flagged"`
+}
+
 func handleList(c *cli.Context) error {
-	if !c.GlobalIsSet("maildir") {
-		err := errors.New("maildir doesn't set")
-		fmt.Println(err)
+	st, folder, err := folderStore(c, c.Args().Get(0))
+	if err != nil {
 		return err
 	}
 
-	mdPath := shellpath.Resolve(c.GlobalString("maildir"))
-	md, err := maildir.New(mdPath)
-	if err != nil {
-		fmt.Println(err)
-		return err
+	outputs := 0
+	for _, name := range []string{"format", "json", "jsonl"} {
+		if c.IsSet(name) {
+			outputs++
+		}
+	}
+	if outputs > 1 {
+		return usageError("--format, --json and --jsonl are exclusive")
 	}
 
-	mails, err := md.Messages(maildir.SubDirCur)
-	if err != nil {
-		fmt.Println(err)
-		return err
+	var subDirs []string
+	switch sd := c.String("subdir"); sd {
+	case "all":
+		subDirs = []string{"new", "cur"}
+	case "new", "cur":
+		subDirs = []string{sd}
+	default:
+		return usageError("invalid sub directory: %v", sd)
 	}
 
-	var offset int
-	offsetStr := c.Args().Get(0)
-	if offsetStr == "" {
-		offset = 0
-	} else {
-		var err error
-		offset, err = strconv.Atoi(offsetStr)
+	var since time.Time
+	if c.IsSet("since") {
+		since, err = parseSince(c.String("since"), time.Now())
 		if err != nil {
-			fmt.Println(err)
-			return err
-		}
-		if offset > len(mails) {
-			offset = len(mails) - 1
+			return usageError("%v", err)
 		}
 	}
 
-	var limit int
-	limitStr := c.Args().Get(1)
-	if limitStr == "" {
-		limit = len(mails)
-	} else {
-		var err error
-		limit, err = strconv.Atoi(limitStr)
+	offset, limit := c.Int("offset"), c.Int("limit")
+	if offset < 0 {
+		return usageError("invalid offset: %v", offset)
+	}
+	if limit < 0 {
+		return usageError("invalid limit: %v", limit)
+	}
+
+	var entries []listEntry
+	for _, sd := range subDirs {
+		mails, err := st.Mails(folder, sd)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
+		for _, m := range mails {
+			e := newListEntry(folder, sd, m)
+			if c.Bool("unread") && !e.Unread || c.Bool("flagged") && !e.Flagged {
+				continue
+			}
+			if !since.IsZero() && e.Date.Before(since) {
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+
+	if offset > len(entries) {
+		offset = len(entries)
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+
+	switch {
+	case c.IsSet("format"):
+		err = writeListTemplate(c, c.String("format"), entries)
+	case c.Bool("json"):
+		enc := json.NewEncoder(c.App.Writer)
+		enc.SetIndent("", "  ")
+		if entries == nil {
+			entries = []listEntry{}
+		}
+		err = enc.Encode(entries)
+	case c.Bool("jsonl"):
+		enc := json.NewEncoder(c.App.Writer)
+		for _, e := range entries {
+			if err = enc.Encode(e); err != nil {
+				break
+			}
+		}
+	default:
+		err = writeListTable(c, entries)
 	}
+	return err
+}
 
-	if offset+limit > len(mails) {
-		limit = len(mails) - offset
+func newListEntry(folder, subDir string, m goem.Mail) listEntry {
+	e := listEntry{
+		Key:     m.Key.String(),
+		Folder:  folder,
+		SubDir:  subDir,
+		Subject: m.Subject,
+		Size:    m.Size,
+		Flags:   strings.Join(m.Key.Flags, ""),
+		Unread:  subDir == "new" || !m.Key.HasFlag(maildir.FlagSeen),
+		Flagged: m.Key.HasFlag(maildir.FlagFlagged),
+	}
+	if d, err := m.Headers.Date(); err == nil {
+		e.Date = d
+	} else {
+		e.Date = time.Unix(int64(m.Key.Second), 0)
+	}
+	if as := m.Headers.Addresses("From"); len(as) > 0 {
+		e.From = as[0].Name
+		if e.From == "" {
+			e.From = as[0].Address
+		}
 	}
+	return e
+}
 
-	for _, m := range mails[offset : offset+limit] {
-		h := mail.Header(m.Header)
-		subject := h.Get("Subject")
-		fmt.Println("Subject:", subject)
+// parseSince parses the date, the time in RFC 3339 or the duration before now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
 	}
+	return time.Time{}, fmt.Errorf("invalid time: %v", s)
+}
 
+func writeListTemplate(c *cli.Context, format string, entries []listEntry) error {
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := tmpl.Execute(c.App.Writer, e); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.App.Writer)
+	}
 	return nil
 }
+
+func writeListTable(c *cli.Context, entries []listEntry) error {
+	w := tabwriter.NewWriter(c.App.Writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "KEY\tDATE\tFROM\tSUBJECT\tFLAGS\tSIZE")
+	for _, e := range entries {
+		flags := e.Flags
+		if flags == "" {
+			flags = "-"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			e.Key, e.Date.Local().Format("2006-01-02 15:04"), tabSafe(e.From), tabSafe(e.Subject), flags, e.Size)
+	}
+	return w.Flush()
+}
+
+// tabSafe replaces the characters breaking the columns.
+func tabSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,118 @@
+package goem
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/mbox"
+	"github.com/tennashi/goem/shellpath"
+	"github.com/urfave/cli"
+)
+
+func handleImport(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	path := c.Args().Get(0)
+	folder := c.Args().Get(1)
+	if path == "" || folder == "" {
+		return usageError("file and folder are required")
+	}
+
+	switch c.String("format") {
+	case "mh", "eml":
+		return importDir(c, shellpath.Resolve(path), folder)
+	}
+
+	f := mbox.NewFormat(c.String("format"))
+	if f == mbox.FormatUnknown {
+		return usageError("unknown format: %v", c.String("format"))
+	}
+	mdPath, err := folderPath(c, folder)
+	if err != nil {
+		return err
+	}
+
+	r := stdin(c)
+	if path != "-" {
+		file, err := os.Open(shellpath.Resolve(path))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	md, err := maildir.Create(mdPath)
+	if err != nil {
+		return err
+	}
+	n, err := mbox.Import(md, r, f)
+	fmt.Fprintf(c.App.Writer, "%v mails imported\n", n)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func importDir(c *cli.Context, dir, folder string) error {
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return err
+	}
+	mdr := goem.NewMaildirRoot(rootDir)
+
+	var n int
+	if c.String("format") == "mh" {
+		n, err = mdr.ImportMH(folder, dir)
+	} else {
+		n, err = mdr.ImportEML(folder, dir)
+	}
+	fmt.Fprintf(c.App.Writer, "%v mails imported\n", n)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func handleExport(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	f := mbox.NewFormat(c.String("format"))
+	if f == mbox.FormatUnknown {
+		return usageError("unknown format: %v", c.String("format"))
+	}
+	folder := c.Args().Get(0)
+	if folder == "" {
+		return usageError("folder is required")
+	}
+	mdPath, err := folderPath(c, folder)
+	if err != nil {
+		return err
+	}
+	if !maildir.IsMaildir(mdPath) {
+		return fmt.Errorf("%v is not maildir", mdPath)
+	}
+	md, err := maildir.New(mdPath)
+	if err != nil {
+		return err
+	}
+
+	w := c.App.Writer
+	if path := c.Args().Get(1); path != "" && path != "-" {
+		file, err := os.Create(shellpath.Resolve(path))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if _, err := mbox.Export(w, md, f); err != nil {
+		return err
+	}
+	return nil
+}
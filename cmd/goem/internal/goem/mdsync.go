@@ -0,0 +1,33 @@
+package goem
+
+import (
+	"fmt"
+
+	"github.com/tennashi/goem/mdsync"
+	"github.com/tennashi/goem/shellpath"
+	"github.com/urfave/cli"
+)
+
+func handleMdsync(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return err
+	}
+	if c.NArg() != 1 {
+		return usageError("remote root directory is required")
+	}
+
+	res, err := mdsync.New(rootDir, shellpath.Resolve(c.Args().First())).Sync()
+	if err != nil {
+		return err
+	}
+	for _, conflict := range res.Conflicts {
+		fmt.Fprintf(c.App.ErrWriter, "conflict: %v\n", conflict)
+	}
+	fmt.Fprintf(c.App.Writer, "%v copied, %v moved, %v removed, %v updated\n",
+		res.Copied, res.Moved, res.Removed, res.Updated)
+	return nil
+}
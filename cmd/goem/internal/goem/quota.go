@@ -0,0 +1,76 @@
+package goem
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/urfave/cli"
+)
+
+func handleQuota(c *cli.Context) error {
+	folders := []string(c.Args())
+	if c.IsSet("set") {
+		if err := localOnly(c); err != nil {
+			return err
+		}
+		if len(folders) == 0 {
+			return usageError("folder is required")
+		}
+		rootDir, err := rootPath(c)
+		if err != nil {
+			return err
+		}
+		mdr := goem.NewMaildirRoot(rootDir)
+		q, err := maildir.ParseQuota(c.String("set"))
+		if err != nil {
+			return err
+		}
+		for _, folder := range folders {
+			if _, err := mdr.SetQuota(folder, q); err != nil {
+				return err
+			}
+		}
+	}
+
+	st, err := rootStore(c)
+	if err != nil {
+		return err
+	}
+	mds, err := st.Maildirs()
+	if err != nil {
+		return err
+	}
+	usages := make(map[string]*maildir.Usage, len(mds))
+	for _, md := range mds {
+		usages[md.Name] = md.Quota
+	}
+	if len(folders) == 0 {
+		for _, md := range mds {
+			folders = append(folders, md.Name)
+		}
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tSIZE LIMIT\tMESSAGES\tMESSAGE LIMIT")
+	for _, folder := range folders {
+		u, ok := usages[folder]
+		if !ok {
+			return fmt.Errorf("%v is not maildir", folder)
+		}
+		if u == nil {
+			fmt.Fprintf(w, "%v\t-\t-\t-\t-\n", folder)
+			continue
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", folder, u.Bytes, limit(u.Quota.Bytes), u.Messages, limit(u.Quota.Messages))
+	}
+	return w.Flush()
+}
+
+func limit(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
@@ -1,41 +1,67 @@
 package goem
 
 import (
-	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	netmail "net/mail"
+	"strings"
 
-	"github.com/tennashi/goem/maildir"
-	"github.com/tennashi/goem/shellpath"
+	"github.com/tennashi/goem/mail"
 	"github.com/urfave/cli"
 )
 
 func handleShow(c *cli.Context) error {
-	if !c.GlobalIsSet("maildir") {
-		err := errors.New("maildir doesn't set")
-		fmt.Println(err)
+	key := c.Args().Get(0)
+	if key == "" {
+		return usageError("key is required")
+	}
+	st, folder, err := folderStore(c, c.String("folder"))
+	if err != nil {
 		return err
 	}
 
-	mdPath := shellpath.Resolve(c.GlobalString("maildir"))
-	md, err := maildir.New(mdPath)
+	f, err := st.OpenMail(folder, key)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-
-	key := c.Args().Get(0)
-	if key == "" {
-		err := errors.New("key is required")
-		fmt.Println(err)
+	defer f.Close()
+	if c.Bool("raw") {
+		_, err = io.Copy(c.App.Writer, f)
 		return err
 	}
 
-	mail, err := md.GetMessageWithRawKey(key, maildir.SubDirCur)
+	m, err := netmail.ReadMessage(f)
 	if err != nil {
 		return err
 	}
+	b, err := ioutil.ReadAll(m.Body)
+	if err != nil {
+		return err
+	}
+	h := mail.Header(m.Header)
+	_, text := mail.ParseText(h, b)
 
-	fmt.Println(mail)
-
+	for _, name := range []string{"From", "To", "Cc", "Date", "Subject"} {
+		if v := h.Get(name); v != "" {
+			fmt.Fprintf(c.App.Writer, "%v: %v\n", name, printable(v))
+		}
+	}
+	fmt.Fprintln(c.App.Writer)
+	fmt.Fprint(c.App.Writer, printable(text))
 	return nil
 }
+
+// printable removes the control characters but the line breaks and the tabs,
+// which could move the cursor or change the terminal settings.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < ' ' || r >= 0x7f && r < 0xa0 {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,172 @@
+package goem
+
+import (
+	"context"
+	"io"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
+	"github.com/tennashi/goem/client"
+	"github.com/tennashi/goem/mail"
+	"github.com/tennashi/goem/maildir"
+	"github.com/urfave/cli"
+)
+
+// store is where the commands read the mails from: the Maildirs on the
+// disk, or goemd in the remote mode. Both return the same values so that
+// the output of the commands is the same.
+type store interface {
+	Maildirs() ([]goem.Maildir, error)
+	// Mails returns the mails with the headers but without the bodies.
+	Mails(folder, subDir string) ([]goem.Mail, error)
+	OpenMail(folder, key string) (io.ReadCloser, error)
+}
+
+type localStore struct {
+	mdr *goem.MaildirRoot
+}
+
+func (s localStore) Maildirs() ([]goem.Maildir, error) {
+	return s.mdr.Maildirs()
+}
+
+func (s localStore) Mails(folder, subDir string) ([]goem.Mail, error) {
+	return s.mdr.GetMails(folder, subDir)
+}
+
+func (s localStore) OpenMail(folder, key string) (io.ReadCloser, error) {
+	return s.mdr.OpenMail(folder, key)
+}
+
+type remoteStore struct {
+	ctx    context.Context
+	client *client.Client
+}
+
+func (s remoteStore) Maildirs() ([]goem.Maildir, error) {
+	mds, err := s.client.Maildirs(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]goem.Maildir, len(mds))
+	for i, md := range mds {
+		ret[i] = goem.Maildir{
+			Name:    md.Name,
+			Total:   md.Total,
+			Unread:  md.Unread,
+			Flagged: md.Flagged,
+			Size:    md.Size,
+		}
+		if q := md.Quota; q != nil {
+			ret[i].Quota = &maildir.Usage{
+				Quota:    maildir.Quota{Bytes: q.BytesLimit, Messages: q.MessagesLimit},
+				Bytes:    q.Bytes,
+				Messages: q.Messages,
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (s remoteStore) Mails(folder, subDir string) ([]goem.Mail, error) {
+	var ret []goem.Mail
+	it := s.client.ListMails(folder, client.ListOptions{SubDir: subDir})
+	for it.Next(s.ctx) {
+		m := it.Mail()
+		k, err := maildir.ParseKey(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		h := mail.Header(m.Headers)
+		// the headers are decoded by goemd, so the addresses are taken from
+		// the parsed ones to keep the names containing "," or "<".
+		for name, as := range map[string][]api.Address{
+			"From": m.From, "To": m.To, "Cc": m.Cc, "Bcc": m.Bcc, "Reply-To": m.ReplyTo,
+		} {
+			if len(as) > 0 {
+				h[name] = []string{formatAddresses(as)}
+			}
+		}
+		ret = append(ret, goem.Mail{Key: k, Subject: m.Subject, Headers: h, Size: m.Size})
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s remoteStore) OpenMail(folder, key string) (io.ReadCloser, error) {
+	return s.client.Raw(s.ctx, folder, key)
+}
+
+func formatAddresses(as []api.Address) string {
+	ss := make([]string, len(as))
+	for i, a := range as {
+		ss[i] = (&netmail.Address{Name: a.Name, Address: a.Address}).String()
+	}
+	return strings.Join(ss, ", ")
+}
+
+// remoteEndpoint returns the URL of goemd set by --remote or the
+// configuration, or "" in the local mode.
+func remoteEndpoint(c *cli.Context) string {
+	if c.GlobalBool("local") {
+		return ""
+	}
+	if u := c.GlobalString("remote"); u != "" {
+		return u
+	}
+	return loadedConfig(c).Remote.URL
+}
+
+func newRemoteStore(c *cli.Context, endpoint string) (store, error) {
+	cl, err := client.New(endpoint, loadedConfig(c).Remote.Token)
+	if err != nil {
+		return nil, &exitError{code: exitConfig, err: err}
+	}
+	return remoteStore{ctx: context.Background(), client: cl}, nil
+}
+
+// rootStore returns the store of all the Maildirs.
+func rootStore(c *cli.Context) (store, error) {
+	if endpoint := remoteEndpoint(c); endpoint != "" {
+		return newRemoteStore(c, endpoint)
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return nil, err
+	}
+	return localStore{mdr: goem.NewMaildirRoot(rootDir)}, nil
+}
+
+// folderStore returns the store and the name of the folder selected as
+// selectFolder does. The folder is the inbox of the account by default in
+// the remote mode.
+func folderStore(c *cli.Context, folder string) (store, string, error) {
+	if endpoint := remoteEndpoint(c); endpoint != "" {
+		if folder == "" {
+			inbox, err := inboxFolder(c)
+			if err != nil {
+				return nil, "", err
+			}
+			folder = inbox
+		}
+		s, err := newRemoteStore(c, endpoint)
+		return s, folder, err
+	}
+	rootDir, folder, err := selectFolder(c, folder)
+	if err != nil {
+		return nil, "", err
+	}
+	return localStore{mdr: goem.NewMaildirRoot(rootDir)}, folder, nil
+}
+
+// localOnly returns the error if the command is run in the remote mode.
+func localOnly(c *cli.Context) error {
+	if remoteEndpoint(c) == "" {
+		return nil
+	}
+	return usageError("%v works on the local Maildirs only; use --local", c.Command.Name)
+}
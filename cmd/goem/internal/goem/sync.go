@@ -0,0 +1,78 @@
+package goem
+
+import (
+	"fmt"
+
+	"github.com/tennashi/goem/imapsync"
+	"github.com/urfave/cli"
+)
+
+// syncTarget is the IMAP account and the root it is synchronized into.
+type syncTarget struct {
+	account imapsync.Account
+	rootDir string
+}
+
+func handleSync(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	targets, err := syncTargets(c)
+	if err != nil {
+		return err
+	}
+	if c.NArg() > 0 {
+		all := targets
+		targets = nil
+		for _, name := range c.Args() {
+			t, ok := findTarget(all, name)
+			if !ok {
+				return usageError("account %v doesn't exist", name)
+			}
+			targets = append(targets, t)
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	for _, t := range targets {
+		if err := imapsync.New(t.account, t.rootDir).Sync(ctx); err != nil {
+			return fmt.Errorf("%v: %v", t.account.Name, err)
+		}
+		fmt.Fprintf(c.App.Writer, "%v: synchronized\n", t.account.Name)
+	}
+	return nil
+}
+
+// syncTargets returns the IMAP settings of the accounts, and the [[imap]]
+// accounts synchronized into the root selected.
+func syncTargets(c *cli.Context) ([]syncTarget, error) {
+	cfg := loadedConfig(c)
+	var ret []syncTarget
+	for _, a := range cfg.Accounts {
+		if a.IMAP != nil {
+			ret = append(ret, syncTarget{account: *a.IMAP, rootDir: a.RootDir})
+		}
+	}
+	if len(cfg.IMAP) == 0 {
+		return ret, nil
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range cfg.IMAP {
+		ret = append(ret, syncTarget{account: a, rootDir: rootDir})
+	}
+	return ret, nil
+}
+
+func findTarget(targets []syncTarget, name string) (syncTarget, bool) {
+	for _, t := range targets {
+		if t.account.Name == name {
+			return t, true
+		}
+	}
+	return syncTarget{}, false
+}
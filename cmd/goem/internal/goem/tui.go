@@ -0,0 +1,36 @@
+package goem
+
+import (
+	"os"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/tui"
+	"github.com/urfave/cli"
+)
+
+func handleTUI(c *cli.Context) error {
+	if err := localOnly(c); err != nil {
+		return err
+	}
+	rootDir, err := rootPath(c)
+	if err != nil {
+		return err
+	}
+
+	a, err := optionalAccount(c)
+	if err != nil {
+		return err
+	}
+	special := goem.DefaultFolders
+	if a != nil {
+		special = a.Folders
+	}
+	app, err := tui.New(rootDir, special)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	return app.Run(ctx, os.Stdin, c.App.Writer)
+}
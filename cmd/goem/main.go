@@ -7,6 +7,6 @@ import (
 )
 
 func main() {
-	g := goem.NewGoem(os.Stdout, os.Stderr)
-	os.Exit(g.Run())
+	g := goem.NewGoem(os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(g.Run(os.Args))
 }
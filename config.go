@@ -1,46 +1,385 @@
 package goem
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/pelletier/go-toml"
+	"github.com/tennashi/goem/imapsync"
+	"github.com/tennashi/goem/pop3"
 	"github.com/tennashi/goem/shellpath"
 )
 
+// ErrNoAccount is returned when no account is configured.
+var ErrNoAccount = errors.New("no account is configured")
+
+// DefaultAccountName is the name of the account made of root_dir.
+const DefaultAccountName = "default"
+
+// Config is the configuration shared by goem and goemd.
 type Config struct {
-	RootDir string       `toml:"root_dir"`
-	Server  ServerConfig `toml:"server"`
+	// Path is the file the configuration is loaded from, empty if the
+	// default file doesn't exist.
+	Path string `toml:"-"`
+	// RootDir is the root of the account named "default", which is used
+	// only if Accounts is empty.
+	RootDir string `toml:"root_dir"`
+	// Maildir is the maildir goem reads when no folder is given.
+	Maildir  string       `toml:"maildir"`
+	Accounts []Account    `toml:"accounts"`
+	Server   ServerConfig `toml:"server"`
+	Filter   FilterConfig `toml:"filter"`
+	Remote   RemoteConfig `toml:"remote"`
+	// IMAP are the IMAP accounts synchronized into the root of the account
+	// selected, in addition to the IMAP settings of the accounts.
+	IMAP []imapsync.Account `toml:"imap"`
+	// POP3 are the POP3 accounts fetched into the root of the account selected.
+	POP3 []pop3.Account `toml:"pop3"`
+}
+
+// Account is a mail account with its own Maildirs.
+type Account struct {
+	Name string `toml:"name"`
+	// RootDir is the directory the Maildirs of the account are in.
+	RootDir string `toml:"root_dir"`
+	// Default marks the account used if no account is given. The first
+	// account is the default if no account is marked.
+	Default    bool       `toml:"default"`
+	Identities []Identity `toml:"identities"`
+	// SMTP is the server the mails are sent through, nil if not set.
+	SMTP *SMTPConfig `toml:"smtp"`
+	// IMAP is the server synchronized into RootDir, nil if not set.
+	IMAP    *imapsync.Account `toml:"imap"`
+	Folders Folders           `toml:"folders"`
+}
+
+// Identity is the sender the user writes the mails as.
+type Identity struct {
+	Name      string   `toml:"name"`
+	Addresses []string `toml:"addresses"`
+	Signature string   `toml:"signature"`
+}
+
+// SMTPConfig is the configuration of the SMTP server.
+type SMTPConfig struct {
+	Host string `toml:"host"`
+	// Port is 465 for "tls" and 587 for the others if zero.
+	Port int `toml:"port"`
+	// TLS is "tls", "starttls" or "none". The default is "starttls".
+	TLS      string `toml:"tls"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
 }
 
+// Folders are the maildir names of the special folders.
+type Folders struct {
+	Inbox   string `toml:"inbox"`
+	Sent    string `toml:"sent"`
+	Drafts  string `toml:"drafts"`
+	Trash   string `toml:"trash"`
+	Archive string `toml:"archive"`
+}
+
+// DefaultFolders are the names of the special folders not configured.
+var DefaultFolders = Folders{
+	Inbox:   "INBOX",
+	Sent:    "Sent",
+	Drafts:  DefaultDrafts,
+	Trash:   "Trash",
+	Archive: "Archive",
+}
+
+// ServerConfig is the configuration of goemd.
 type ServerConfig struct {
+	// Port is the port goemd listens on, DefaultPort if empty.
 	Port string `toml:"port"`
+	// Token is the bearer token required for the API, which is open if empty.
+	Token string `toml:"token"`
+	// Account is the name of the account served, the default account if empty.
+	Account string `toml:"account"`
+	// CertFile and KeyFile are the certificate and its key goemd serves
+	// TLS with, which is not used if empty.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+}
+
+// FilterConfig is the configuration of the sieve filter.
+type FilterConfig struct {
+	// Script is the path of the sieve script.
+	Script string `toml:"script"`
+	// Folders are the maildir names whose new messages are filtered.
+	Folders []string `toml:"folders"`
+	// Drafts is the maildir name the vacation replies are stored in,
+	// the drafts folder of the account if empty.
+	Drafts string `toml:"drafts"`
+	// Interval is the polling interval in seconds, DefaultFilterInterval
+	// if zero.
+	Interval int `toml:"interval"`
+}
+
+// RemoteConfig is the goemd which goem works against instead of the local
+// Maildirs.
+type RemoteConfig struct {
+	// URL is the endpoint of goemd such as "https://mail.example.com:8080".
+	URL string `toml:"url"`
+	// Token is the bearer token set in the server configuration of goemd.
+	Token string `toml:"token"`
 }
 
-func NewConfig(path string) *Config {
-	path = configPath(path)
-	file, err := os.Open(path)
+// Defaults of the configuration.
+const (
+	// DefaultPort is the port goemd listens on.
+	DefaultPort = "8080"
+	// DefaultFilterInterval is the polling interval of the filter in seconds.
+	DefaultFilterInterval = 30
+)
+
+// envOverrides are the environment variables overriding the values in the
+// configuration file.
+var envOverrides = []struct {
+	name  string
+	key   string
+	value func(c *Config) *string
+}{
+	{"GOEM_ROOT_DIR", "root_dir", func(c *Config) *string { return &c.RootDir }},
+	{"GOEM_MAILDIR", "maildir", func(c *Config) *string { return &c.Maildir }},
+	{"GOEM_SERVER_PORT", "server.port", func(c *Config) *string { return &c.Server.Port }},
+	{"GOEM_SERVER_TOKEN", "server.token", func(c *Config) *string { return &c.Server.Token }},
+	{"GOEM_SERVER_ACCOUNT", "server.account", func(c *Config) *string { return &c.Server.Account }},
+	{"GOEM_SERVER_CERT_FILE", "server.cert_file", func(c *Config) *string { return &c.Server.CertFile }},
+	{"GOEM_SERVER_KEY_FILE", "server.key_file", func(c *Config) *string { return &c.Server.KeyFile }},
+	{"GOEM_FILTER_SCRIPT", "filter.script", func(c *Config) *string { return &c.Filter.Script }},
+	{"GOEM_REMOTE_URL", "remote.url", func(c *Config) *string { return &c.Remote.URL }},
+	{"GOEM_REMOTE_TOKEN", "remote.token", func(c *Config) *string { return &c.Remote.Token }},
+}
+
+// LoadConfig reads the configuration file at the path, $GOEM_CONFIG or
+// DefaultConfigPath if path is empty. The default file is optional.
+//
+// The values are overridden by the environment variables GOEM_ROOT_DIR,
+// GOEM_MAILDIR, GOEM_SERVER_PORT, GOEM_SERVER_TOKEN, GOEM_SERVER_ACCOUNT,
+// GOEM_SERVER_CERT_FILE, GOEM_SERVER_KEY_FILE, GOEM_FILTER_SCRIPT,
+// GOEM_REMOTE_URL and GOEM_REMOTE_TOKEN, then the
+// defaults are set and the configuration is validated. The errors in the
+// configuration are returned as ConfigErrors.
+func LoadConfig(path string) (*Config, error) {
+	optional := false
+	if path == "" {
+		path = os.Getenv("GOEM_CONFIG")
+	}
+	if path == "" {
+		path, optional = DefaultConfigPath(), true
+	}
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err) && optional:
+		path = ""
+	case err != nil:
+		return nil, err
+	}
+
+	tree, err := toml.LoadBytes(b)
 	if err != nil {
-		return nil
+		return nil, ConfigErrors{positionError(path, "", err)}
+	}
+	l := newLocator(path, tree)
+	errs := unknownKeys(l, tree, reflect.TypeOf(Config{}), "")
+	// the port is a string, but written as a number naturally.
+	if n, ok := tree.Get("server.port").(int64); ok {
+		l.fix("server.port", tree)
+		tree.Set("server.port", strconv.FormatInt(n, 10))
 	}
-	defer file.Close()
 	config := &Config{}
-	if err := toml.NewDecoder(file).Decode(config); err != nil {
-		return nil
+	if err := tree.Unmarshal(config); err != nil {
+		return nil, append(errs, positionError(path, "", err))
 	}
+	config.Path = path
 
-	config.RootDir = shellpath.Resolve(config.RootDir)
-
-	return config
+	for _, o := range envOverrides {
+		if v, ok := os.LookupEnv(o.name); ok {
+			*o.value(config) = v
+			l.env[o.key] = o.name
+		}
+	}
+	config.setDefaults()
+	errs = append(errs, config.validate(l)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return config, nil
 }
 
-func configPath(path string) string {
-	if path != "" {
-		return path
-	}
-	path, err := os.UserConfigDir()
+// DefaultConfigPath returns goem/config.toml in the user configuration
+// directory, or goemd/config.toml read by the old goemd if only it exists.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
 	if err != nil {
 		return filepath.Join(".", "config.toml")
 	}
-	return filepath.Join(path, "goemd", "config.toml")
+	path := filepath.Join(dir, "goem", "config.toml")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		old := filepath.Join(dir, "goemd", "config.toml")
+		if _, err := os.Stat(old); err == nil {
+			return old
+		}
+	}
+	return path
+}
+
+// setDefaults resolves the paths, makes the default account of root_dir
+// and sets the defaults.
+func (c *Config) setDefaults() {
+	if c.RootDir != "" {
+		c.RootDir = shellpath.Resolve(c.RootDir)
+	}
+	for _, p := range []*string{&c.Filter.Script, &c.Server.CertFile, &c.Server.KeyFile} {
+		if *p != "" {
+			*p = shellpath.Resolve(*p)
+		}
+	}
+	if c.Server.Port == "" {
+		c.Server.Port = DefaultPort
+	}
+	if c.Filter.Interval == 0 {
+		c.Filter.Interval = DefaultFilterInterval
+	}
+	if len(c.Accounts) == 0 && c.RootDir != "" {
+		c.Accounts = []Account{{Name: DefaultAccountName, RootDir: c.RootDir}}
+	}
+	for i := range c.Accounts {
+		a := &c.Accounts[i]
+		if a.RootDir != "" {
+			a.RootDir = shellpath.Resolve(a.RootDir)
+		}
+		if a.IMAP != nil && a.IMAP.Name == "" {
+			a.IMAP.Name = a.Name
+		}
+		a.Folders.fill(DefaultFolders)
+	}
+}
+
+func (f *Folders) fill(d Folders) {
+	for _, p := range []struct {
+		v *string
+		d string
+	}{
+		{&f.Inbox, d.Inbox},
+		{&f.Sent, d.Sent},
+		{&f.Drafts, d.Drafts},
+		{&f.Trash, d.Trash},
+		{&f.Archive, d.Archive},
+	} {
+		if *p.v == "" {
+			*p.v = p.d
+		}
+	}
+}
+
+// Account returns the account of the name, or the default account if name
+// is empty.
+func (c *Config) Account(name string) (*Account, error) {
+	if len(c.Accounts) == 0 {
+		return nil, ErrNoAccount
+	}
+	if name == "" {
+		for i := range c.Accounts {
+			if c.Accounts[i].Default {
+				return &c.Accounts[i], nil
+			}
+		}
+		return &c.Accounts[0], nil
+	}
+	for i := range c.Accounts {
+		if c.Accounts[i].Name == name {
+			return &c.Accounts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("account %v doesn't exist", name)
+}
+
+// Addresses returns the addresses of all the identities.
+func (a *Account) Addresses() []string {
+	var ret []string
+	for _, id := range a.Identities {
+		ret = append(ret, id.Addresses...)
+	}
+	return ret
+}
+
+// ConfigureFilter sets the drafts folder and the addresses of the account
+// to the filter. The account may be nil.
+func (c *Config) ConfigureFilter(f *Filter, a *Account) {
+	if a != nil {
+		f.Drafts = a.Folders.Drafts
+		f.Addresses = a.Addresses()
+	}
+	if c.Filter.Drafts != "" {
+		f.Drafts = c.Filter.Drafts
+	}
+}
+
+// Diff returns the values changed from old as "key: old -> new". The
+// tokens and the passwords are not shown.
+func (c *Config) Diff(old *Config) []string {
+	return diff("", reflect.ValueOf(*old), reflect.ValueOf(*c))
+}
+
+func diff(key string, a, b reflect.Value) []string {
+	switch a.Kind() {
+	case reflect.Struct:
+		var ret []string
+		for i := 0; i < a.NumField(); i++ {
+			name := strings.Split(a.Type().Field(i).Tag.Get("toml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if key != "" {
+				name = key + "." + name
+			}
+			ret = append(ret, diff(name, a.Field(i), b.Field(i))...)
+		}
+		return ret
+	case reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return nil
+		case a.IsNil():
+			return []string{key + ": added"}
+		case b.IsNil():
+			return []string{key + ": removed"}
+		}
+		return diff(key, a.Elem(), b.Elem())
+	case reflect.Slice:
+		if a.Type().Elem().Kind() != reflect.Struct {
+			break
+		}
+		var ret []string
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			k := fmt.Sprintf("%v[%v]", key, i)
+			switch {
+			case i >= a.Len():
+				ret = append(ret, k+": added")
+			case i >= b.Len():
+				ret = append(ret, k+": removed")
+			default:
+				ret = append(ret, diff(k, a.Index(i), b.Index(i))...)
+			}
+		}
+		return ret
+	}
+
+	if reflect.DeepEqual(a.Interface(), b.Interface()) {
+		return nil
+	}
+	switch key[strings.LastIndex(key, ".")+1:] {
+	case "token", "password":
+		return []string{key + ": changed"}
+	}
+	return []string{fmt.Sprintf("%v: %v -> %v", key, a.Interface(), b.Interface())}
 }
@@ -0,0 +1,161 @@
+package goem_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+)
+
+// setenv sets the environment variables with the others of goem unset, and
+// returns the function restoring them.
+func setenv(t *testing.T, env map[string]string) func() {
+	t.Helper()
+	saved := map[string]string{}
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GOEM_") {
+			i := strings.Index(kv, "=")
+			saved[kv[:i]] = kv[i+1:]
+			os.Unsetenv(kv[:i])
+		}
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+		for k, v := range saved {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+// writeConfig writes config.toml and the files under a temporary directory.
+// "{{dir}}" in the contents is replaced with the directory.
+func writeConfig(t *testing.T, config string, files map[string]string) (string, string, func()) {
+	t.Helper()
+	dir, cleanup := writeFiles(t, files)
+	path := filepath.Join(dir, "config.toml")
+	config = strings.Replace(config, "{{dir}}", dir, -1)
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return path, dir, cleanup
+}
+
+func Test_LoadConfig(t *testing.T) {
+	files := map[string]string{
+		"mail/.keep": "",
+		"work/.keep": "",
+	}
+	cases := map[string]struct {
+		config string
+		env    map[string]string
+		check  func(dir string, c *goem.Config) (got, want interface{})
+	}{
+		"(valid)default port": {
+			config: `root_dir = "{{dir}}/mail"`,
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				return c.Server.Port, goem.DefaultPort
+			},
+		},
+		"(valid)default filter interval": {
+			config: `root_dir = "{{dir}}/mail"`,
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				return c.Filter.Interval, goem.DefaultFilterInterval
+			},
+		},
+		"(valid)default account": {
+			config: `root_dir = "{{dir}}/mail"`,
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				want := []goem.Account{{
+					Name:    goem.DefaultAccountName,
+					RootDir: filepath.Join(dir, "mail"),
+					Folders: goem.DefaultFolders,
+				}}
+				return c.Accounts, want
+			},
+		},
+		"(valid)integer port": {
+			config: "root_dir = \"{{dir}}/mail\"\n[server]\nport = 8443\n",
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				return c.Server.Port, "8443"
+			},
+		},
+		"(valid)string port": {
+			config: "root_dir = \"{{dir}}/mail\"\n[server]\nport = \"8443\"\n",
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				return c.Server.Port, "8443"
+			},
+		},
+		"(valid)port overridden": {
+			config: "root_dir = \"{{dir}}/mail\"\n[server]\nport = 8443\n",
+			env:    map[string]string{"GOEM_SERVER_PORT": "9000"},
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				return c.Server.Port, "9000"
+			},
+		},
+		"(valid)root_dir overridden": {
+			config: `root_dir = "{{dir}}/mail"`,
+			env:    map[string]string{"GOEM_ROOT_DIR": "{{dir}}/work"},
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				return c.Accounts[0].RootDir, filepath.Join(dir, "work")
+			},
+		},
+		"(valid)accounts": {
+			config: `
+[[accounts]]
+name = "home"
+root_dir = "{{dir}}/mail"
+
+[[accounts]]
+name = "work"
+root_dir = "{{dir}}/work"
+default = true
+[accounts.imap]
+host = "imap.example.com"
+[accounts.folders]
+sent = "Sent Items"
+`,
+			check: func(dir string, c *goem.Config) (interface{}, interface{}) {
+				a, err := c.Account("")
+				if err != nil {
+					return err, nil
+				}
+				return []string{a.Name, a.IMAP.Name, a.Folders.Sent, a.Folders.Inbox}, []string{"work", "work", "Sent Items", "INBOX"}
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			path, dir, cleanup := writeConfig(t, tt.config, files)
+			defer cleanup()
+			env := map[string]string{}
+			for k, v := range tt.env {
+				env[k] = strings.Replace(v, "{{dir}}", dir, -1)
+			}
+			defer setenv(t, env)()
+
+			c, err := goem.LoadConfig(path)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.config, err)
+			}
+			if c.Path != path {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", c.Path, path)
+			}
+			if got, want := tt.check(dir, c); !reflect.DeepEqual(got, want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, want)
+			}
+		})
+	}
+}
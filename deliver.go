@@ -0,0 +1,77 @@
+package goem
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// Deliver delivers the message into new of the maildir named mdName,
+// creating the maildir if it does not exist.
+// The leading From_ line is removed, and the Return-Path and Delivered-To
+// headers are added from the envelope. Return-Path is added only if the
+// sender is known and the message has none. The delivery exceeding the quota is
+// refused with maildir.ErrQuotaExceeded unless IgnoreQuota is set.
+func (r *MaildirRoot) Deliver(mdName string, msg io.Reader, env Envelope) (maildir.Key, error) {
+	br := bufio.NewReader(msg)
+	head, err := br.Peek(br.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return maildir.Key{}, err
+	}
+	if len(head) == 0 {
+		return maildir.Key{}, ErrEmptyMessage
+	}
+	newline := "\n"
+	if i := bytes.IndexByte(head, '\n'); i > 0 && head[i-1] == '\r' {
+		newline = "\r\n"
+	}
+	if bytes.HasPrefix(head, []byte("From ")) {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return maildir.Key{}, ErrEmptyMessage
+		}
+		if env.From == "" {
+			if fields := strings.Fields(line); len(fields) > 1 {
+				env.From = fields[1]
+			}
+		}
+	}
+
+	var header bytes.Buffer
+	head, _ = br.Peek(br.Buffered())
+	if from := env.From; from != "" && !hasHeader(head, "Return-Path") {
+		// MAILER-DAEMON is the null sender of the bounces.
+		if from == "MAILER-DAEMON" {
+			from = ""
+		}
+		fmt.Fprintf(&header, "Return-Path: <%v>%v", from, newline)
+	}
+	if env.To != "" {
+		fmt.Fprintf(&header, "Delivered-To: %v%v", env.To, newline)
+	}
+
+	md, err := maildir.Create(r.maildirPath(mdName))
+	if err != nil {
+		return maildir.Key{}, err
+	}
+	return md.Deliver(io.MultiReader(&header, br), maildir.DeliverOption{EnforceQuota: !r.IgnoreQuota})
+}
+
+// hasHeader reports whether the header in head has the field.
+func hasHeader(head []byte, name string) bool {
+	prefix := strings.ToLower(name) + ":"
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			return false
+		}
+		if strings.HasPrefix(strings.ToLower(line), prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package goem_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+)
+
+func Test_MaildirRoot_Deliver(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		env   goem.Envelope
+		quota maildir.Quota
+		want  string
+		err   error
+	}{
+		"(valid)envelope": {
+			input: "Subject: a\n\nbody\n",
+			env:   goem.Envelope{From: "alice@example.com", To: "bob@example.com"},
+			want:  "Return-Path: <alice@example.com>\nDelivered-To: bob@example.com\nSubject: a\n\nbody\n",
+		},
+		"(valid)no sender": {
+			input: "Subject: a\n\nbody\n",
+			want:  "Subject: a\n\nbody\n",
+		},
+		"(valid)null sender": {
+			input: "Subject: a\n\nbody\n",
+			env:   goem.Envelope{From: "MAILER-DAEMON"},
+			want:  "Return-Path: <>\nSubject: a\n\nbody\n",
+		},
+		"(valid)From_ line": {
+			input: "From alice@example.com Mon Oct  7 12:00:00 2019\nSubject: a\n\nbody\n",
+			want:  "Return-Path: <alice@example.com>\nSubject: a\n\nbody\n",
+		},
+		"(valid)CRLF": {
+			input: "From alice@example.com Mon Oct  7 12:00:00 2019\r\nSubject: a\r\n\r\nbody\r\n",
+			env:   goem.Envelope{To: "bob@example.com"},
+			want:  "Return-Path: <alice@example.com>\r\nDelivered-To: bob@example.com\r\nSubject: a\r\n\r\nbody\r\n",
+		},
+		"(valid)Return-Path kept": {
+			input: "Subject: a\nreturn-path: <carol@example.com>\n\nbody\n",
+			env:   goem.Envelope{From: "alice@example.com"},
+			want:  "Subject: a\nreturn-path: <carol@example.com>\n\nbody\n",
+		},
+		"(valid)Return-Path in body": {
+			input: "Subject: a\n\nReturn-Path: <carol@example.com>\n",
+			env:   goem.Envelope{From: "alice@example.com"},
+			want:  "Return-Path: <alice@example.com>\nSubject: a\n\nReturn-Path: <carol@example.com>\n",
+		},
+		"(invalid)empty": {
+			input: "",
+			err:   goem.ErrEmptyMessage,
+		},
+		"(invalid)only From_ line": {
+			input: "From alice@example.com Mon Oct  7 12:00:00 2019",
+			err:   goem.ErrEmptyMessage,
+		},
+		"(invalid)quota exceeded": {
+			input: "Subject: a\n\nbody\n",
+			quota: maildir.Quota{Bytes: 10},
+			err:   maildir.ErrQuotaExceeded,
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			root, cleanup := writeFiles(t, nil)
+			defer cleanup()
+			md, err := maildir.Create(filepath.Join(root, "INBOX"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := md.SetQuota(tt.quota); err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := goem.NewMaildirRoot(root).Deliver("INBOX", strings.NewReader(tt.input), tt.env)
+			if err != tt.err {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			f, err := md.Open(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got, tt.want)
+			}
+		})
+	}
+}
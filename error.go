@@ -0,0 +1,99 @@
+package goem
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// ErrorCode is the kind of the errors of MaildirRoot.
+type ErrorCode string
+
+// Error codes.
+const (
+	// CodeNotFound is for the maildir or the mail which doesn't exist.
+	CodeNotFound ErrorCode = "not_found"
+	// CodeNotMaildir is for the directory which is not a maildir.
+	CodeNotMaildir ErrorCode = "not_maildir"
+	// CodeInvalidKey is for the key which cannot be parsed.
+	CodeInvalidKey ErrorCode = "invalid_key"
+	// CodeUnknownSubDir is for the sub directory other than new and cur.
+	CodeUnknownSubDir ErrorCode = "unknown_sub_dir"
+	// CodePermissionDenied is for the files which cannot be accessed.
+	CodePermissionDenied ErrorCode = "permission_denied"
+	// CodeInternal is for the other errors.
+	CodeInternal ErrorCode = "internal"
+)
+
+// Error is the error of MaildirRoot with its kind.
+type Error struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Code returns the kind of err. The errors of the maildir package and os
+// are classified too, and the others are CodeInternal.
+func Code(err error) ErrorCode {
+	switch e := err.(type) {
+	case *Error:
+		return e.Code
+	case *os.PathError:
+		if e.Err == maildir.ErrNotMaildir {
+			return CodeNotMaildir
+		}
+	}
+	switch {
+	case err == maildir.ErrCannotParse:
+		return CodeInvalidKey
+	case err == maildir.ErrUnknownSubDir:
+		return CodeUnknownSubDir
+	case os.IsNotExist(err):
+		return CodeNotFound
+	case os.IsPermission(err):
+		return CodePermissionDenied
+	}
+	return CodeInternal
+}
+
+// openMaildir opens the maildir of the name under the root. The error
+// message has the name instead of the path, which is shown to the clients.
+func (r *MaildirRoot) openMaildir(mdName string) (*maildir.Maildir, error) {
+	md, err := maildir.Open(r.maildirPath(mdName))
+	if err == nil {
+		return md, nil
+	}
+	switch code := Code(err); code {
+	case CodeNotFound:
+		return nil, &Error{Code: code, Err: fmt.Errorf("%v doesn't exist", mdName)}
+	case CodeNotMaildir:
+		return nil, &Error{Code: code, Err: fmt.Errorf("%v is not maildir", mdName)}
+	case CodePermissionDenied:
+		return nil, &Error{Code: code, Err: fmt.Errorf("%v: permission denied", mdName)}
+	}
+	return nil, err
+}
+
+// parseKey parses the key given by the user.
+func parseKey(key string) (maildir.Key, error) {
+	k, err := maildir.ParseKey(key)
+	if err != nil {
+		return maildir.Key{}, &Error{Code: CodeInvalidKey, Err: fmt.Errorf("invalid key: %v", key)}
+	}
+	return k, nil
+}
+
+// mailError classifies the error reading the mail of the key.
+func mailError(mdName, key string, err error) error {
+	switch code := Code(err); code {
+	case CodeNotFound:
+		return &Error{Code: code, Err: fmt.Errorf("%v doesn't exist in %v", key, mdName)}
+	case CodePermissionDenied:
+		return &Error{Code: code, Err: fmt.Errorf("%v in %v: permission denied", key, mdName)}
+	}
+	return err
+}
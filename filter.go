@@ -0,0 +1,358 @@
+package goem
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tennashi/goem/imap"
+	goemmail "github.com/tennashi/goem/mail"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/sieve"
+)
+
+// DefaultDrafts is the maildir name the vacation replies are stored in.
+const DefaultDrafts = "Drafts"
+
+// vacationFile records the senders already replied to in the drafts maildir.
+const vacationFile = ".goem-vacation"
+
+// Envelope is the envelope of the delivered message.
+type Envelope struct {
+	From string
+	To   string
+}
+
+// Filter applies the sieve script to the messages delivered into new.
+// Kept messages are moved into cur so that they are filtered only once.
+type Filter struct {
+	root   *MaildirRoot
+	script *sieve.Script
+	// Drafts is the maildir name the vacation replies are stored in.
+	Drafts string
+	// Addresses are the addresses of the user, which the vacation replies
+	// are sent for in addition to the :addresses of the script.
+	Addresses []string
+}
+
+// NewFilter is ...
+func NewFilter(root *MaildirRoot, script *sieve.Script) *Filter {
+	return &Filter{
+		root:   root,
+		script: script,
+		Drafts: DefaultDrafts,
+	}
+}
+
+// LoadFilter parses the sieve script file.
+func LoadFilter(root *MaildirRoot, path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	s, err := sieve.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return NewFilter(root, s), nil
+}
+
+// FilterNew applies the script to the messages in new of the maildir.
+// It returns the number of the filtered messages. The message the script
+// fails on is moved into cur untouched so that it is not filtered again.
+func (f *Filter) FilterNew(mdName string) (int, error) {
+	md, err := f.root.openMaildir(mdName)
+	if err != nil {
+		return 0, err
+	}
+	keys, err := md.Keys(maildir.SubDirNew)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, k := range keys {
+		if err := f.Apply(mdName, k, nil); err != nil {
+			log.Printf("filter %v/%v: %v", mdName, k, err)
+			if _, err := md.SetFlags(k, k.Flags); err != nil && !os.IsNotExist(err) {
+				log.Printf("filter %v/%v: %v", mdName, k, err)
+			}
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Watch filters the messages arriving in new of the maildirs every interval until ctx is done.
+func (f *Filter) Watch(ctx context.Context, mdNames []string, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for _, name := range mdNames {
+			if _, err := f.FilterNew(name); err != nil {
+				log.Printf("filter %v: %v", name, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Apply applies the script to the message in the maildir.
+// The envelope is taken from the Return-Path and Delivered-To headers if env is nil.
+func (f *Filter) Apply(mdName string, key maildir.Key, env *Envelope) error {
+	md, err := maildir.New(f.root.maildirPath(mdName))
+	if err != nil {
+		return err
+	}
+	file, err := md.Open(key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	msg, err := mail.ReadMessage(file)
+	if err != nil {
+		return err
+	}
+	if env == nil {
+		env = headerEnvelope(msg.Header)
+	}
+
+	actions, err := f.script.Execute(&sieve.Message{
+		Header: msg.Header,
+		Size:   info.Size(),
+		From:   env.From,
+		To:     env.To,
+	})
+	if err != nil {
+		return err
+	}
+
+	keep := false
+	var flags []string
+	for _, a := range actions {
+		switch a.Type {
+		case sieve.ActionKeep:
+			keep = true
+			flags = imap.MaildirFlags(a.Flags)
+		case sieve.ActionFileInto:
+			if a.Mailbox == mdName {
+				keep = true
+				flags = imap.MaildirFlags(a.Flags)
+				continue
+			}
+			path := f.root.maildirPath(a.Mailbox)
+			if !a.Create && !maildir.IsMaildir(path) {
+				// the message is kept instead as the mailbox extension requires.
+				log.Printf("filter: fileinto %v: the maildir doesn't exist without :create", a.Mailbox)
+				keep = true
+				flags = imap.MaildirFlags(a.Flags)
+				continue
+			}
+			opt := maildir.DeliverOption{SubDir: maildir.SubDirCur, Flags: imap.MaildirFlags(a.Flags)}
+			if err := f.copyTo(path, file, opt); err != nil {
+				return err
+			}
+		case sieve.ActionRedirect:
+			path := a.Address
+			if !filepath.IsAbs(path) {
+				path = f.root.maildirPath(path)
+			}
+			if abs, err := filepath.Abs(path); err == nil && abs == md.Path {
+				// redirected to itself, the message stays as it is.
+				if !keep {
+					keep = true
+					flags = key.Flags
+				}
+				continue
+			}
+			if err := f.copyTo(path, file, maildir.DeliverOption{}); err != nil {
+				return err
+			}
+		case sieve.ActionVacation:
+			if err := f.vacation(msg.Header, env, a.Vacation); err != nil {
+				return err
+			}
+		}
+	}
+
+	if keep {
+		_, err := md.SetFlags(key, flags)
+		return err
+	}
+	return md.Remove(key)
+}
+
+func (f *Filter) copyTo(path string, file *os.File, opt maildir.DeliverOption) error {
+	md, err := maildir.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = md.Deliver(file, opt)
+	return err
+}
+
+func headerEnvelope(h mail.Header) *Envelope {
+	env := &Envelope{
+		From: strings.Trim(strings.TrimSpace(h.Get("Return-Path")), "<>"),
+		To:   h.Get("Delivered-To"),
+	}
+	if env.To == "" {
+		env.To = h.Get("X-Original-To")
+	}
+	env.To = strings.TrimSpace(env.To)
+	return env
+}
+
+// vacation stores the reply into the drafts maildir instead of sending it.
+func (f *Filter) vacation(h mail.Header, env *Envelope, v *sieve.Vacation) error {
+	sender := env.From
+	if sender == "" || strings.HasPrefix(strings.ToUpper(sender), "MAILER-DAEMON") {
+		return nil
+	}
+	if as := strings.ToLower(h.Get("Auto-Submitted")); as != "" && as != "no" {
+		return nil
+	}
+	switch strings.ToLower(h.Get("Precedence")) {
+	case "bulk", "list", "junk":
+		return nil
+	}
+	if h.Get("List-Id") != "" {
+		return nil
+	}
+	own := append([]string{env.To}, v.Addresses...)
+	if !addressedTo(h, append(own, f.Addresses...)) {
+		return nil
+	}
+
+	md, err := maildir.Create(f.root.maildirPath(f.Drafts))
+	if err != nil {
+		return err
+	}
+	handle := v.Handle
+	if handle == "" {
+		handle = fmt.Sprintf("%x", sha1.Sum([]byte(v.Subject+"\x00"+v.Reason)))
+	}
+	statePath := filepath.Join(md.Path, vacationFile)
+	replied, err := vacationReplied(statePath, handle, sender, time.Duration(v.Days)*24*time.Hour)
+	if err != nil || replied {
+		return err
+	}
+
+	from := v.From
+	if from == "" {
+		from = env.To
+	}
+	subject := v.Subject
+	if subject == "" {
+		subject = "Auto: " + goemmail.Header(h).Get("Subject")
+	}
+	var b bytes.Buffer
+	if from != "" {
+		fmt.Fprintf(&b, "From: %v\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %v\r\n", sender)
+	fmt.Fprintf(&b, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	if id := h.Get("Message-Id"); id != "" {
+		fmt.Fprintf(&b, "In-Reply-To: %v\r\n", id)
+		fmt.Fprintf(&b, "References: %v\r\n", strings.TrimSpace(h.Get("References")+" "+id))
+	}
+	b.WriteString("Auto-Submitted: auto-replied\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if !v.MIME {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	}
+	b.WriteString(v.Reason)
+
+	opt := maildir.DeliverOption{SubDir: maildir.SubDirCur, Flags: []string{maildir.FlagDraft}}
+	if _, err := md.Deliver(&b, opt); err != nil {
+		return err
+	}
+	return recordVacation(statePath, handle, sender)
+}
+
+func addressedTo(h mail.Header, addrs []string) bool {
+	var own []string
+	for _, a := range addrs {
+		if a != "" {
+			own = append(own, strings.ToLower(a))
+		}
+	}
+	if len(own) == 0 {
+		return true
+	}
+	for _, name := range []string{"To", "Cc", "Bcc", "Resent-To", "Resent-Cc"} {
+		list, err := h.AddressList(name)
+		if err != nil {
+			continue
+		}
+		for _, a := range list {
+			for _, o := range own {
+				if strings.ToLower(a.Address) == o {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func vacationReplied(path, handle, sender string, period time.Duration) (bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || fields[0] != handle || !strings.EqualFold(fields[1], sender) {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		if time.Since(time.Unix(sec, 0)) < period {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func recordVacation(path, handle, sender string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(file, "%v\t%v\t%v\n", handle, sender, time.Now().Unix()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
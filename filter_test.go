@@ -0,0 +1,115 @@
+package goem_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/sieve"
+)
+
+// count returns the number of the messages in new and cur of the maildir.
+func count(t *testing.T, path string) [2]int {
+	t.Helper()
+	if !maildir.IsMaildir(path) {
+		return [2]int{-1, -1}
+	}
+	md, err := maildir.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [2]int
+	for i, sd := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		keys, err := md.Keys(sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[i] = len(keys)
+	}
+	return got
+}
+
+func Test_Filter_FilterNew(t *testing.T) {
+	cases := map[string]struct {
+		script string
+		// want are the numbers of the messages in new and cur of the
+		// maildirs, -1 if the maildir doesn't exist.
+		want map[string][2]int
+	}{
+		"(valid)fileinto existing": {
+			script: `require "fileinto"; fileinto "Lists";`,
+			want: map[string][2]int{
+				"INBOX": {0, 1},
+				"Lists": {0, 2},
+				"New":   {-1, -1},
+			},
+		},
+		"(valid)fileinto without create": {
+			script: `require "fileinto"; fileinto "New";`,
+			want: map[string][2]int{
+				"INBOX": {0, 3},
+				"Lists": {0, 0},
+				"New":   {-1, -1},
+			},
+		},
+		"(valid)fileinto with create": {
+			script: `require ["fileinto", "mailbox"]; fileinto :create "New";`,
+			want: map[string][2]int{
+				"INBOX": {0, 1},
+				"Lists": {0, 0},
+				"New":   {0, 2},
+			},
+		},
+		"(valid)redirect to itself": {
+			script: `redirect "INBOX";`,
+			want: map[string][2]int{
+				"INBOX": {0, 3},
+				"Lists": {0, 0},
+				"New":   {-1, -1},
+			},
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			root, cleanup := writeFiles(t, nil)
+			defer cleanup()
+			for _, name := range []string{"INBOX", "Lists"} {
+				if _, err := maildir.Create(filepath.Join(root, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			md, err := maildir.New(filepath.Join(root, "INBOX"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the broken message in the middle doesn't stop the others.
+			for _, m := range []string{"Subject: 1\n\nbody\n", "broken header\n\nbody\n", "Subject: 3\n\nbody\n"} {
+				if _, err := md.Deliver(strings.NewReader(m), maildir.DeliverOption{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s, err := sieve.Parse(strings.NewReader(tt.script))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := goem.NewFilter(goem.NewMaildirRoot(root), s).FilterNew("INBOX")
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			if n != 2 {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", n, 2)
+			}
+			got := map[string][2]int{}
+			for name := range tt.want {
+				got[name] = count(t, filepath.Join(root, name))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
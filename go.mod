@@ -6,6 +6,8 @@ require (
 	github.com/go-chi/chi v4.0.2+incompatible
 	github.com/pelletier/go-toml v1.4.0
 	github.com/urfave/cli v1.21.0
+	golang.org/x/crypto v0.0.0-20190911031432-227b76d455e7
+	golang.org/x/net v0.0.0-20190923162816-aa69164e4478
 	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
 	golang.org/x/text v0.3.2
 )
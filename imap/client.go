@@ -0,0 +1,380 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// internalDateLayout is the format of INTERNALDATE.
+const internalDateLayout = "_2-Jan-2006 15:04:05 -0700"
+
+// Error is the NO or BAD response from the server.
+type Error struct {
+	Status string
+	Text   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("imap: %v %v", e.Status, e.Text)
+}
+
+// Client is the IMAP4rev1 client.
+type Client struct {
+	conn net.Conn
+	r    *reader
+	w    *bufio.Writer
+	seq  int
+	caps map[string]bool
+}
+
+// Dial connects to the server, with TLS if tlsConfig is not nil.
+func Dial(ctx context.Context, addr string, tlsConfig *tls.Config) (*Client, error) {
+	d := &net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient reads the greeting from the connection.
+func NewClient(conn net.Conn) (*Client, error) {
+	c := &Client{conn: conn}
+	c.setConn(conn)
+	res, err := c.r.readResponse()
+	if err != nil {
+		return nil, err
+	}
+	if res.tag != "*" || res.status == "BYE" {
+		return nil, &Error{Status: res.status, Text: res.text}
+	}
+	c.updateCapability(res.code)
+	return c, nil
+}
+
+func (c *Client) setConn(conn net.Conn) {
+	c.conn = conn
+	c.r = &reader{br: bufio.NewReader(conn)}
+	c.w = bufio.NewWriter(conn)
+}
+
+// Close closes the connection without LOGOUT.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) execute(cmd string) ([]*response, error) {
+	c.seq++
+	tag := fmt.Sprintf("A%04d", c.seq)
+	if _, err := fmt.Fprintf(c.w, "%v %v\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	var untagged []*response
+	for {
+		res, err := c.r.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		switch res.tag {
+		case tag:
+			c.updateCapability(res.code)
+			if res.status != "OK" {
+				return untagged, &Error{Status: res.status, Text: res.text}
+			}
+			return untagged, nil
+		case "*":
+			if res.status == "BYE" && !strings.HasPrefix(strings.ToUpper(cmd), "LOGOUT") {
+				return nil, &Error{Status: res.status, Text: res.text}
+			}
+			untagged = append(untagged, res)
+		}
+	}
+}
+
+func (c *Client) updateCapability(code []interface{}) {
+	if !strings.EqualFold(fieldString(code, 0), "CAPABILITY") {
+		return
+	}
+	c.caps = map[string]bool{}
+	for _, v := range code[1:] {
+		if s, ok := v.(string); ok {
+			c.caps[strings.ToUpper(s)] = true
+		}
+	}
+}
+
+// Capability asks the server for the capabilities.
+func (c *Client) Capability() (map[string]bool, error) {
+	res, err := c.execute("CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+	c.caps = map[string]bool{}
+	for _, r := range res {
+		if !strings.EqualFold(fieldString(r.fields, 0), "CAPABILITY") {
+			continue
+		}
+		for _, v := range r.fields[1:] {
+			c.caps[strings.ToUpper(v.(string))] = true
+		}
+	}
+	return c.caps, nil
+}
+
+// Has reports whether the server has the capability.
+func (c *Client) Has(capability string) bool {
+	if c.caps == nil {
+		if _, err := c.Capability(); err != nil {
+			return false
+		}
+	}
+	return c.caps[strings.ToUpper(capability)]
+}
+
+// StartTLS upgrades the connection to TLS.
+func (c *Client) StartTLS(tlsConfig *tls.Config) error {
+	if _, err := c.execute("STARTTLS"); err != nil {
+		return err
+	}
+	c.setConn(tls.Client(c.conn, tlsConfig))
+	c.caps = nil
+	return nil
+}
+
+// Login authenticates with the user name and the password.
+func (c *Client) Login(user, password string) error {
+	if strings.ContainsAny(user+password, "\r\n") {
+		return errors.New("imap: user name and password cannot contain line breaks")
+	}
+	if _, err := c.execute("LOGIN " + quote(user) + " " + quote(password)); err != nil {
+		return err
+	}
+	c.caps = nil
+	return nil
+}
+
+// Logout closes the session and the connection.
+func (c *Client) Logout() error {
+	_, err := c.execute("LOGOUT")
+	c.conn.Close()
+	return err
+}
+
+// MailboxInfo is the mailbox returned by LIST.
+type MailboxInfo struct {
+	Name       string
+	Delimiter  string
+	Attributes []string
+}
+
+// List returns the mailboxes matching the pattern.
+func (c *Client) List(ref, pattern string) ([]MailboxInfo, error) {
+	res, err := c.execute("LIST " + quote(EncodeMailboxName(ref)) + " " + quote(EncodeMailboxName(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	var mbs []MailboxInfo
+	for _, r := range res {
+		if !strings.EqualFold(fieldString(r.fields, 0), "LIST") || len(r.fields) < 4 {
+			continue
+		}
+		name, err := DecodeMailboxName(fieldString(r.fields, 3))
+		if err != nil {
+			return nil, err
+		}
+		mb := MailboxInfo{
+			Name:      name,
+			Delimiter: fieldString(r.fields, 2),
+		}
+		attrs, _ := r.fields[1].([]interface{})
+		for _, a := range attrs {
+			if s, ok := a.(string); ok {
+				mb.Attributes = append(mb.Attributes, s)
+			}
+		}
+		mbs = append(mbs, mb)
+	}
+	return mbs, nil
+}
+
+// MailboxStatus is the status of the selected mailbox.
+type MailboxStatus struct {
+	Name          string
+	Exists        uint32
+	UIDValidity   uint32
+	UIDNext       uint32
+	HighestModSeq uint64
+	Flags         []string
+}
+
+// Select selects the mailbox, enabling CONDSTORE if condstore is true.
+func (c *Client) Select(name string, condstore bool) (*MailboxStatus, error) {
+	cmd := "SELECT " + quote(EncodeMailboxName(name))
+	if condstore {
+		cmd += " (CONDSTORE)"
+	}
+	res, err := c.execute(cmd)
+	if err != nil {
+		return nil, err
+	}
+	st := &MailboxStatus{Name: name}
+	for _, r := range res {
+		switch strings.ToUpper(fieldString(r.code, 0)) {
+		case "UIDVALIDITY":
+			st.UIDValidity = uint32(parseNumber(fieldString(r.code, 1)))
+		case "UIDNEXT":
+			st.UIDNext = uint32(parseNumber(fieldString(r.code, 1)))
+		case "HIGHESTMODSEQ":
+			st.HighestModSeq = parseNumber(fieldString(r.code, 1))
+		}
+		switch {
+		case strings.EqualFold(fieldString(r.fields, 1), "EXISTS"):
+			st.Exists = uint32(parseNumber(fieldString(r.fields, 0)))
+		case strings.EqualFold(fieldString(r.fields, 0), "FLAGS") && len(r.fields) > 1:
+			st.Flags = stringList(r.fields[1])
+		}
+	}
+	return st, nil
+}
+
+// UIDSearchAll returns the UIDs of all messages in the selected mailbox.
+func (c *Client) UIDSearchAll() ([]uint32, error) {
+	res, err := c.execute("UID SEARCH ALL")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range res {
+		if !strings.EqualFold(fieldString(r.fields, 0), "SEARCH") {
+			continue
+		}
+		for _, v := range r.fields[1:] {
+			s, _ := v.(string)
+			if isNumber(s) {
+				uids = append(uids, uint32(parseNumber(s)))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// Message is the message returned by FETCH.
+type Message struct {
+	UID          uint32
+	Flags        []string
+	ModSeq       uint64
+	InternalDate time.Time
+	Body         []byte
+}
+
+// UIDFetchFlags returns the flags of all messages, or of the messages changed
+// since the mod-sequence if changedSince is not zero.
+func (c *Client) UIDFetchFlags(changedSince uint64) ([]*Message, error) {
+	cmd := "UID FETCH 1:* (UID FLAGS)"
+	if changedSince > 0 {
+		cmd = fmt.Sprintf("UID FETCH 1:* (UID FLAGS MODSEQ) (CHANGEDSINCE %v)", changedSince)
+	}
+	return c.fetch(cmd)
+}
+
+// UIDFetchMessage returns the message with its flags and body.
+// The message is not marked as seen.
+func (c *Client) UIDFetchMessage(uid uint32) (*Message, error) {
+	ms, err := c.fetch(fmt.Sprintf("UID FETCH %v (UID FLAGS INTERNALDATE BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range ms {
+		if m.UID == uid {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("imap: message %v not found", uid)
+}
+
+func (c *Client) fetch(cmd string) ([]*Message, error) {
+	res, err := c.execute(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*Message
+	for _, r := range res {
+		if !strings.EqualFold(fieldString(r.fields, 1), "FETCH") || len(r.fields) < 3 {
+			continue
+		}
+		items, _ := r.fields[2].([]interface{})
+		m := &Message{}
+		for i := 0; i+1 < len(items); i += 2 {
+			name, _ := items[i].(string)
+			switch strings.ToUpper(name) {
+			case "UID":
+				s, _ := items[i+1].(string)
+				m.UID = uint32(parseNumber(s))
+			case "FLAGS":
+				m.Flags = stringList(items[i+1])
+			case "MODSEQ":
+				if l := stringList(items[i+1]); len(l) > 0 {
+					m.ModSeq = parseNumber(l[0])
+				}
+			case "INTERNALDATE":
+				s, _ := items[i+1].(string)
+				m.InternalDate, _ = time.Parse(internalDateLayout, s)
+			case "BODY[]":
+				s, _ := items[i+1].(string)
+				m.Body = []byte(s)
+			}
+		}
+		if m.UID != 0 {
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// UIDStore adds the flags to the message, or removes them if add is false.
+func (c *Client) UIDStore(uid uint32, add bool, flags []string) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	op := "+FLAGS.SILENT"
+	if !add {
+		op = "-FLAGS.SILENT"
+	}
+	_, err := c.execute(fmt.Sprintf("UID STORE %v %v (%v)", uid, op, strings.Join(flags, " ")))
+	return err
+}
+
+func parseNumber(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var strs []string
+	for _, e := range list {
+		if s, ok := e.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,72 @@
+package imap_test
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/tennashi/goem/imap"
+)
+
+// serve answers the first command with the response lines and the tagged OK.
+func serve(t *testing.T, conn net.Conn, lines string) {
+	t.Helper()
+	go func() {
+		defer conn.Close()
+		if _, err := conn.Write([]byte("* OK ready\r\n")); err != nil {
+			return
+		}
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+			return
+		}
+		conn.Write([]byte(lines + "A0001 OK done\r\n"))
+	}()
+}
+
+func Test_Client_List_literal(t *testing.T) {
+	cases := map[string]struct {
+		lines string
+		want  []string
+		err   error
+	}{
+		"(valid)literal": {
+			lines: "* LIST () \"/\" {5}\r\nINBOX\r\n",
+			want:  []string{"INBOX"},
+			err:   nil,
+		},
+		"(invalid)too large": {
+			lines: "* LIST () \"/\" {1099511627776}\r\nINBOX\r\n",
+			want:  nil,
+			err:   imap.ErrLiteralTooLarge,
+		},
+		"(invalid)negative": {
+			lines: "* LIST () \"/\" {-1}\r\nINBOX\r\n",
+			want:  nil,
+			err:   imap.ErrMalformedResponse,
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			client, server := net.Pipe()
+			serve(t, server, tt.lines)
+			c, err := imap.NewClient(client)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			defer c.Close()
+
+			infos, err := c.List("", "*")
+			if err != tt.err {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", err, tt.err)
+			}
+			var got []string
+			for _, info := range infos {
+				got = append(got, info.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package imap
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// System flags.
+const (
+	FlagSeen     = `\Seen`
+	FlagAnswered = `\Answered`
+	FlagFlagged  = `\Flagged`
+	FlagDeleted  = `\Deleted`
+	FlagDraft    = `\Draft`
+	FlagRecent   = `\Recent`
+)
+
+var maildirFlags = map[string]string{
+	`\seen`:     maildir.FlagSeen,
+	`\answered`: maildir.FlagReplied,
+	`\flagged`:  maildir.FlagFlagged,
+	`\deleted`:  maildir.FlagTrashed,
+	`\draft`:    maildir.FlagDraft,
+}
+
+var systemFlags = map[string]string{
+	maildir.FlagSeen:    FlagSeen,
+	maildir.FlagReplied: FlagAnswered,
+	maildir.FlagFlagged: FlagFlagged,
+	maildir.FlagTrashed: FlagDeleted,
+	maildir.FlagDraft:   FlagDraft,
+}
+
+// MaildirFlags converts the system flags to the maildir flags.
+// Keywords and \Recent are dropped because maildir cannot store them.
+func MaildirFlags(flags []string) []string {
+	var ret []string
+	for _, f := range flags {
+		if mf, ok := maildirFlags[strings.ToLower(f)]; ok {
+			ret = append(ret, mf)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// SystemFlags converts the maildir flags to the system flags.
+// The passed flag is dropped because IMAP has no equivalent.
+func SystemFlags(flags []string) []string {
+	var ret []string
+	for _, f := range flags {
+		if sf, ok := systemFlags[f]; ok {
+			ret = append(ret, sf)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
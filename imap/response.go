@@ -0,0 +1,329 @@
+package imap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedResponse is returned when the server response cannot be parsed.
+var ErrMalformedResponse = errors.New("malformed response")
+
+// ErrLiteralTooLarge is returned when the server sends the literal larger
+// than MaxLiteralSize.
+var ErrLiteralTooLarge = errors.New("literal too large")
+
+// MaxLiteralSize is the largest literal read from the server, which is
+// enough for the messages accepted by the usual servers.
+const MaxLiteralSize = 256 << 20
+
+// response is the server response.
+// Fields are string for atoms, quoted strings and literals, []interface{}
+// for parenthesized lists and nil for NIL.
+type response struct {
+	tag    string
+	fields []interface{}
+	status string
+	code   []interface{}
+	text   string
+}
+
+var statusWords = map[string]bool{
+	"OK":      true,
+	"NO":      true,
+	"BAD":     true,
+	"BYE":     true,
+	"PREAUTH": true,
+}
+
+type reader struct {
+	br *bufio.Reader
+}
+
+func (r *reader) readResponse() (*response, error) {
+	tag, err := r.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	res := &response{tag: tag}
+	if tag == "+" {
+		res.text, err = r.readText()
+		return res, err
+	}
+	if err := r.expect(' '); err != nil {
+		return nil, err
+	}
+
+	first, err := r.readValue()
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := first.(string); ok && statusWords[strings.ToUpper(s)] {
+		res.status = strings.ToUpper(s)
+		if err := r.readStatusText(res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	res.fields = []interface{}{first}
+	if s, ok := first.(string); ok && isNumber(s) {
+		// "* 5 EXISTS" or "* 5 FETCH (...)"
+		if err := r.expect(' '); err != nil {
+			return nil, err
+		}
+		name, err := r.readAtom()
+		if err != nil {
+			return nil, err
+		}
+		res.fields = append(res.fields, name)
+	}
+	if strings.EqualFold(fieldString(res.fields, len(res.fields)-1), "CAPABILITY") ||
+		strings.EqualFold(fieldString(res.fields, len(res.fields)-1), "SEARCH") {
+		text, err := r.readText()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range strings.Fields(text) {
+			res.fields = append(res.fields, f)
+		}
+		return res, nil
+	}
+	for {
+		c, err := r.br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case '\r':
+			return res, r.expect('\n')
+		case '\n':
+			return res, nil
+		case ' ':
+		default:
+			return nil, ErrMalformedResponse
+		}
+		if b, err := r.br.Peek(1); err == nil && (b[0] == '\r' || b[0] == '\n') {
+			continue
+		}
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		res.fields = append(res.fields, v)
+	}
+}
+
+func (r *reader) readStatusText(res *response) error {
+	b, err := r.br.Peek(2)
+	if err == nil && b[0] == ' ' && b[1] == '[' {
+		r.br.Discard(2)
+		var depth int
+		var code strings.Builder
+		for {
+			c, err := r.br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if c == '[' {
+				depth++
+			}
+			if c == ']' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+			code.WriteByte(c)
+		}
+		sub := &reader{br: bufio.NewReader(strings.NewReader(code.String() + "\r\n"))}
+		res.code, err = sub.readList('\r')
+		if err != nil {
+			return err
+		}
+	}
+	res.text, err = r.readText()
+	return err
+}
+
+// readList reads the values separated by spaces up to the terminator.
+func (r *reader) readList(end byte) ([]interface{}, error) {
+	var list []interface{}
+	for {
+		b, err := r.br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == end {
+			r.br.Discard(1)
+			return list, nil
+		}
+		if b[0] == ' ' {
+			r.br.Discard(1)
+			continue
+		}
+		v, err := r.readValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+}
+
+func (r *reader) readValue() (interface{}, error) {
+	b, err := r.br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch b[0] {
+	case '(':
+		r.br.Discard(1)
+		list, err := r.readList(')')
+		if err != nil {
+			return nil, err
+		}
+		if list == nil {
+			list = []interface{}{}
+		}
+		return list, nil
+	case '"':
+		return r.readQuoted()
+	case '{':
+		return r.readLiteral()
+	}
+	atom, err := r.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(atom, "NIL") {
+		return nil, nil
+	}
+	return atom, nil
+}
+
+func (r *reader) readAtom() (string, error) {
+	var b strings.Builder
+	depth := 0
+	for {
+		c, err := r.br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if depth == 0 && (c == ' ' || c == '(' || c == ')' || c == '\r' || c == '\n') {
+			r.br.UnreadByte()
+			if b.Len() == 0 {
+				return "", ErrMalformedResponse
+			}
+			return b.String(), nil
+		}
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		b.WriteByte(c)
+	}
+}
+
+func (r *reader) readQuoted() (string, error) {
+	r.br.Discard(1)
+	var b strings.Builder
+	for {
+		c, err := r.br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			c, err = r.br.ReadByte()
+			if err != nil {
+				return "", err
+			}
+		case '\r', '\n':
+			return "", ErrMalformedResponse
+		}
+		b.WriteByte(c)
+	}
+}
+
+func (r *reader) readLiteral() (string, error) {
+	r.br.Discard(1)
+	s, err := r.br.ReadString('}')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(s, "}"), 10, 64)
+	if err != nil || n < 0 {
+		return "", ErrMalformedResponse
+	}
+	if n > MaxLiteralSize {
+		return "", ErrLiteralTooLarge
+	}
+	if err := r.expect('\r'); err != nil {
+		return "", err
+	}
+	if err := r.expect('\n'); err != nil {
+		return "", err
+	}
+	// the buffer grows as the literal arrives rather than trusting n.
+	var b strings.Builder
+	if _, err := io.CopyN(&b, r.br, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (r *reader) readText() (string, error) {
+	s, err := r.br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+func (r *reader) expect(c byte) error {
+	got, err := r.br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if got != c {
+		return fmt.Errorf("%v: expected %q but %q", ErrMalformedResponse, c, got)
+	}
+	return nil
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || '9' < c {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldString(fields []interface{}, i int) string {
+	if i < 0 || i >= len(fields) {
+		return ""
+	}
+	s, _ := fields[i].(string)
+	return s
+}
+
+// quote returns the quoted string for the command argument.
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
@@ -0,0 +1,82 @@
+package imap
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrInvalidUTF7 is returned when the mailbox name is not valid modified UTF-7.
+var ErrInvalidUTF7 = errors.New("invalid modified UTF-7")
+
+var utf7Encoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+,").WithPadding(base64.NoPadding)
+
+// EncodeMailboxName encodes the mailbox name into modified UTF-7 of RFC 3501.
+func EncodeMailboxName(name string) string {
+	var b strings.Builder
+	var pending []rune
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		u := utf16.Encode(pending)
+		buf := make([]byte, len(u)*2)
+		for i, c := range u {
+			buf[i*2] = byte(c >> 8)
+			buf[i*2+1] = byte(c)
+		}
+		b.WriteByte('&')
+		b.WriteString(utf7Encoding.EncodeToString(buf))
+		b.WriteByte('-')
+		pending = nil
+	}
+	for _, r := range name {
+		switch {
+		case r == '&':
+			flush()
+			b.WriteString("&-")
+		case 0x20 <= r && r <= 0x7e:
+			flush()
+			b.WriteRune(r)
+		default:
+			pending = append(pending, r)
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// DecodeMailboxName decodes the mailbox name in modified UTF-7 of RFC 3501.
+func DecodeMailboxName(name string) (string, error) {
+	var b strings.Builder
+	for len(name) > 0 {
+		i := strings.IndexByte(name, '&')
+		if i < 0 {
+			b.WriteString(name)
+			break
+		}
+		b.WriteString(name[:i])
+		name = name[i+1:]
+		end := strings.IndexByte(name, '-')
+		if end < 0 {
+			return "", ErrInvalidUTF7
+		}
+		if end == 0 {
+			b.WriteByte('&')
+			name = name[1:]
+			continue
+		}
+		buf, err := utf7Encoding.DecodeString(name[:end])
+		if err != nil || len(buf)%2 != 0 {
+			return "", ErrInvalidUTF7
+		}
+		u := make([]uint16, len(buf)/2)
+		for j := range u {
+			u[j] = uint16(buf[j*2])<<8 | uint16(buf[j*2+1])
+		}
+		b.WriteString(string(utf16.Decode(u)))
+		name = name[end+1:]
+	}
+	return b.String(), nil
+}
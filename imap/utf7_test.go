@@ -0,0 +1,45 @@
+package imap_test
+
+import (
+	"testing"
+
+	"github.com/tennashi/goem/imap"
+)
+
+func Test_MailboxName(t *testing.T) {
+	cases := map[string]struct {
+		decoded string
+		encoded string
+	}{
+		"(valid)ascii": {
+			decoded: "INBOX/Sent",
+			encoded: "INBOX/Sent",
+		},
+		"(valid)ampersand": {
+			decoded: "Q&A",
+			encoded: "Q&-A",
+		},
+		"(valid)japanese": {
+			decoded: "受信箱/仕事",
+			encoded: "&U9dP4Xux-/&TtVOiw-",
+		},
+		"(valid)rfc3501 example": {
+			decoded: "~peter/mail/台北/日本語",
+			encoded: "~peter/mail/&U,BTFw-/&ZeVnLIqe-",
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			if got := imap.EncodeMailboxName(tt.decoded); got != tt.encoded {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.encoded)
+			}
+			got, err := imap.DecodeMailboxName(tt.encoded)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			if got != tt.decoded {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.decoded)
+			}
+		})
+	}
+}
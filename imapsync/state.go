@@ -0,0 +1,59 @@
+package imapsync
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// state is the synchronization state of a mailbox stored in the local maildir.
+type state struct {
+	UIDValidity   uint32             `json:"uid_validity"`
+	UIDNext       uint32             `json:"uid_next"`
+	HighestModSeq uint64             `json:"highest_modseq"`
+	Messages      map[uint32]*record `json:"messages"`
+}
+
+// record is the message synchronized at the last time.
+type record struct {
+	// Key is the unique part of the local key.
+	Key string `json:"key"`
+	// Flags are the maildir flags at the last synchronization.
+	Flags string `json:"flags"`
+}
+
+func stateFile(mdPath, account string) string {
+	return filepath.Join(mdPath, ".goem-imap-"+account+".json")
+}
+
+func loadState(path string) (*state, error) {
+	st := &state{Messages: map[uint32]*record{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	if st.Messages == nil {
+		st.Messages = map[uint32]*record{}
+	}
+	return st, nil
+}
+
+// save writes the state into the temporary file and renames it.
+func (st *state) save(path string) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,398 @@
+package imapsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tennashi/goem/imap"
+	"github.com/tennashi/goem/maildir"
+)
+
+// Account is the configuration of the IMAP account.
+type Account struct {
+	Name string `toml:"name"`
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+	// TLS is "tls", "starttls" or "none". The default is "tls".
+	TLS      string `toml:"tls"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// Folders maps the remote mailbox names to the local maildir names.
+	// If it is empty, all mailboxes are synchronized into the maildirs named
+	// with the hierarchy delimiter replaced by ".".
+	Folders map[string]string `toml:"folders"`
+}
+
+func (a Account) addr() string {
+	port := a.Port
+	if port == 0 {
+		port = 993
+		if a.TLS == "starttls" || a.TLS == "none" {
+			port = 143
+		}
+	}
+	return net.JoinHostPort(a.Host, strconv.Itoa(port))
+}
+
+// Syncer synchronizes the IMAP account into the maildirs under the root directory.
+//
+// New messages are downloaded, messages expunged on the server are removed
+// locally, and flag changes are merged in both directions against the state
+// of the last synchronization. Messages removed locally are marked \Deleted
+// on the server but never expunged.
+type Syncer struct {
+	account Account
+	rootDir string
+}
+
+// New is ...
+func New(account Account, rootDir string) *Syncer {
+	return &Syncer{
+		account: account,
+		rootDir: rootDir,
+	}
+}
+
+// Sync synchronizes all the mailboxes of the account.
+func (s *Syncer) Sync(ctx context.Context) error {
+	if s.account.Name == "" {
+		return errors.New("account name is required")
+	}
+	c, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	err = s.sync(c)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		return err
+	}
+	return c.Logout()
+}
+
+func (s *Syncer) connect(ctx context.Context) (*imap.Client, error) {
+	a := s.account
+	tlsConfig := &tls.Config{ServerName: a.Host}
+	var c *imap.Client
+	var err error
+	switch a.TLS {
+	case "", "tls":
+		c, err = imap.Dial(ctx, a.addr(), tlsConfig)
+	case "starttls":
+		c, err = imap.Dial(ctx, a.addr(), nil)
+		if err == nil {
+			if err = c.StartTLS(tlsConfig); err != nil {
+				c.Close()
+			}
+		}
+	case "none":
+		c, err = imap.Dial(ctx, a.addr(), nil)
+	default:
+		return nil, fmt.Errorf("unknown tls mode: %v", a.TLS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Login(a.Username, a.Password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *Syncer) sync(c *imap.Client) error {
+	folders := s.account.Folders
+	if len(folders) == 0 {
+		mbs, err := c.List("", "*")
+		if err != nil {
+			return err
+		}
+		folders = map[string]string{}
+		for _, mb := range mbs {
+			if hasAttribute(mb.Attributes, `\Noselect`) || hasAttribute(mb.Attributes, `\NonExistent`) {
+				continue
+			}
+			local, err := localName(mb.Name, mb.Delimiter)
+			if err != nil {
+				log.Printf("imapsync: %v: %v", s.account.Name, err)
+				continue
+			}
+			folders[mb.Name] = local
+		}
+	}
+
+	remotes := make([]string, 0, len(folders))
+	for remote := range folders {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+	condstore := c.Has("CONDSTORE")
+	for _, remote := range remotes {
+		if err := s.syncMailbox(c, remote, folders[remote], condstore); err != nil {
+			return fmt.Errorf("%v: %v", remote, err)
+		}
+	}
+	return nil
+}
+
+// localName returns the maildir name of the mailbox with the hierarchy
+// delimiter replaced by ".". The names escaping the root directory are
+// rejected since they are given by the server.
+func localName(name, delim string) (string, error) {
+	local := name
+	if delim != "" {
+		local = strings.Replace(local, delim, ".", -1)
+	}
+	if local == "" || local == "." || local == ".." || strings.ContainsAny(local, `/\`) || filepath.IsAbs(local) {
+		return "", fmt.Errorf("invalid mailbox name: %q", name)
+	}
+	return local, nil
+}
+
+func hasAttribute(attrs []string, attr string) bool {
+	for _, a := range attrs {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Syncer) syncMailbox(c *imap.Client, remote, local string, condstore bool) (err error) {
+	md, err := maildir.Create(filepath.Join(s.rootDir, local))
+	if err != nil {
+		return err
+	}
+	statePath := stateFile(md.Path, s.account.Name)
+	st, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if serr := st.save(statePath); err == nil {
+			err = serr
+		}
+	}()
+
+	status, err := c.Select(remote, condstore)
+	if err != nil {
+		return err
+	}
+	locals, err := localKeys(md)
+	if err != nil {
+		return err
+	}
+	if st.UIDValidity != 0 && st.UIDValidity != status.UIDValidity {
+		// the UIDs are no longer valid, so download the messages again.
+		for _, r := range st.Messages {
+			if k, ok := locals[r.Key]; ok {
+				if err := md.Remove(k); err != nil {
+					return err
+				}
+				delete(locals, r.Key)
+			}
+		}
+		st = &state{Messages: map[uint32]*record{}}
+	}
+	st.UIDValidity = status.UIDValidity
+
+	uids, err := c.UIDSearchAll()
+	if err != nil {
+		return err
+	}
+	exists := make(map[uint32]bool, len(uids))
+	for _, uid := range uids {
+		exists[uid] = true
+	}
+	var changedSince uint64
+	if condstore && status.HighestModSeq > 0 {
+		changedSince = st.HighestModSeq
+	}
+	ms, err := c.UIDFetchFlags(changedSince)
+	if err != nil {
+		return err
+	}
+	remoteFlags := make(map[uint32][]string, len(ms))
+	for _, m := range ms {
+		remoteFlags[m.UID] = imap.MaildirFlags(m.Flags)
+	}
+
+	if err := s.syncFlags(c, md, st, locals, exists, remoteFlags); err != nil {
+		return err
+	}
+
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	for _, uid := range uids {
+		if _, ok := st.Messages[uid]; ok || uid < st.UIDNext {
+			continue
+		}
+		m, err := c.UIDFetchMessage(uid)
+		if err != nil {
+			return err
+		}
+		flags := imap.MaildirFlags(m.Flags)
+		opt := maildir.DeliverOption{
+			SubDir: maildir.SubDirNew,
+			Flags:  flags,
+			Time:   m.InternalDate,
+		}
+		if len(flags) > 0 {
+			opt.SubDir = maildir.SubDirCur
+		}
+		k, err := md.Deliver(bytes.NewReader(m.Body), opt)
+		if err != nil {
+			return err
+		}
+		st.Messages[uid] = &record{Key: k.Unique(), Flags: strings.Join(flags, "")}
+	}
+
+	st.UIDNext = status.UIDNext
+	if len(uids) > 0 && st.UIDNext <= uids[len(uids)-1] {
+		st.UIDNext = uids[len(uids)-1] + 1
+	}
+	st.HighestModSeq = status.HighestModSeq
+	return nil
+}
+
+func (s *Syncer) syncFlags(c *imap.Client, md *maildir.Maildir, st *state, locals map[string]maildir.Key, exists map[uint32]bool, remoteFlags map[uint32][]string) error {
+	uids := make([]uint32, 0, len(st.Messages))
+	for uid := range st.Messages {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	for _, uid := range uids {
+		r := st.Messages[uid]
+		k, ok := locals[r.Key]
+		if !exists[uid] {
+			if ok {
+				if err := md.Remove(k); err != nil {
+					return err
+				}
+			}
+			delete(st.Messages, uid)
+			continue
+		}
+		if !ok {
+			if err := c.UIDStore(uid, true, []string{imap.FlagDeleted}); err != nil {
+				return err
+			}
+			delete(st.Messages, uid)
+			continue
+		}
+
+		base := r.Flags
+		localFlags := mergeFlags("", strings.Join(k.Flags, ""), "")
+		remote := base
+		if fs, ok := remoteFlags[uid]; ok {
+			// keep the flags IMAP cannot store so that they do not look removed.
+			remote = strings.Join(fs, "") + localOnlyFlags(base)
+		}
+		merged := mergeFlags(base, localFlags, remote)
+
+		add, remove := diffFlags(remote, merged)
+		if err := c.UIDStore(uid, true, imap.SystemFlags(add)); err != nil {
+			return err
+		}
+		if err := c.UIDStore(uid, false, imap.SystemFlags(remove)); err != nil {
+			return err
+		}
+		if merged != localFlags || k.SubDir() != maildir.SubDirCur && merged != "" {
+			nk, err := md.SetFlags(k, split(merged))
+			if err != nil {
+				return err
+			}
+			r.Key = nk.Unique()
+		}
+		r.Flags = merged
+	}
+	return nil
+}
+
+func localKeys(md *maildir.Maildir) (map[string]maildir.Key, error) {
+	keys := map[string]maildir.Key{}
+	for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		ks, err := md.Keys(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range ks {
+			keys[k.Unique()] = k
+		}
+	}
+	return keys, nil
+}
+
+// allFlags are the maildir flags in ASCII order.
+const allFlags = "DFPRST"
+
+// mergeFlags merges the flags changed locally and remotely since base.
+// A flag changed locally wins over the remote one. The result is sorted.
+func mergeFlags(base, local, remote string) string {
+	var b strings.Builder
+	for _, f := range allFlags {
+		inBase := strings.ContainsRune(base, f)
+		inLocal := strings.ContainsRune(local, f)
+		if inLocal != inBase {
+			if inLocal {
+				b.WriteRune(f)
+			}
+			continue
+		}
+		if strings.ContainsRune(remote, f) {
+			b.WriteRune(f)
+		}
+	}
+	return b.String()
+}
+
+func localOnlyFlags(flags string) string {
+	if strings.Contains(flags, maildir.FlagPassed) {
+		return maildir.FlagPassed
+	}
+	return ""
+}
+
+func diffFlags(from, to string) ([]string, []string) {
+	var add, remove []string
+	for _, f := range allFlags {
+		inFrom := strings.ContainsRune(from, f)
+		inTo := strings.ContainsRune(to, f)
+		switch {
+		case inTo && !inFrom:
+			add = append(add, string(f))
+		case inFrom && !inTo:
+			remove = append(remove, string(f))
+		}
+	}
+	return add, remove
+}
+
+func split(flags string) []string {
+	if flags == "" {
+		return nil
+	}
+	return strings.Split(flags, "")
+}
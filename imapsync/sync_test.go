@@ -0,0 +1,430 @@
+package imapsync_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tennashi/goem/imapsync"
+	"github.com/tennashi/goem/maildir"
+)
+
+type fakeMessage struct {
+	uid    uint32
+	flags  []string
+	modSeq uint64
+	body   string
+}
+
+type fakeMailbox struct {
+	uidValidity uint32
+	uidNext     uint32
+	messages    []*fakeMessage
+}
+
+// fakeServer is the IMAP server stand-in which supports the commands the syncer uses.
+type fakeServer struct {
+	mu        sync.Mutex
+	ln        net.Listener
+	modSeq    uint64
+	mailboxes map[string]*fakeMailbox
+	// delimiter is the hierarchy delimiter, NIL if it is empty.
+	delimiter string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, mailboxes: map[string]*fakeMailbox{}, delimiter: "/"}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeServer) account() imapsync.Account {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return imapsync.Account{
+		Name:     "test",
+		Host:     host,
+		Port:     p,
+		TLS:      "none",
+		Username: "user",
+		Password: "pass",
+	}
+}
+
+func (s *fakeServer) add(mailbox string, flags []string, body string) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb, ok := s.mailboxes[mailbox]
+	if !ok {
+		mb = &fakeMailbox{uidValidity: 1, uidNext: 1}
+		s.mailboxes[mailbox] = mb
+	}
+	s.modSeq++
+	m := &fakeMessage{uid: mb.uidNext, flags: flags, modSeq: s.modSeq, body: body}
+	mb.uidNext++
+	mb.messages = append(mb.messages, m)
+	return m.uid
+}
+
+func (s *fakeServer) message(mailbox string, uid uint32) *fakeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.mailboxes[mailbox].messages {
+		if m.uid == uid {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) setFlags(mailbox string, uid uint32, flags []string) {
+	m := s.message(mailbox, uid)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modSeq++
+	m.flags = flags
+	m.modSeq = s.modSeq
+}
+
+func (s *fakeServer) expunge(mailbox string, uid uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb := s.mailboxes[mailbox]
+	for i, m := range mb.messages {
+		if m.uid == uid {
+			mb.messages = append(mb.messages[:i], mb.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	fmt.Fprint(w, "* OK [CAPABILITY IMAP4rev1 CONDSTORE] ready\r\n")
+	w.Flush()
+
+	var selected *fakeMailbox
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) < 2 {
+			return
+		}
+		tag, cmd, args := fields[0], strings.ToUpper(fields[1]), fields[2:]
+		if cmd == "UID" && len(args) > 0 {
+			cmd += " " + strings.ToUpper(args[0])
+			args = args[1:]
+		}
+
+		s.mu.Lock()
+		switch cmd {
+		case "CAPABILITY":
+			fmt.Fprint(w, "* CAPABILITY IMAP4rev1 CONDSTORE\r\n")
+		case "LOGIN":
+		case "LOGOUT":
+			fmt.Fprint(w, "* BYE logging out\r\n")
+		case "LIST":
+			names := make([]string, 0, len(s.mailboxes))
+			for name := range s.mailboxes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			delim := "NIL"
+			if s.delimiter != "" {
+				delim = `"` + s.delimiter + `"`
+			}
+			for _, name := range names {
+				fmt.Fprintf(w, "* LIST () %v \"%v\"\r\n", delim, name)
+			}
+		case "SELECT":
+			selected = s.mailboxes[strings.Trim(args[0], `"`)]
+			if selected == nil {
+				fmt.Fprintf(w, "%v NO no such mailbox\r\n", tag)
+				s.mu.Unlock()
+				w.Flush()
+				continue
+			}
+			fmt.Fprintf(w, "* %v EXISTS\r\n", len(selected.messages))
+			fmt.Fprintf(w, "* OK [UIDVALIDITY %v] ok\r\n", selected.uidValidity)
+			fmt.Fprintf(w, "* OK [UIDNEXT %v] ok\r\n", selected.uidNext)
+			fmt.Fprintf(w, "* OK [HIGHESTMODSEQ %v] ok\r\n", s.modSeq)
+		case "UID SEARCH":
+			fmt.Fprint(w, "* SEARCH")
+			for _, m := range selected.messages {
+				fmt.Fprintf(w, " %v", m.uid)
+			}
+			fmt.Fprint(w, "\r\n")
+		case "UID FETCH":
+			var changedSince uint64
+			if i := strings.Index(line, "CHANGEDSINCE "); i >= 0 {
+				changedSince, _ = strconv.ParseUint(strings.TrimRight(line[i+len("CHANGEDSINCE "):], ")\r\n"), 10, 64)
+			}
+			for i, m := range selected.messages {
+				if args[0] != "1:*" && args[0] != fmt.Sprint(m.uid) || m.modSeq <= changedSince {
+					continue
+				}
+				fmt.Fprintf(w, "* %v FETCH (UID %v FLAGS (%v) MODSEQ (%v)", i+1, m.uid, strings.Join(m.flags, " "), m.modSeq)
+				if strings.Contains(line, "BODY.PEEK[]") {
+					fmt.Fprintf(w, " INTERNALDATE \"02-Jan-2006 15:04:05 +0000\" BODY[] {%v}\r\n%v", len(m.body), m.body)
+				}
+				fmt.Fprint(w, ")\r\n")
+			}
+		case "UID STORE":
+			uid, _ := strconv.ParseUint(args[0], 10, 32)
+			flags := strings.Fields(strings.Trim(strings.Join(args[2:], " "), "()"))
+			for _, m := range selected.messages {
+				if uint64(m.uid) != uid {
+					continue
+				}
+				s.modSeq++
+				m.modSeq = s.modSeq
+				if strings.HasPrefix(args[1], "+") {
+					m.flags = append(m.flags, flags...)
+					continue
+				}
+				var kept []string
+				for _, f := range m.flags {
+					if !contains(flags, f) {
+						kept = append(kept, f)
+					}
+				}
+				m.flags = kept
+			}
+		default:
+			fmt.Fprintf(w, "%v BAD unknown command\r\n", tag)
+			s.mu.Unlock()
+			w.Flush()
+			continue
+		}
+		s.mu.Unlock()
+		fmt.Fprintf(w, "%v OK done\r\n", tag)
+		w.Flush()
+		if cmd == "LOGOUT" {
+			return
+		}
+	}
+}
+
+func contains(strs []string, s string) bool {
+	for _, t := range strs {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// localMessages returns the bodies in the maildir keyed by the sub directory and the flags.
+func localMessages(t *testing.T, path string) map[string]string {
+	t.Helper()
+	md, err := maildir.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := map[string]string{}
+	for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		keys, err := md.Keys(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range keys {
+			f, err := md.Open(k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			ms[string(b)] = s.String() + ":" + strings.Join(k.Flags, "")
+		}
+	}
+	return ms
+}
+
+func findKey(t *testing.T, path, body string) maildir.Key {
+	t.Helper()
+	md, _ := maildir.New(path)
+	for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		keys, _ := md.Keys(s)
+		for _, k := range keys {
+			f, _ := md.Open(k)
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			if string(b) == body {
+				return k
+			}
+		}
+	}
+	t.Fatalf("%q not found", body)
+	return maildir.Key{}
+}
+
+func Test_Syncer_Sync(t *testing.T) {
+	srv := newFakeServer(t)
+	one := srv.add("INBOX", []string{`\Seen`}, "Subject: one\r\n\r\n1\r\n")
+	two := srv.add("INBOX", nil, "Subject: two\r\n\r\n2\r\n")
+	srv.add("Work/Sub", nil, "Subject: sub\r\n\r\nsub\r\n")
+
+	root, err := ioutil.TempDir("", "imapsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	inbox := filepath.Join(root, "INBOX")
+	s := imapsync.New(srv.account(), root)
+	sync := func() {
+		t.Helper()
+		if err := s.Sync(context.Background()); err != nil {
+			t.Fatalf("should not be error but %v", err)
+		}
+	}
+
+	sync()
+	want := map[string]string{
+		"Subject: one\r\n\r\n1\r\n": "cur:S",
+		"Subject: two\r\n\r\n2\r\n": "new:",
+	}
+	if got := localMessages(t, inbox); !reflect.DeepEqual(got, want) {
+		t.Fatalf("initial\n\tgot: %v\n\twant: %v", got, want)
+	}
+	if got := localMessages(t, filepath.Join(root, "Work.Sub")); len(got) != 1 {
+		t.Fatalf("Work.Sub: %v", got)
+	}
+
+	// flag changes in both directions and a new message.
+	md, _ := maildir.New(inbox)
+	if _, err := md.SetFlags(findKey(t, inbox, "Subject: two\r\n\r\n2\r\n"), []string{"F", "S"}); err != nil {
+		t.Fatal(err)
+	}
+	srv.setFlags("INBOX", one, []string{`\Seen`, `\Answered`})
+	three := srv.add("INBOX", nil, "Subject: three\r\n\r\n3\r\n")
+	sync()
+	want = map[string]string{
+		"Subject: one\r\n\r\n1\r\n":   "cur:RS",
+		"Subject: two\r\n\r\n2\r\n":   "cur:FS",
+		"Subject: three\r\n\r\n3\r\n": "new:",
+	}
+	if got := localMessages(t, inbox); !reflect.DeepEqual(got, want) {
+		t.Fatalf("changed\n\tgot: %v\n\twant: %v", got, want)
+	}
+	flags := srv.message("INBOX", two).flags
+	sort.Strings(flags)
+	if !reflect.DeepEqual(flags, []string{`\Flagged`, `\Seen`}) {
+		t.Fatalf("remote flags: %v", flags)
+	}
+
+	// deletions in both directions.
+	srv.expunge("INBOX", three)
+	if err := md.Remove(findKey(t, inbox, "Subject: one\r\n\r\n1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	want = map[string]string{
+		"Subject: two\r\n\r\n2\r\n": "cur:FS",
+	}
+	if got := localMessages(t, inbox); !reflect.DeepEqual(got, want) {
+		t.Fatalf("deleted\n\tgot: %v\n\twant: %v", got, want)
+	}
+	if !contains(srv.message("INBOX", one).flags, `\Deleted`) {
+		t.Fatalf("remote message should be marked deleted")
+	}
+}
+
+func Test_Syncer_Sync_mailboxNames(t *testing.T) {
+	cases := map[string]struct {
+		delimiter string
+		mailboxes []string
+		want      []string
+	}{
+		"(valid)hierarchy": {
+			delimiter: "/",
+			mailboxes: []string{"INBOX", "Work/Sub", "../x"},
+			want:      []string{"...x", "INBOX", "Work.Sub"},
+		},
+		"(invalid)parent": {
+			delimiter: "/",
+			mailboxes: []string{"INBOX", ".."},
+			want:      []string{"INBOX"},
+		},
+		"(invalid)path without delimiter": {
+			mailboxes: []string{"INBOX", "../../x", "/tmp/x", "."},
+			want:      []string{"INBOX"},
+		},
+		"(invalid)path with dot delimiter": {
+			delimiter: ".",
+			mailboxes: []string{"INBOX", "../x", "a/../../x"},
+			want:      []string{"INBOX"},
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			srv.delimiter = tt.delimiter
+			for _, mb := range tt.mailboxes {
+				srv.add(mb, nil, "Subject: "+mb+"\r\n\r\nbody\r\n")
+			}
+			dir, err := ioutil.TempDir("", "imapsync")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			root := filepath.Join(dir, "a", "root")
+			if err := os.MkdirAll(root, 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := imapsync.New(srv.account(), root).Sync(context.Background()); err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.mailboxes, err)
+			}
+			var got []string
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if maildir.IsMaildir(path) {
+					rel, err := filepath.Rel(root, path)
+					if err != nil {
+						return err
+					}
+					got = append(got, rel)
+					return filepath.SkipDir
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
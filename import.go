@@ -0,0 +1,104 @@
+package goem
+
+import (
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/mh"
+)
+
+// ImportMH delivers the messages in the MH folder into the maildir named mdName.
+// Messages in the unseen sequence are delivered into new and the others into cur,
+// and the flagged and replied sequences are converted to the flags.
+func (r *MaildirRoot) ImportMH(mdName, dir string) (int, error) {
+	f, err := mh.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+	ns, err := f.Messages()
+	if err != nil {
+		return 0, err
+	}
+	md, err := maildir.Create(r.maildirPath(mdName))
+	if err != nil {
+		return 0, err
+	}
+
+	for i, n := range ns {
+		opt := maildir.DeliverOption{SubDir: maildir.SubDirNew}
+		if !f.InSequence("unseen", n) {
+			opt.SubDir = maildir.SubDirCur
+			opt.Flags = append(opt.Flags, maildir.FlagSeen)
+		}
+		if f.InSequence("flagged", n) {
+			opt.Flags = append(opt.Flags, maildir.FlagFlagged)
+		}
+		if f.InSequence("replied", n) {
+			opt.Flags = append(opt.Flags, maildir.FlagReplied)
+		}
+		if err := deliverFile(md, f.MessagePath(n), opt); err != nil {
+			return i, err
+		}
+	}
+	return len(ns), nil
+}
+
+// ImportEML delivers the .eml files in dir into new of the maildir named mdName.
+func (r *MaildirRoot) ImportEML(mdName, dir string) (int, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	md, err := maildir.Create(r.maildirPath(mdName))
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, info := range infos {
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(info.Name()), ".eml") {
+			continue
+		}
+		opt := maildir.DeliverOption{SubDir: maildir.SubDirNew}
+		if err := deliverFile(md, filepath.Join(dir, info.Name()), opt); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// deliverFile delivers the message file with the time of its Date header,
+// or its modification time if the header is missing.
+func deliverFile(md *maildir.Maildir, path string, opt maildir.DeliverOption) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	opt.Time = messageTime(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = md.Deliver(f, opt)
+	return err
+}
+
+func messageTime(f *os.File) time.Time {
+	if m, err := mail.ReadMessage(f); err == nil {
+		if t, err := m.Header.Date(); err == nil {
+			return t
+		}
+	}
+	if info, err := f.Stat(); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,136 @@
+package goem_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+)
+
+// writeFiles writes the files under a temporary directory.
+func writeFiles(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// imported returns the sub directory and the info of the keys delivered.
+func imported(t *testing.T, path string) []string {
+	t.Helper()
+	md, err := maildir.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, sd := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		keys, err := md.Keys(sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range keys {
+			got = append(got, sd.String()+":"+k.Info())
+		}
+	}
+	sort.Strings(got)
+	return got
+}
+
+func Test_MaildirRoot_ImportMH(t *testing.T) {
+	cases := map[string]struct {
+		files map[string]string
+		want  []string
+		err   bool
+	}{
+		"(valid)sequences": {
+			files: map[string]string{
+				"1":             "Subject: 1\n\nbody\n",
+				"2":             "Subject: 2\n\nbody\n",
+				"3":             "Subject: 3\n\nbody\n",
+				"notes":         "not a message",
+				".mh_sequences": "unseen: 2\nflagged: 1-3\nreplied: 3-100\n",
+			},
+			want: []string{"cur:2,FRS", "cur:2,FS", "new:2,F"},
+			err:  false,
+		},
+		"(valid)no sequences": {
+			files: map[string]string{
+				"1": "Subject: 1\n\nbody\n",
+			},
+			want: []string{"cur:2,S"},
+			err:  false,
+		},
+		"(invalid)reversed range": {
+			files: map[string]string{
+				"1":             "Subject: 1\n\nbody\n",
+				".mh_sequences": "unseen: 5-3\n",
+			},
+			want: nil,
+			err:  true,
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			src, cleanup := writeFiles(t, tt.files)
+			defer cleanup()
+			root, cleanup := writeFiles(t, nil)
+			defer cleanup()
+
+			n, err := goem.NewMaildirRoot(root).ImportMH("INBOX", src)
+			if !tt.err && err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			if tt.err {
+				if err == nil {
+					t.Fatalf("should be error for %v but not", caseName)
+				}
+				return
+			}
+			if n != len(tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", n, len(tt.want))
+			}
+			if got := imported(t, filepath.Join(root, "INBOX")); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_MaildirRoot_ImportEML(t *testing.T) {
+	src, cleanup := writeFiles(t, map[string]string{
+		"a.eml":         "Subject: a\n\nbody\n",
+		"B.EML":         "Subject: b\n\nbody\n",
+		"note.txt":      "not a message",
+		"dir.eml/c.eml": "Subject: c\n\nbody\n",
+	})
+	defer cleanup()
+	root, cleanup := writeFiles(t, nil)
+	defer cleanup()
+
+	n, err := goem.NewMaildirRoot(root).ImportEML("INBOX", src)
+	if err != nil {
+		t.Fatalf("should not be error for %v but %v", src, err)
+	}
+	if n != 2 {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", n, 2)
+	}
+	if got, want := imported(t, filepath.Join(root, "INBOX")), []string{"new:", "new:"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got, want)
+	}
+}
@@ -13,6 +13,8 @@ type Mail struct {
 	Subject string
 	Headers mail.Header
 	Body    io.Reader
+	// Size is the size of the message file, zero if it is unknown.
+	Size int64
 }
 
 // NewMail is ...
@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+var addressParser = &mail.AddressParser{WordDecoder: wordDecoder}
+
+// Addresses returns the addresses in the header field. Unlike AddressList it
+// never fails: if the field is malformed, the addresses are parsed one by one
+// and the ones which cannot be parsed are returned as the names without the
+// addresses.
+func (h Header) Addresses(key string) []*mail.Address {
+	v := mail.Header(h).Get(key)
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	if as, err := addressParser.ParseList(v); err == nil {
+		return as
+	}
+
+	var as []*mail.Address
+	for _, s := range splitAddresses(v) {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		a, err := addressParser.Parse(s)
+		if err != nil {
+			a = &mail.Address{Name: strings.TrimSpace(decodeHeader(s))}
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+// splitAddresses splits the address list by the commas outside of the
+// quoted strings, the comments and the angle brackets.
+func splitAddresses(v string) []string {
+	var ss []string
+	var quoted, escaped bool
+	depth, start := 0, 0
+	for i, r := range v {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '(' || r == '<':
+			depth++
+		case (r == ')' || r == '>') && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			ss = append(ss, v[start:i])
+			start = i + 1
+		}
+	}
+	return append(ss, v[start:])
+}
+
+// MessageID returns the Message-ID without the angle brackets.
+func (h Header) MessageID() string {
+	ids := msgIDs(mail.Header(h).Get("Message-Id"))
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+// References returns the message IDs in References without the angle brackets.
+func (h Header) References() []string {
+	return msgIDs(mail.Header(h).Get("References"))
+}
+
+// InReplyTo returns the message IDs in In-Reply-To without the angle brackets.
+func (h Header) InReplyTo() []string {
+	return msgIDs(mail.Header(h).Get("In-Reply-To"))
+}
+
+// msgIDs extracts the message IDs enclosed in the angle brackets.
+// The whole value is returned if it has no brackets.
+func msgIDs(v string) []string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	if !strings.Contains(v, "<") {
+		return strings.Fields(v)
+	}
+	var ids []string
+	for {
+		i := strings.Index(v, "<")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(v[i:], ">")
+		if j < 0 {
+			break
+		}
+		if id := strings.TrimSpace(v[i+1 : i+j]); id != "" {
+			ids = append(ids, id)
+		}
+		v = v[i+j+1:]
+	}
+	return ids
+}
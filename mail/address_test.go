@@ -0,0 +1,52 @@
+package mail_test
+
+import (
+	"net/textproto"
+	"reflect"
+	"testing"
+
+	"github.com/tennashi/goem/mail"
+)
+
+func Test_Header_Addresses(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  []string
+	}{
+		"(valid)encoded names": {
+			input: `=?ISO-2022-JP?B?GyRCRnxLXDhsGyhC?= <a@example.com>, "Doe, John" <j@example.com>`,
+			want:  []string{"日本語 <a@example.com>", "Doe, John <j@example.com>"},
+		},
+		"(valid)malformed entry": {
+			input: `b@example.com, not an address, =?UTF-8?Q?caf=C3=A9?= <c@example.com>`,
+			want:  []string{" <b@example.com>", "not an address <>", "café <c@example.com>"},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := mail.Header(textproto.MIMEHeader{"To": {tt.input}})
+			var got []string
+			for _, a := range h.Addresses("to") {
+				got = append(got, a.Name+" <"+a.Address+">")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Header_References(t *testing.T) {
+	h := mail.Header(textproto.MIMEHeader{
+		"Message-Id": {"<one@example.com>"},
+		"References": {"<a@example.com>\r\n <b@example.com> garbage <c@example.com"},
+	})
+	if got := h.MessageID(); got != "one@example.com" {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got, "one@example.com")
+	}
+	want := []string{"a@example.com", "b@example.com"}
+	if got := h.References(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got, want)
+	}
+}
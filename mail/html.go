@@ -0,0 +1,239 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// SanitizeOption is the option for SanitizeHTML.
+type SanitizeOption struct {
+	// AllowRemote keeps the resources loaded from the remote servers.
+	// They are removed by default because they can track the reader.
+	AllowRemote bool
+	// CIDURL returns the URL of the part referenced by the cid: URL.
+	// The references are removed if it is nil.
+	CIDURL func(cid string) string
+}
+
+// droppedElements are removed with their contents.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Form:     true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+	atom.Title:    true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Audio:    true,
+	atom.Video:    true,
+}
+
+// allowedElements are kept. The other elements are replaced with their contents.
+var allowedElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.Address: true, atom.B: true,
+	atom.Blockquote: true, atom.Br: true, atom.Caption: true, atom.Center: true,
+	atom.Cite: true, atom.Code: true, atom.Col: true, atom.Colgroup: true,
+	atom.Dd: true, atom.Del: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Em: true, atom.Font: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true, atom.I: true,
+	atom.Img: true, atom.Ins: true, atom.Kbd: true, atom.Li: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Q: true, atom.S: true, atom.Small: true,
+	atom.Span: true, atom.Strike: true, atom.Strong: true, atom.Sub: true,
+	atom.Sup: true, atom.Table: true, atom.Tbody: true, atom.Td: true,
+	atom.Tfoot: true, atom.Th: true, atom.Thead: true, atom.Tr: true, atom.U: true,
+	atom.Ul: true,
+}
+
+// allowedAttrs are kept on the allowed elements.
+var allowedAttrs = map[string]bool{
+	"align": true, "alt": true, "bgcolor": true, "border": true,
+	"cellpadding": true, "cellspacing": true, "color": true, "colspan": true,
+	"dir": true, "face": true, "height": true, "href": true, "lang": true,
+	"rowspan": true, "size": true, "src": true, "style": true, "title": true,
+	"valign": true, "width": true,
+}
+
+// SanitizeHTML returns the HTML body without the scripts, the event handlers
+// and the remote resources so that it can be displayed safely.
+func SanitizeHTML(r io.Reader, opt SanitizeOption) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+
+	var buf bytes.Buffer
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := sanitizeNode(&buf, c, opt); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func sanitizeNode(w *bytes.Buffer, n *html.Node, opt SanitizeOption) error {
+	switch n.Type {
+	case html.TextNode:
+		w.WriteString(html.EscapeString(n.Data))
+		return nil
+	case html.ElementNode:
+	default:
+		return nil
+	}
+	if droppedElements[n.DataAtom] {
+		return nil
+	}
+	if !allowedElements[n.DataAtom] {
+		return sanitizeChildren(w, n, opt)
+	}
+
+	e := &html.Node{Type: html.ElementNode, Data: n.Data, DataAtom: n.DataAtom}
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !allowedAttrs[key] {
+			continue
+		}
+		val, ok := sanitizeAttr(n.DataAtom, key, a.Val, opt)
+		if !ok {
+			continue
+		}
+		e.Attr = append(e.Attr, html.Attribute{Key: key, Val: val})
+	}
+	if n.DataAtom == atom.Img && attr(e, "src") == "" {
+		// show the alternative text instead of the broken image.
+		w.WriteString(html.EscapeString(attr(n, "alt")))
+		return nil
+	}
+	if n.DataAtom == atom.A && attr(e, "href") != "" {
+		e.Attr = append(e.Attr,
+			html.Attribute{Key: "rel", Val: "noopener noreferrer"},
+			html.Attribute{Key: "target", Val: "_blank"},
+		)
+	}
+
+	// render the start and end tags only; the children are sanitized recursively.
+	var tag bytes.Buffer
+	if err := html.Render(&tag, e); err != nil {
+		return err
+	}
+	s := tag.String()
+	end := "</" + e.Data + ">"
+	if !strings.HasSuffix(s, end) {
+		// void element
+		w.WriteString(s)
+		return nil
+	}
+	w.WriteString(strings.TrimSuffix(s, end))
+	if err := sanitizeChildren(w, n, opt); err != nil {
+		return err
+	}
+	w.WriteString(end)
+	return nil
+}
+
+func sanitizeChildren(w *bytes.Buffer, n *html.Node, opt SanitizeOption) error {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if err := sanitizeNode(w, c, opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sanitizeAttr(a atom.Atom, key, val string, opt SanitizeOption) (string, bool) {
+	switch key {
+	case "href":
+		if a != atom.A {
+			return "", false
+		}
+		switch urlScheme(val) {
+		case "", "http", "https", "mailto":
+			return val, true
+		}
+		return "", false
+	case "src":
+		if a != atom.Img {
+			return "", false
+		}
+		switch urlScheme(val) {
+		case "cid":
+			if opt.CIDURL == nil {
+				return "", false
+			}
+			u := opt.CIDURL(val[len("cid:"):])
+			return u, u != ""
+		case "http", "https":
+			return val, opt.AllowRemote
+		case "data":
+			return val, strings.HasPrefix(strings.ToLower(val), "data:image/")
+		}
+		return "", false
+	case "style":
+		// the escapes and the comments can hide the keywords below.
+		if strings.Contains(val, `\`) || strings.Contains(val, "/*") {
+			return "", false
+		}
+		lower := strings.ToLower(val)
+		if strings.Contains(lower, "expression") || strings.Contains(lower, "javascript:") {
+			return "", false
+		}
+		if strings.Contains(lower, "url(") && !opt.AllowRemote {
+			return "", false
+		}
+		return val, true
+	}
+	return val, true
+}
+
+// urlScheme returns the lower-cased scheme of the URL, or "" if it is relative.
+func urlScheme(u string) string {
+	// browsers ignore the control characters and the spaces in the scheme.
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u)
+	i := strings.IndexAny(u, ":/?#")
+	if i <= 0 || u[i] != ':' {
+		return ""
+	}
+	return strings.ToLower(u[:i])
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
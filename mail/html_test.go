@@ -0,0 +1,116 @@
+package mail_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/mail"
+)
+
+func Test_SanitizeHTML(t *testing.T) {
+	cidURL := func(cid string) string { return "/parts/" + cid }
+	cases := map[string]struct {
+		input string
+		opt   mail.SanitizeOption
+		want  string
+	}{
+		"(valid)script": {
+			input: `<p>hello<script>alert(1)</script></p>`,
+			want:  `<p>hello</p>`,
+		},
+		"(valid)event handler": {
+			input: `<div onclick="alert(1)" align="center">hi</div>`,
+			want:  `<div align="center">hi</div>`,
+		},
+		"(valid)javascript link": {
+			input: `<a href=" javascript:alert(1)">x</a><a href="https://example.com">y</a>`,
+			want:  `<a>x</a><a href="https://example.com" rel="noopener noreferrer" target="_blank">y</a>`,
+		},
+		"(valid)remote image": {
+			input: `<img src="https://example.com/t.gif" alt="logo">`,
+			want:  `logo`,
+		},
+		"(valid)remote image allowed": {
+			input: `<img src="https://example.com/t.gif">`,
+			opt:   mail.SanitizeOption{AllowRemote: true},
+			want:  `<img src="https://example.com/t.gif"/>`,
+		},
+		"(valid)cid": {
+			input: `<img src="cid:logo@example.com">`,
+			opt:   mail.SanitizeOption{CIDURL: cidURL},
+			want:  `<img src="/parts/logo@example.com"/>`,
+		},
+		"(valid)style with url": {
+			input: `<span style="background: url(https://example.com/t.gif)">x</span><span style="color: red">y</span>`,
+			want:  `<span>x</span><span style="color: red">y</span>`,
+		},
+		"(valid)style with escaped url": {
+			input: `<span style="background:u\72l(http://tracker/)">x</span>`,
+			want:  `<span>x</span>`,
+		},
+		"(valid)style with commented expression": {
+			input: `<span style="width: expr/**/ession(alert(1))">x</span>`,
+			want:  `<span>x</span>`,
+		},
+		"(valid)unknown element": {
+			input: `<html><head><title>t</title></head><body><custom>text &amp; more</custom><iframe src="x"></iframe></body></html>`,
+			want:  `text &amp; more`,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := mail.SanitizeHTML(strings.NewReader(tt.input), tt.opt)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_HTMLToText(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  string
+	}{
+		"(valid)paragraphs": {
+			input: "<p>hello\n  world</p><p>second<br>line</p>",
+			want:  "hello world\n\nsecond\nline\n",
+		},
+		"(valid)links": {
+			input: `<p>see <a href="https://example.com">the site</a> or <a href="https://example.org">https://example.org</a></p>`,
+			want:  "see the site <https://example.com> or https://example.org\n",
+		},
+		"(valid)lists": {
+			input: `<ul><li>one</li><li>two<ol><li>a</li><li>b</li></ol></li></ul>`,
+			want:  "* one\n* two\n  1. a\n  2. b\n",
+		},
+		"(valid)blockquote": {
+			input: `<p>reply</p><blockquote><p>quoted</p><p>text</p></blockquote>`,
+			want:  "reply\n\n> quoted\n>\n> text\n",
+		},
+		"(valid)pre": {
+			input: "<pre>a  b\n  c</pre>",
+			want:  "a  b\n  c\n",
+		},
+		"(valid)script and image": {
+			input: `<script>x()</script><p><img alt="logo"> text</p>`,
+			want:  "[logo] text\n",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := mail.HTMLToText(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got, tt.want)
+			}
+		})
+	}
+}
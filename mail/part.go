@@ -0,0 +1,228 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Part is a node of the MIME tree.
+type Part struct {
+	// ID is the section number such as "1.2" as in IMAP.
+	// It is empty for the multipart message itself.
+	ID     string
+	Header Header
+	// MediaType is the lower-cased media type, text/plain if it is not given.
+	MediaType string
+	Params    map[string]string
+	// Body is the content with the transfer encoding decoded.
+	Body []byte
+	// Parts are the child parts, nil unless the part is multipart.
+	Parts []*Part
+}
+
+// ReadPart reads the message and parses its MIME tree.
+func ReadPart(r io.Reader) (*Part, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewPart(Header(msg.Header), msg.Body)
+}
+
+// NewPart parses the MIME tree of the message with the header and the body.
+func NewPart(h Header, body io.Reader) (*Part, error) {
+	p, err := newPart("", h, body)
+	if err != nil {
+		return nil, err
+	}
+	if p.Parts == nil {
+		p.ID = "1"
+	}
+	return p, nil
+}
+
+func newPart(id string, h Header, body io.Reader) (*Part, error) {
+	p := &Part{ID: id, Header: h, MediaType: "text/plain", Params: map[string]string{}}
+	if ct := mail.Header(h).Get("Content-Type"); ct != "" {
+		mt, params, err := mime.ParseMediaType(ct)
+		if err == nil {
+			p.MediaType, p.Params = mt, params
+		}
+	}
+
+	if strings.HasPrefix(p.MediaType, "multipart/") && p.Params["boundary"] != "" {
+		mr := multipart.NewReader(body, p.Params["boundary"])
+		for i := 1; ; i++ {
+			mp, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			childID := fmt.Sprint(i)
+			if id != "" {
+				childID = id + "." + childID
+			}
+			child, err := newPart(childID, Header(mp.Header), mp)
+			if err != nil {
+				return nil, err
+			}
+			p.Parts = append(p.Parts, child)
+		}
+		if p.Parts == nil {
+			p.Parts = []*Part{}
+		}
+		return p, nil
+	}
+
+	b, err := ioutil.ReadAll(decodeTransfer(mail.Header(h).Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return nil, err
+	}
+	p.Body = b
+	return p, nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner drops the line breaks and spaces the decoder does not accept.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		j := 0
+		for _, b := range p[:n] {
+			if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+// IsMultipart reports whether the part has the child parts.
+func (p *Part) IsMultipart() bool {
+	return p.Parts != nil
+}
+
+// ContentID returns the Content-ID without the angle brackets.
+func (p *Part) ContentID() string {
+	id := strings.TrimSpace(mail.Header(p.Header).Get("Content-Id"))
+	return strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+}
+
+// Filename returns the file name of the attachment, or "" if it has none.
+func (p *Part) Filename() string {
+	if cd := mail.Header(p.Header).Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil && params["filename"] != "" {
+			return params["filename"]
+		}
+	}
+	return p.Params["name"]
+}
+
+// Text returns the body of the text part converted from its charset to UTF-8.
+// The body in an unknown charset is returned as it is.
+func (p *Part) Text() (string, error) {
+	charset := p.Params["charset"]
+	switch strings.ToLower(charset) {
+	case "", "us-ascii", "utf-8", "utf8":
+		return string(p.Body), nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(p.Body), nil
+	}
+	b, err := ioutil.ReadAll(enc.NewDecoder().Reader(bytes.NewReader(p.Body)))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Walk calls fn for the part and its descendants in depth-first order.
+func (p *Part) Walk(fn func(*Part)) {
+	fn(p)
+	for _, c := range p.Parts {
+		c.Walk(fn)
+	}
+}
+
+// Find returns the part with the section number, or nil if not found.
+func (p *Part) Find(id string) *Part {
+	var found *Part
+	p.Walk(func(c *Part) {
+		if found == nil && c.ID == id {
+			found = c
+		}
+	})
+	return found
+}
+
+// FindContentID returns the part with the Content-ID, or nil if not found.
+func (p *Part) FindContentID(cid string) *Part {
+	var found *Part
+	p.Walk(func(c *Part) {
+		if found == nil && c.ContentID() == cid {
+			found = c
+		}
+	})
+	return found
+}
+
+// PlainText returns the body of the message preferring text/plain.
+// The text/html body is converted to the plain text.
+func (p *Part) PlainText() (string, error) {
+	b := p.SelectBody(PreferPlain)
+	if b == nil {
+		return "", nil
+	}
+	s, err := b.Text()
+	if err != nil {
+		return "", err
+	}
+	if b.MediaType == "text/html" {
+		return HTMLToText(strings.NewReader(s))
+	}
+	return s, nil
+}
+
+// ParseText parses the MIME tree of the message and returns it with the
+// plain text of the body. If the body can't be parsed, the tree is nil and
+// the text is the body as it is.
+func ParseText(h Header, body []byte) (*Part, string) {
+	p, err := NewPart(h, bytes.NewReader(body))
+	if err != nil {
+		return nil, string(body)
+	}
+	text, err := p.PlainText()
+	if err != nil {
+		return p, string(body)
+	}
+	return p, text
+}
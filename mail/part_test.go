@@ -0,0 +1,132 @@
+package mail_test
+
+import (
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/mail"
+)
+
+func Test_ReadPart(t *testing.T) {
+	msg := strings.Join([]string{
+		"Content-Type: multipart/related; boundary=b1",
+		"",
+		"--b1",
+		"Content-Type: text/html; charset=iso-2022-jp",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"GyRCRnxLXDhsGyhC",
+		"--b1",
+		"Content-Type: image/png",
+		"Content-ID: <logo@example.com>",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"aW1h",
+		"Z2U=",
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	p, err := mail.ReadPart(strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if !p.IsMultipart() || len(p.Parts) != 2 {
+		t.Fatalf("should be multipart with 2 parts but %v", p.Parts)
+	}
+
+	html := p.Find("1")
+	if html == nil || html.MediaType != "text/html" {
+		t.Fatalf("part 1 should be text/html but %v", html)
+	}
+	text, err := html.Text()
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if text != "日本語" {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", text, "日本語")
+	}
+
+	img := p.FindContentID("logo@example.com")
+	if img == nil || img.ID != "2" || string(img.Body) != "image" {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", img, "image")
+	}
+}
+
+func Test_Part_Text(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  string
+	}{
+		"(valid)utf-8": {
+			input: "Content-Type: text/plain; charset=utf-8\r\n\r\n日本語",
+			want:  "日本語",
+		},
+		"(valid)iso-2022-jp": {
+			input: "Content-Type: text/plain; charset=iso-2022-jp\r\n\r\n\x1b$BF|K\\8l\x1b(B",
+			want:  "日本語",
+		},
+		"(valid)unknown charset": {
+			input: "Content-Type: text/plain; charset=x-unknown\r\n\r\nraw text",
+			want:  "raw text",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := mail.ReadPart(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.input, err)
+			}
+			got, err := p.Text()
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseText(t *testing.T) {
+	cases := map[string]struct {
+		header   string
+		body     string
+		wantTree bool
+		want     string
+	}{
+		"(valid)plain": {
+			header:   "Content-Type: text/plain\r\n",
+			body:     "body\r\n",
+			wantTree: true,
+			want:     "body\r\n",
+		},
+		"(valid)unknown charset": {
+			header:   "Content-Type: text/plain; charset=x-unknown\r\n",
+			body:     "raw body\r\n",
+			wantTree: true,
+			want:     "raw body\r\n",
+		},
+		"(invalid)truncated multipart": {
+			header: "Content-Type: multipart/mixed; boundary=b1\r\n",
+			body:   "--b1\r\nContent-Type: text/plain\r\n\r\ntruncated",
+			want:   "--b1\r\nContent-Type: text/plain\r\n\r\ntruncated",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := netmail.ReadMessage(strings.NewReader(tt.header + "\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, got := mail.ParseText(mail.Header(m.Header), []byte(tt.body))
+			if (p != nil) != tt.wantTree {
+				t.Fatalf("\n\tgot: %v\n\twant: tree %v", p, tt.wantTree)
+			}
+			if got != tt.want {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// Preference is the media type preferred among the alternatives.
+type Preference uint8
+
+const (
+	// PreferPlain prefers text/plain.
+	PreferPlain Preference = iota
+	// PreferHTML prefers text/html.
+	PreferHTML
+)
+
+// NewPreference returns the preference named "plain" or "html".
+// It returns PreferPlain for the other names.
+func NewPreference(s string) Preference {
+	if strings.EqualFold(s, "html") {
+		return PreferHTML
+	}
+	return PreferPlain
+}
+
+func (pref Preference) mediaType() string {
+	if pref == PreferHTML {
+		return "text/html"
+	}
+	return "text/plain"
+}
+
+// SelectBody returns the text/plain or text/html part to display, or nil if
+// the message has none.
+//
+// Among the alternatives the last one of the preferred media type is chosen,
+// falling back to the last displayable one. The root part of
+// multipart/related and the first displayable part of the other multiparts
+// are chosen.
+func (p *Part) SelectBody(pref Preference) *Part {
+	if !p.IsMultipart() {
+		if p.isInlineText() {
+			return p
+		}
+		return nil
+	}
+
+	switch p.MediaType {
+	case "multipart/alternative":
+		var fallback *Part
+		for i := len(p.Parts) - 1; i >= 0; i-- {
+			b := p.Parts[i].SelectBody(pref)
+			if b == nil {
+				continue
+			}
+			if b.MediaType == pref.mediaType() {
+				return b
+			}
+			if fallback == nil {
+				fallback = b
+			}
+		}
+		return fallback
+	case "multipart/related":
+		if root := p.relatedRoot(); root != nil {
+			return root.SelectBody(pref)
+		}
+		return nil
+	}
+	for _, c := range p.Parts {
+		if b := c.SelectBody(pref); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+func (p *Part) isInlineText() bool {
+	if p.MediaType != "text/plain" && p.MediaType != "text/html" {
+		return false
+	}
+	cd := mail.Header(p.Header).Get("Content-Disposition")
+	return !strings.HasPrefix(strings.ToLower(strings.TrimSpace(cd)), "attachment")
+}
+
+// relatedRoot returns the part named by the start parameter, or the first part.
+func (p *Part) relatedRoot() *Part {
+	if start := p.Params["start"]; start != "" {
+		cid := strings.TrimSuffix(strings.TrimPrefix(start, "<"), ">")
+		for _, c := range p.Parts {
+			if c.ContentID() == cid {
+				return c
+			}
+		}
+	}
+	if len(p.Parts) == 0 {
+		return nil
+	}
+	return p.Parts[0]
+}
@@ -0,0 +1,92 @@
+package mail_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/mail"
+)
+
+func Test_Part_SelectBody(t *testing.T) {
+	alternative := strings.Join([]string{
+		"Content-Type: multipart/alternative; boundary=alt",
+		"",
+		"--alt",
+		"Content-Type: text/plain",
+		"",
+		"plain",
+		"--alt",
+		"Content-Type: multipart/related; boundary=rel; start=\"<html@example.com>\"",
+		"",
+		"--rel",
+		"Content-Type: image/png",
+		"Content-ID: <logo@example.com>",
+		"",
+		"png",
+		"--rel",
+		"Content-Type: text/html",
+		"Content-ID: <html@example.com>",
+		"",
+		"<p>html</p>",
+		"--rel--",
+		"--alt--",
+		"",
+	}, "\r\n")
+	mixed := strings.Join([]string{
+		"Content-Type: multipart/mixed; boundary=mix",
+		"",
+		"--mix",
+		"Content-Type: multipart/alternative; boundary=alt",
+		"",
+		"--alt",
+		"Content-Type: text/html",
+		"",
+		"<p>html only</p>",
+		"--alt--",
+		"--mix",
+		"Content-Type: text/plain",
+		"Content-Disposition: attachment; filename=a.txt",
+		"",
+		"attachment",
+		"--mix--",
+		"",
+	}, "\r\n")
+
+	cases := map[string]struct {
+		input  string
+		pref   mail.Preference
+		wantID string
+	}{
+		"(valid)alternative prefer plain": {
+			input:  alternative,
+			pref:   mail.PreferPlain,
+			wantID: "1",
+		},
+		"(valid)alternative prefer html in related": {
+			input:  alternative,
+			pref:   mail.NewPreference("html"),
+			wantID: "2.2",
+		},
+		"(valid)html only prefer plain": {
+			input:  mixed,
+			pref:   mail.PreferPlain,
+			wantID: "1.1",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := mail.ReadPart(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("should not be error but %v", err)
+			}
+			got := p.SelectBody(tt.pref)
+			if got == nil {
+				t.Fatalf("should select a part")
+			}
+			if got.ID != tt.wantID {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got.ID, tt.wantID)
+			}
+		})
+	}
+}
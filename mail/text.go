@@ -0,0 +1,247 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockElements start on a new line.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true,
+	atom.Blockquote: true, atom.Center: true, atom.Dd: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Footer: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+// paragraphElements are separated by a blank line.
+var paragraphElements = map[atom.Atom]bool{
+	atom.Blockquote: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.P: true, atom.Pre: true,
+	atom.Table: true,
+}
+
+// HTMLToText converts the HTML body to the plain text for the terminal.
+// The links are written as "text <url>" and the list items are marked
+// with "*" or the number.
+func HTMLToText(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	t := &textWriter{}
+	t.node(doc)
+	t.flush()
+
+	var b strings.Builder
+	blank := true
+	for _, l := range t.lines {
+		l = strings.TrimRight(l, " ")
+		isBlank := strings.Trim(l, "> ") == ""
+		if isBlank && blank {
+			continue
+		}
+		blank = isBlank
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "> \n") + "\n", nil
+}
+
+type textWriter struct {
+	lines []string
+	cur   strings.Builder
+	// space is true if a space is pending before the next word.
+	space bool
+	// quote is the depth of the blockquote.
+	quote int
+	pre   int
+	// lists are the counters of the nested lists, -1 for ul.
+	lists []int
+	// marker is written before the first word of the list item.
+	marker string
+}
+
+func (t *textWriter) write(s string) {
+	if t.cur.Len() == 0 {
+		t.cur.WriteString(strings.Repeat("> ", t.quote))
+		if t.marker != "" {
+			t.cur.WriteString(t.marker)
+			t.marker = ""
+		}
+	} else if t.space {
+		t.cur.WriteString(" ")
+	}
+	t.space = false
+	t.cur.WriteString(s)
+}
+
+// flush ends the current line if it is not empty.
+func (t *textWriter) flush() {
+	if t.cur.Len() > 0 {
+		t.lines = append(t.lines, t.cur.String())
+		t.cur.Reset()
+	}
+	t.space = false
+}
+
+// br ends the current line even if it is empty.
+func (t *textWriter) br() {
+	if t.cur.Len() == 0 {
+		t.cur.WriteString(strings.Repeat("> ", t.quote))
+	}
+	t.lines = append(t.lines, t.cur.String())
+	t.cur.Reset()
+	t.space = false
+}
+
+// blank ends the current line and separates the next one by a blank line.
+func (t *textWriter) blank() {
+	t.flush()
+	if len(t.lines) > 0 && strings.Trim(t.lines[len(t.lines)-1], "> ") != "" {
+		t.lines = append(t.lines, strings.TrimSpace(strings.Repeat("> ", t.quote)))
+	}
+}
+
+func (t *textWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		t.textNode(n.Data)
+		return
+	case html.DocumentNode:
+		t.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if droppedElements[n.DataAtom] {
+		return
+	}
+
+	t.breakBlock(n.DataAtom)
+	switch n.DataAtom {
+	case atom.Br:
+		t.br()
+		return
+	case atom.Hr:
+		t.write("----")
+		t.flush()
+		return
+	case atom.Img:
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			t.space = t.space || t.cur.Len() > 0
+			t.write("[" + alt + "]")
+		}
+		return
+	case atom.Blockquote:
+		t.quote++
+		t.children(n)
+		t.quote--
+	case atom.Pre:
+		t.pre++
+		t.children(n)
+		t.pre--
+	case atom.Ul, atom.Ol:
+		counter := -1
+		if n.DataAtom == atom.Ol {
+			counter = 0
+		}
+		t.lists = append(t.lists, counter)
+		t.children(n)
+		t.lists = t.lists[:len(t.lists)-1]
+	case atom.Li:
+		marker := "* "
+		if i := len(t.lists) - 1; i >= 0 && t.lists[i] >= 0 {
+			t.lists[i]++
+			marker = fmt.Sprintf("%v. ", t.lists[i])
+		}
+		if len(t.lists) > 1 {
+			marker = strings.Repeat("  ", len(t.lists)-1) + marker
+		}
+		t.marker = marker
+		t.children(n)
+		t.marker = ""
+	case atom.A:
+		start := t.cur.Len()
+		lines := len(t.lines)
+		t.children(n)
+		href := strings.TrimSpace(attr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "#") {
+			break
+		}
+		label := ""
+		if len(t.lines) == lines && t.cur.Len() >= start {
+			label = strings.TrimSpace(t.cur.String()[start:])
+		}
+		switch {
+		case label == "" && len(t.lines) == lines:
+			t.space = t.space || t.cur.Len() > 0
+			t.write(href)
+		case label != href && label != strings.TrimPrefix(href, "mailto:"):
+			t.space = true
+			t.write("<" + href + ">")
+		}
+	case atom.Td, atom.Th:
+		if n.PrevSibling != nil && t.cur.Len() > 0 {
+			t.cur.WriteString("\t")
+			t.space = false
+		}
+		t.children(n)
+	default:
+		t.children(n)
+	}
+	t.breakBlock(n.DataAtom)
+}
+
+// breakBlock breaks the line before and after the block element.
+func (t *textWriter) breakBlock(a atom.Atom) {
+	switch {
+	case paragraphElements[a]:
+		t.blank()
+	case blockElements[a]:
+		t.flush()
+	}
+}
+
+func (t *textWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		t.node(c)
+	}
+}
+
+func (t *textWriter) textNode(s string) {
+	if s == "" {
+		return
+	}
+	if t.pre > 0 {
+		for i, l := range strings.Split(s, "\n") {
+			if i > 0 {
+				t.br()
+			}
+			if l != "" {
+				t.write(l)
+			}
+		}
+		return
+	}
+	if isSpace(s[0]) {
+		t.space = true
+	}
+	if words := strings.Fields(s); len(words) > 0 {
+		t.write(strings.Join(words, " "))
+	}
+	if isSpace(s[len(s)-1]) {
+		t.space = true
+	}
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
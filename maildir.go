@@ -1,16 +1,26 @@
 package goem
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/mail"
+	"os"
 	"path/filepath"
 
 	"github.com/tennashi/goem/maildir"
 )
 
+var (
+	// ErrEmptyMessage is returned when the delivered message is empty.
+	ErrEmptyMessage = errors.New("empty message")
+)
+
 // MaildirRoot is ...
 type MaildirRoot struct {
 	path string
+	// IgnoreQuota lets Deliver exceed the quota of the maildirs.
+	IgnoreQuota bool
 }
 
 // NewMaildirRoot is ...
@@ -32,8 +42,12 @@ func (r *MaildirRoot) Maildirs() ([]Maildir, error) {
 			continue
 		}
 		path := filepath.Join(r.path, dirInfo.Name())
+		if !maildir.IsMaildir(path) {
+			continue
+		}
 		md, err := NewMaildir(path)
 		if err != nil {
+			// a broken maildir doesn't hide the others.
 			continue
 		}
 		maildirs = append(maildirs, *md)
@@ -47,51 +61,135 @@ func (r *MaildirRoot) maildirPath(mdName string) string {
 
 // GetMails is ...
 func (r *MaildirRoot) GetMails(mdName, subDirName string) ([]Mail, error) {
-	path := r.maildirPath(mdName)
-	if !maildir.IsMaildir(path) {
-		return nil, fmt.Errorf("%v is not maildir", path)
+	mails, _, err := r.GetMailsPage(mdName, subDirName, 0, 0)
+	return mails, err
+}
+
+// GetMailsPage returns the mails in the sub directory ordered by the keys,
+// skipping offset mails and at most limit mails if limit is positive, and
+// the number of all the mails. Only the headers of the mails are read.
+func (r *MaildirRoot) GetMailsPage(mdName, subDirName string, offset, limit int) ([]Mail, int, error) {
+	md, err := r.openMaildir(mdName)
+	if err != nil {
+		return nil, 0, err
 	}
-	md, err := maildir.New(path)
+	sd := maildir.NewSubDir(subDirName)
+	if sd != maildir.SubDirNew && sd != maildir.SubDirCur {
+		return nil, 0, &Error{Code: CodeUnknownSubDir, Err: fmt.Errorf("unknown sub directory: %v", subDirName)}
+	}
+	keys, err := md.Keys(sd)
+	if err != nil {
+		return nil, 0, err
+	}
+	maildir.SortKey(keys)
+	total := len(keys)
+	if offset > len(keys) {
+		offset = len(keys)
+	}
+	keys = keys[offset:]
+	if limit > 0 && limit < len(keys) {
+		keys = keys[:limit]
+	}
+
+	mails := make([]Mail, len(keys))
+	for i, k := range keys {
+		m, err := readHeader(*md, k)
+		if err != nil {
+			return nil, 0, err
+		}
+		m.Size = mailSize(filepath.Join(md.Path, sd.String(), k.String()), k)
+		mails[i] = *m
+	}
+	return mails, total, nil
+}
+
+// readHeader reads the header of the mail. The body is not readable.
+func readHeader(md maildir.Maildir, k maildir.Key) (*Mail, error) {
+	f, err := md.Open(k)
 	if err != nil {
 		return nil, err
 	}
-	sd := maildir.NewSubDir(subDirName)
-	ms, err := md.Mails(sd)
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
 	if err != nil {
 		return nil, err
 	}
-	mails := make([]Mail, len(ms))
-	for i, m := range ms {
-		mail := NewMail(m)
-		mails[i] = *mail
+	return NewMail(maildir.Mail{Key: k, Message: msg}), nil
+}
+
+// mailSize returns the size in S= of the key or of the file.
+func mailSize(path string, k maildir.Key) int64 {
+	if size, ok := k.Size(); ok {
+		return size
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
 	}
-	return mails, nil
+	return info.Size()
 }
 
 // GetMail is ...
 func (r *MaildirRoot) GetMail(mdName, key string) (*Mail, error) {
-	path := r.maildirPath(mdName)
-	if !maildir.IsMaildir(path) {
-		return nil, fmt.Errorf("%v is not maildir", path)
-	}
-	md, err := maildir.New(path)
+	md, err := r.openMaildir(mdName)
 	if err != nil {
 		return nil, err
 	}
-	k, err := maildir.ParseKey(key)
+	k, err := parseKey(key)
 	if err != nil {
 		return nil, err
 	}
 	ml, err := md.Mail(k)
 	if err != nil {
-		return nil, err
+		return nil, mailError(mdName, key, err)
 	}
 	return NewMail(*ml), nil
 }
 
+// OpenMail opens the message file of the key as it is on the disk.
+func (r *MaildirRoot) OpenMail(mdName, key string) (*os.File, error) {
+	md, err := r.openMaildir(mdName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := md.Open(k)
+	if err != nil {
+		return nil, mailError(mdName, key, err)
+	}
+	return f, nil
+}
+
+// Quota returns the quota usage of the maildir, or nil if it has no quota.
+func (r *MaildirRoot) Quota(mdName string) (*maildir.Usage, error) {
+	md, err := r.openMaildir(mdName)
+	if err != nil {
+		return nil, err
+	}
+	return md.QuotaUsage()
+}
+
+// SetQuota sets the quota of the maildir. The zero quota removes it.
+func (r *MaildirRoot) SetQuota(mdName string, q maildir.Quota) (*maildir.Usage, error) {
+	md, err := r.openMaildir(mdName)
+	if err != nil {
+		return nil, err
+	}
+	return md.SetQuota(q)
+}
+
 // Maildir is ...
 type Maildir struct {
-	Name string
+	Name    string
+	Total   int
+	Unread  int
+	Flagged int
+	Size    int64
+	// Quota is nil if the maildir has no quota.
+	Quota *maildir.Usage
 }
 
 // NewMaildir is ...
@@ -99,7 +197,24 @@ func NewMaildir(path string) (*Maildir, error) {
 	if !maildir.IsMaildir(path) {
 		return nil, fmt.Errorf("%v is not maildir", path)
 	}
+	md, err := maildir.New(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := md.Stat()
+	if err != nil {
+		return nil, err
+	}
+	q, err := md.QuotaUsage()
+	if err != nil {
+		return nil, err
+	}
 	return &Maildir{
-		Name: filepath.Base(path),
+		Name:    filepath.Base(path),
+		Total:   st.Total,
+		Unread:  st.Unread,
+		Flagged: st.Flagged,
+		Size:    st.Size,
+		Quota:   q,
 	}, nil
 }
@@ -0,0 +1,152 @@
+package maildir
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var deliverySeq uint64
+
+// Create creates the maildir with the cur, new and tmp sub directories.
+func Create(path string) (*Maildir, error) {
+	md, err := New(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range []SubDir{SubDirCur, SubDirNew, SubDirTmp} {
+		if err := os.MkdirAll(filepath.Join(md.Path, s.String()), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return md, nil
+}
+
+// DeliverOption is the option for Deliver.
+type DeliverOption struct {
+	// SubDir is the destination, new if it is SubDirUnknown.
+	SubDir SubDir
+	// Flags are the flags of the delivered message.
+	Flags []string
+	// Time is the delivery time, now if it is zero.
+	Time time.Time
+	// Unique is the unique part of the key, generated if it is empty.
+	Unique string
+	// EnforceQuota refuses the delivery exceeding the quota with ErrQuotaExceeded.
+	EnforceQuota bool
+}
+
+// Deliver writes the message read from r into tmp and moves it into new or cur.
+func (md Maildir) Deliver(r io.Reader, opt DeliverOption) (Key, error) {
+	s := opt.SubDir
+	if s == SubDirUnknown {
+		s = SubDirNew
+	}
+	if s == SubDirTmp {
+		return Key{}, fmt.Errorf("cannot deliver into %v", s)
+	}
+	t := opt.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	uniq := opt.Unique
+	if uniq == "" {
+		uniq = uniqueName(t)
+	}
+	tmpPath := filepath.Join(md.Path, SubDirTmp.String(), uniq)
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return Key{}, err
+	}
+	defer os.Remove(tmpPath)
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return Key{}, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return Key{}, err
+	}
+	if err := f.Close(); err != nil {
+		return Key{}, err
+	}
+	if opt.EnforceQuota {
+		u, err := md.QuotaUsage()
+		if err != nil {
+			return Key{}, err
+		}
+		if u != nil && !u.Allows(size, 1) {
+			return Key{}, ErrQuotaExceeded
+		}
+	}
+	if !opt.Time.IsZero() {
+		if err := os.Chtimes(tmpPath, t, t); err != nil {
+			return Key{}, err
+		}
+	}
+
+	if opt.Unique == "" {
+		uniq = fmt.Sprintf("%v,S=%v", uniq, size)
+	}
+	// the messages in new have no info unless they are flagged.
+	name := uniq
+	if s != SubDirNew || len(opt.Flags) > 0 {
+		name = fmt.Sprintf("%v:2,%v", uniq, formatFlags(opt.Flags))
+	}
+	path := filepath.Join(md.Path, s.String(), name)
+	if err := os.Link(tmpPath, path); err != nil {
+		if !os.IsExist(err) {
+			err = os.Rename(tmpPath, path)
+		}
+		if err != nil {
+			return Key{}, err
+		}
+	}
+
+	md.updateQuota(size, 1)
+
+	k, err := ParseKey(name)
+	if err != nil {
+		return Key{}, err
+	}
+	k.subDir = s
+	return k, nil
+}
+
+func uniqueName(t time.Time) string {
+	seq := atomic.AddUint64(&deliverySeq, 1)
+	return fmt.Sprintf("%v.M%vP%vQ%v.%v",
+		t.Unix(), t.Nanosecond()/1000, os.Getpid(), seq, hostName())
+}
+
+func hostName() string {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		h = "localhost"
+	}
+	h = strings.Replace(h, "/", `\057`, -1)
+	h = strings.Replace(h, ",", `\054`, -1)
+	return strings.Replace(h, ":", `\072`, -1)
+}
+
+func formatFlags(flags []string) string {
+	fs := make([]string, 0, len(flags))
+	seen := make(map[string]bool, len(flags))
+	for _, f := range flags {
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		fs = append(fs, f)
+	}
+	sort.Strings(fs)
+	return strings.Join(fs, "")
+}
@@ -0,0 +1,260 @@
+package maildir
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultStaleAge is the age of the files in tmp regarded as abandoned.
+const DefaultStaleAge = 36 * time.Hour
+
+// ProblemType is the kind of the inconsistency found by Check.
+type ProblemType uint8
+
+const (
+	_ ProblemType = iota
+	// ProblemStaleTmp is the file left in tmp by the failed delivery.
+	ProblemStaleTmp
+	// ProblemDuplicate is the message in new whose unique part is also in cur.
+	ProblemDuplicate
+	// ProblemEmpty is the zero-byte message.
+	ProblemEmpty
+	// ProblemUnparsable is the file whose name is not a key.
+	ProblemUnparsable
+	// ProblemWrongSize is the message whose S= differs from the file size.
+	// It is never repaired since S= is in the unique part of the key, which
+	// the synchronization pairs the messages by.
+	ProblemWrongSize
+	// ProblemMisplaced is the message with the flags in new.
+	ProblemMisplaced
+)
+
+func (t ProblemType) String() string {
+	switch t {
+	case ProblemStaleTmp:
+		return "stale tmp file"
+	case ProblemDuplicate:
+		return "duplicate key"
+	case ProblemEmpty:
+		return "empty message"
+	case ProblemUnparsable:
+		return "unparsable file name"
+	case ProblemWrongSize:
+		return "wrong size"
+	case ProblemMisplaced:
+		return "flagged message in new"
+	}
+	return "unknown"
+}
+
+// Problem is the inconsistency found by Check.
+type Problem struct {
+	Type ProblemType
+	// Path is the path of the file relative to the maildir.
+	Path   string
+	Detail string
+	// Repaired is true if the problem has been repaired.
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%v: %v", p.Path, p.Type)
+	if p.Detail != "" {
+		s += " (" + p.Detail + ")"
+	}
+	if p.Repaired {
+		s += ": repaired"
+	}
+	return s
+}
+
+// CheckOption is the option for Check.
+type CheckOption struct {
+	// Repair repairs the problems. Otherwise they are only reported.
+	Repair bool
+	// StaleAge is the age of the stale files in tmp, DefaultStaleAge if zero.
+	StaleAge time.Duration
+}
+
+// Check finds the inconsistencies of the maildir and repairs them if
+// opt.Repair is true. The unparsable files, the wrong sizes and the
+// duplicates with the different contents are never repaired.
+func (md Maildir) Check(opt CheckOption) ([]Problem, error) {
+	staleAge := opt.StaleAge
+	if staleAge == 0 {
+		staleAge = DefaultStaleAge
+	}
+	c := &checker{md: md, repair: opt.Repair}
+
+	if err := c.checkTmp(time.Now().Add(-staleAge)); err != nil {
+		return nil, err
+	}
+	cur, err := c.checkMessages(SubDirCur)
+	if err != nil {
+		return nil, err
+	}
+	news, err := c.checkMessages(SubDirNew)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkDuplicates(news, cur); err != nil {
+		return nil, err
+	}
+	for _, k := range news {
+		if err := c.checkMisplaced(k, cur); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(c.problems, func(i, j int) bool { return c.problems[i].Path < c.problems[j].Path })
+	return c.problems, nil
+}
+
+type checker struct {
+	md       Maildir
+	repair   bool
+	problems []Problem
+}
+
+func (c *checker) report(t ProblemType, path, detail string, repair func() error) error {
+	p := Problem{Type: t, Path: path, Detail: detail}
+	if c.repair && repair != nil {
+		if err := repair(); err != nil {
+			return err
+		}
+		p.Repaired = true
+	}
+	c.problems = append(c.problems, p)
+	return nil
+}
+
+func (c *checker) path(rel string) string {
+	return filepath.Join(c.md.Path, rel)
+}
+
+func (c *checker) checkTmp(staleBefore time.Time) error {
+	infos, err := ioutil.ReadDir(c.path(SubDirTmp.String()))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() || !info.ModTime().Before(staleBefore) {
+			continue
+		}
+		rel := filepath.Join(SubDirTmp.String(), info.Name())
+		detail := "modified " + info.ModTime().Format(time.RFC3339)
+		if err := c.report(ProblemStaleTmp, rel, detail, func() error {
+			return os.Remove(c.path(rel))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMessages checks the files in the sub directory and returns the keys
+// of the messages remaining.
+func (c *checker) checkMessages(s SubDir) (map[string]Key, error) {
+	keys, skipped, err := c.md.ScanKeys(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range skipped {
+		rel := filepath.Join(s.String(), f.Name)
+		if err := c.report(ProblemUnparsable, rel, "", nil); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := make(map[string]Key, len(keys))
+	for _, k := range keys {
+		rel := filepath.Join(s.String(), k.Raw)
+		info, err := os.Stat(c.path(rel))
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() == 0 {
+			if err := c.report(ProblemEmpty, rel, "", func() error {
+				return os.Remove(c.path(rel))
+			}); err != nil {
+				return nil, err
+			}
+			if c.repair {
+				continue
+			}
+		}
+		if size, ok := k.Size(); ok && size != info.Size() && info.Size() > 0 {
+			detail := fmt.Sprintf("S=%v but %v bytes", size, info.Size())
+			if err := c.report(ProblemWrongSize, rel, detail, nil); err != nil {
+				return nil, err
+			}
+		}
+		ret[k.Unique()] = k
+	}
+	return ret, nil
+}
+
+// checkDuplicates finds the messages in new with the unique part also in
+// cur, removing the ones whose contents are the same as in cur.
+func (c *checker) checkDuplicates(news, cur map[string]Key) error {
+	for unique, k := range news {
+		ck, ok := cur[unique]
+		if !ok {
+			continue
+		}
+		rel := filepath.Join(SubDirNew.String(), k.Raw)
+		same, err := c.sameContents(rel, filepath.Join(SubDirCur.String(), ck.Raw))
+		if err != nil {
+			return err
+		}
+		var repair func() error
+		detail := "also in cur as " + ck.Raw
+		if same {
+			repair = func() error { return os.Remove(c.path(rel)) }
+		} else {
+			detail += " with different contents"
+		}
+		if err := c.report(ProblemDuplicate, rel, detail, repair); err != nil {
+			return err
+		}
+		if c.repair && same {
+			delete(news, unique)
+		}
+	}
+	return nil
+}
+
+func (c *checker) sameContents(a, b string) (bool, error) {
+	ab, err := ioutil.ReadFile(c.path(a))
+	if err != nil {
+		return false, err
+	}
+	bb, err := ioutil.ReadFile(c.path(b))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ab, bb), nil
+}
+
+// checkMisplaced finds the message with the flags in new, which should have
+// been moved into cur by the reader.
+func (c *checker) checkMisplaced(k Key, cur map[string]Key) error {
+	if len(k.Flags) == 0 {
+		return nil
+	}
+	var repair func() error
+	if _, dup := cur[k.Unique()]; !dup {
+		repair = func() error {
+			return os.Rename(c.path(filepath.Join(SubDirNew.String(), k.Raw)), c.path(filepath.Join(SubDirCur.String(), k.Raw)))
+		}
+	}
+	return c.report(ProblemMisplaced, filepath.Join(SubDirNew.String(), k.Raw), "", repair)
+}
@@ -24,6 +24,16 @@ const (
 	FlagTypeNormal
 )
 
+// Flags defined by the maildir specification.
+const (
+	FlagPassed  = "P"
+	FlagReplied = "R"
+	FlagSeen    = "S"
+	FlagTrashed = "T"
+	FlagDraft   = "D"
+	FlagFlagged = "F"
+)
+
 // Key is ...
 type Key struct {
 	Raw        string
@@ -40,44 +50,125 @@ func (k Key) String() string {
 	return k.Raw
 }
 
-// ParseKey is ..
+// Unique returns the unique part of the key without the info.
+func (k Key) Unique() string {
+	unique, _, _ := splitInfo(k.Raw)
+	return unique
+}
+
+// Info returns the info part after the separator such as "2,FS",
+// or "" if the key has no info.
+func (k Key) Info() string {
+	_, _, info := splitInfo(k.Raw)
+	return info
+}
+
+// withFlags returns the file name of the key with the flags, keeping the
+// info separator.
+func (k Key) withFlags(flags string) string {
+	unique, sep, _ := splitInfo(k.Raw)
+	if sep == "" {
+		sep = ":"
+	}
+	return unique + sep + "2," + flags
+}
+
+// altSeparators are used instead of ":" where it is not allowed in the file names.
+var altSeparators = []string{"!", ";"}
+
+// splitInfo splits the file name into the unique part, the info separator
+// and the info. The alternative separators are recognized only before
+// "1," or "2," since they can appear in the unique part.
+func splitInfo(name string) (string, string, string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], ":", name[i+1:]
+	}
+	for _, sep := range altSeparators {
+		i := strings.LastIndex(name, sep)
+		if i < 0 {
+			continue
+		}
+		if info := name[i+1:]; strings.HasPrefix(info, "1,") || strings.HasPrefix(info, "2,") {
+			return name[:i], sep, info
+		}
+	}
+	return name, "", ""
+}
+
+// SubDir returns the sub directory the key was found in.
+func (k Key) SubDir() SubDir {
+	return k.subDir
+}
+
+// HasFlag reports whether the key has the flag.
+func (k Key) HasFlag(flag string) bool {
+	for _, f := range k.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the message size recorded in the S= parameter.
+func (k Key) Size() (int64, bool) {
+	s, ok := k.Params["S"]
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// ParseKey parses the file name of the message.
+//
+// The key without the info, as delivered into new, and the info separated by
+// "!" or ";" are accepted. The flags are parsed only in the "2," info; the
+// experimental "1," info and the malformed info are kept in Raw only.
 func ParseKey(str string) (Key, error) {
 	k := Key{Raw: str}
+	unique, _, info := splitInfo(str)
 
-	pieces := strings.SplitN(k.Raw, ".", 3)
-	if len(pieces) < 3 {
+	pieces := strings.SplitN(unique, ".", 3)
+	if len(pieces) < 3 || pieces[2] == "" {
 		return Key{}, ErrCannotParse
 	}
 	sec, err := strconv.ParseUint(pieces[0], 10, 0)
 	if err != nil {
-		return Key{}, err
+		return Key{}, ErrCannotParse
 	}
 	k.Second = uint(sec)
 
-	k.DeliveryID, err = ParseID(pieces[1])
+	// the delivery ID is informative, so the unknown formats are accepted.
+	k.DeliveryID, _ = ParseID(pieces[1])
 
-	additionals := strings.SplitN(pieces[2], ":", 2)
-
-	h := strings.Split(additionals[0], ",")
+	h := strings.Split(pieces[2], ",")
 	k.HostName = h[0]
 
 	k.Params = make(map[string]string, len(h[1:]))
 	for _, param := range h[1:] {
 		kv := strings.SplitN(param, "=", 2)
 		if len(kv) < 2 {
-			return Key{}, ErrCannotParse
+			continue
 		}
 		k.Params[kv[0]] = kv[1]
 	}
 
-	flags := strings.Split(additionals[1], ",")
+	flags := strings.SplitN(info, ",", 2)
+	if len(flags) < 2 {
+		return k, nil
+	}
 	ft, err := strconv.ParseUint(flags[0], 10, 8)
 	if err != nil {
-		return Key{}, err
+		return k, nil
 	}
 	k.FlagType = FlagType(ft)
-
-	k.Flags = strings.Split(flags[1], "")
+	if k.FlagType == FlagTypeNormal && flags[1] != "" {
+		k.Flags = strings.Split(flags[1], "")
+	}
 
 	return k, nil
 }
@@ -38,6 +38,58 @@ func Test_ParseKey(t *testing.T) {
 			},
 			err: false,
 		},
+		"(valid)without info": {
+			input: "123.M1P2Q3.host,S=10",
+			want: maildir.Key{
+				Raw:        "123.M1P2Q3.host,S=10",
+				Second:     123,
+				DeliveryID: maildir.ID{MicroSecond: 1, PID: 2, Seq: 3},
+				HostName:   "host",
+				Params:     map[string]string{"S": "10"},
+			},
+		},
+		"(valid)empty flags with alternative separator": {
+			input: "123.456.host!2,",
+			want: maildir.Key{
+				Raw:        "123.456.host!2,",
+				Second:     123,
+				DeliveryID: maildir.ID{PID: 456},
+				HostName:   "host",
+				Params:     map[string]string{},
+				FlagType:   maildir.FlagTypeNormal,
+			},
+		},
+		"(valid)experimental info": {
+			input: "123.456.host:1,anything",
+			want: maildir.Key{
+				Raw:        "123.456.host:1,anything",
+				Second:     123,
+				DeliveryID: maildir.ID{PID: 456},
+				HostName:   "host",
+				Params:     map[string]string{},
+				FlagType:   maildir.FlagTypeExperimental,
+			},
+		},
+		"(valid)malformed info": {
+			input: "123.456.host:",
+			want: maildir.Key{
+				Raw:        "123.456.host:",
+				Second:     123,
+				DeliveryID: maildir.ID{PID: 456},
+				HostName:   "host",
+				Params:     map[string]string{},
+			},
+		},
+		"(invalid)foreign name": {
+			input: "README",
+			want:  maildir.Key{},
+			err:   true,
+		},
+		"(invalid)non numeric time": {
+			input: "abc.456.host:2,S",
+			want:  maildir.Key{},
+			err:   true,
+		},
 	}
 	for caseName, tt := range cases {
 		t.Run(caseName, func(t *testing.T) {
@@ -8,6 +8,13 @@ import (
 	"path/filepath"
 )
 
+var (
+	// ErrNotMaildir is returned when the directory is not a maildir.
+	ErrNotMaildir = errors.New("not maildir")
+	// ErrUnknownSubDir is returned for the unknown sub directory.
+	ErrUnknownSubDir = errors.New("unknown sub directory")
+)
+
 // SubDir is the subdirectory name.
 type SubDir uint8
 
@@ -66,6 +73,19 @@ func New(path string) (*Maildir, error) {
 	}, nil
 }
 
+// Open returns the existing maildir. The error is *os.PathError, whose Err
+// is ErrNotMaildir if the directory is not a maildir.
+func Open(path string) (*Maildir, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() || !IsMaildir(path) {
+		return nil, &os.PathError{Op: "open", Path: path, Err: ErrNotMaildir}
+	}
+	return New(path)
+}
+
 // Mail is ...
 type Mail struct {
 	Key     Key
@@ -75,7 +95,7 @@ type Mail struct {
 // Mails is ...
 func (md Maildir) Mails(s SubDir) ([]Mail, error) {
 	if s == SubDirUnknown {
-		return nil, errors.New("unknown sub directory")
+		return nil, ErrUnknownSubDir
 	}
 	keys, err := md.Keys(s)
 	if err != nil {
@@ -106,23 +126,82 @@ func (md Maildir) Mails(s SubDir) ([]Mail, error) {
 	return ms, nil
 }
 
-// Keys is ...
-func (md Maildir) Keys(s SubDir) ([]Key, error) {
+// SkippedFile is the file which cannot be parsed as a key.
+type SkippedFile struct {
+	Name string
+	Err  error
+}
+
+// ScanKeys returns the keys in the sub directory and the files skipped
+// because they are not the messages.
+func (md Maildir) ScanKeys(s SubDir) ([]Key, []SkippedFile, error) {
 	path := filepath.Join(md.Path, s.String())
-	rawKeys, err := ioutil.ReadDir(path)
+	infos, err := ioutil.ReadDir(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	keys := make([]Key, len(rawKeys))
-	for i, rawKey := range rawKeys {
-		key, err := ParseKey(rawKey.Name())
+	keys := make([]Key, 0, len(infos))
+	var skipped []SkippedFile
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		key, err := ParseKey(info.Name())
 		if err != nil {
-			return nil, err
+			skipped = append(skipped, SkippedFile{Name: info.Name(), Err: err})
+			continue
 		}
 		key.subDir = s
-		keys[i] = key
+		keys = append(keys, key)
+	}
+	return keys, skipped, nil
+}
+
+// Keys returns the keys in the sub directory, ignoring the files which
+// cannot be parsed.
+func (md Maildir) Keys(s SubDir) ([]Key, error) {
+	keys, _, err := md.ScanKeys(s)
+	return keys, err
+}
+
+// Stat is the summary of the messages in a maildir.
+type Stat struct {
+	Total   int
+	Unread  int
+	Flagged int
+	Size    int64
+}
+
+// Stat counts the messages in new and cur, skipping the files which
+// cannot be parsed as keys.
+// Messages in new and messages in cur without the seen flag are unread.
+func (md Maildir) Stat() (*Stat, error) {
+	st := &Stat{}
+	for _, s := range []SubDir{SubDirNew, SubDirCur} {
+		keys, _, err := md.ScanKeys(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			st.Total++
+			if s == SubDirNew || !key.HasFlag(FlagSeen) {
+				st.Unread++
+			}
+			if key.HasFlag(FlagFlagged) {
+				st.Flagged++
+			}
+			size, ok := key.Size()
+			if !ok {
+				info, err := os.Stat(filepath.Join(md.Path, s.String(), key.Raw))
+				if err != nil {
+					return nil, err
+				}
+				size = info.Size()
+			}
+			st.Size += size
+		}
 	}
-	return keys, nil
+	return st, nil
 }
 
 // Mail is ...
@@ -142,37 +221,107 @@ func (md Maildir) Mail(key Key) (*Mail, error) {
 	}, nil
 }
 
+// Open opens the message file of the key.
+func (md Maildir) Open(key Key) (*os.File, error) {
+	return md.openMail(&key)
+}
+
 func (md Maildir) openMail(key *Key) (*os.File, error) {
-	var p string
+	p, err := md.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// resolve returns the path of the message file.
+// The key without the sub directory is looked up in cur and then in new.
+func (md Maildir) resolve(key *Key) (string, error) {
 	switch key.subDir {
-	case SubDirCur:
-		p = filepath.Join(md.Path, "cur", key.String())
-	case SubDirNew:
-		p = filepath.Join(md.Path, "new", key.String())
-	default:
-		p = filepath.Join(md.Path, "cur", key.String())
+	case SubDirCur, SubDirNew:
+		return filepath.Join(md.Path, key.subDir.String(), key.String()), nil
 	}
 
-	f, err := os.Open(p)
+	p := filepath.Join(md.Path, "cur", key.String())
+	_, err := os.Stat(p)
+	if err == nil {
+		key.subDir = SubDirCur
+		return p, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	p = filepath.Join(md.Path, "new", key.String())
+	if _, err := os.Stat(p); err != nil {
+		return "", err
+	}
+	key.subDir = SubDirNew
+	return p, nil
+}
+
+// SetFlags renames the message with the flags and moves it into cur.
+func (md Maildir) SetFlags(key Key, flags []string) (Key, error) {
+	p, err := md.resolve(&key)
 	if err != nil {
-		if err != os.ErrNotExist {
-			return nil, err
-		}
-		p = filepath.Join(md.Path, "new", key.String())
-		f, err = os.Open(p)
-		if err != nil {
-			return nil, err
-		}
-		if key.subDir == SubDirUnknown {
-			key.subDir = SubDirNew
-		}
-		return f, err
+		return Key{}, err
+	}
+	name := key.withFlags(formatFlags(flags))
+	if err := os.Rename(p, filepath.Join(md.Path, "cur", name)); err != nil {
+		return Key{}, err
+	}
+	k, err := ParseKey(name)
+	if err != nil {
+		return Key{}, err
 	}
+	k.subDir = SubDirCur
+	return k, nil
+}
 
-	if key.subDir == SubDirUnknown {
-		key.subDir = SubDirCur
+// Move moves the message into the same sub directory of dst.
+func (md Maildir) Move(key Key, dst Maildir) (Key, error) {
+	p, err := md.resolve(&key)
+	if err != nil {
+		return Key{}, err
+	}
+	size, err := messageSize(key, p)
+	if err != nil {
+		return Key{}, err
+	}
+	if err := os.Rename(p, filepath.Join(dst.Path, key.subDir.String(), key.String())); err != nil {
+		return Key{}, err
+	}
+	md.updateQuota(-size, -1)
+	dst.updateQuota(size, 1)
+	return key, nil
+}
+
+// Remove removes the message.
+func (md Maildir) Remove(key Key) error {
+	p, err := md.resolve(&key)
+	if err != nil {
+		return err
+	}
+	size, err := messageSize(key, p)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	md.updateQuota(-size, -1)
+	return nil
+}
+
+// messageSize returns the size in S= or of the file.
+func messageSize(key Key, path string) (int64, error) {
+	if size, ok := key.Size(); ok {
+		return size, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
 	}
-	return f, nil
+	return info.Size(), nil
 }
 
 // IsMaildir is ...
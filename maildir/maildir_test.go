@@ -0,0 +1,321 @@
+package maildir_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+const testMessage = "Subject: test\r\n\r\nbody\r\n"
+
+func newTestMaildir(t *testing.T, files map[string]string) *maildir.Maildir {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "maildir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for _, s := range []string{"cur", "new", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, s), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	md, err := maildir.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
+
+func Test_Maildir_Stat(t *testing.T) {
+	cases := map[string]struct {
+		files map[string]string
+		want  maildir.Stat
+	}{
+		"(valid)empty": {
+			files: map[string]string{},
+			want:  maildir.Stat{},
+		},
+		"(valid)mixed": {
+			files: map[string]string{
+				"new/1.1.host,S=100:2,":  testMessage,
+				"cur/2.2.host,S=200:2,S": testMessage,
+				"cur/3.3.host:2,FS":      testMessage,
+				"cur/4.4.host,S=50:2,F":  testMessage,
+			},
+			want: maildir.Stat{
+				Total:   4,
+				Unread:  2,
+				Flagged: 2,
+				Size:    100 + 200 + int64(len(testMessage)) + 50,
+			},
+		},
+		"(valid)foreign files": {
+			files: map[string]string{
+				"new/1.1.host":         testMessage,
+				"new/.DS_Store":        "",
+				"cur/2.2.host:2,S":     testMessage,
+				"cur/.DS_Store":        "",
+				"cur/README":           "",
+				"cur/3.3.host,S=10:2,": testMessage,
+			},
+			want: maildir.Stat{
+				Total:  3,
+				Unread: 2,
+				Size:   2*int64(len(testMessage)) + 10,
+			},
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			md := newTestMaildir(t, tt.files)
+			got, err := md.Stat()
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Maildir_ScanKeys(t *testing.T) {
+	md := newTestMaildir(t, map[string]string{
+		"cur/1.1.host!2,S": testMessage,
+		"cur/2.2.host":     testMessage,
+		"cur/.hidden":      testMessage,
+		"cur/README":       testMessage,
+	})
+
+	keys, skipped, err := md.ScanKeys(maildir.SubDirCur)
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	var got []string
+	for _, k := range keys {
+		got = append(got, k.Raw)
+	}
+	if want := []string{"1.1.host!2,S", "2.2.host"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got, want)
+	}
+	got = nil
+	for _, s := range skipped {
+		got = append(got, s.Name)
+	}
+	if want := []string{".hidden", "README"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got, want)
+	}
+
+	// the alternative separator is kept.
+	k, err := md.SetFlags(keys[0], []string{"F", "S"})
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if want := "1.1.host!2,FS"; k.Raw != want {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", k.Raw, want)
+	}
+}
+
+func Test_Maildir_Check(t *testing.T) {
+	files := map[string]string{
+		"tmp/1.1.host":         "partial",
+		"tmp/2.2.host":         "writing",
+		"new/3.3.host":         testMessage,
+		"cur/3.3.host:2,S":     testMessage,
+		"new/4.4.host":         "",
+		"cur/5.5.host,S=1:2,S": testMessage,
+		"new/6.6.host:2,F":     testMessage,
+		"new/7.7.host,S=3:2,":  "abc",
+		"cur/README":           testMessage,
+		"new/8.8.host":         "different",
+		"cur/8.8.host:2,S":     testMessage,
+	}
+	want := []string{
+		"cur/5.5.host,S=1:2,S: wrong size",
+		"cur/README: unparsable file name",
+		"new/3.3.host: duplicate key",
+		"new/4.4.host: empty message",
+		"new/6.6.host:2,F: flagged message in new",
+		"new/8.8.host: duplicate key",
+		"tmp/1.1.host: stale tmp file",
+	}
+
+	for _, repair := range []bool{false, true} {
+		t.Run(fmt.Sprintf("repair=%v", repair), func(t *testing.T) {
+			md := newTestMaildir(t, files)
+			old := time.Now().Add(-48 * time.Hour)
+			if err := os.Chtimes(filepath.Join(md.Path, "tmp/1.1.host"), old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			problems, err := md.Check(maildir.CheckOption{Repair: repair})
+			if err != nil {
+				t.Fatalf("should not be error but %v", err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.Path+": "+p.Type.String())
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, want)
+			}
+			if !repair {
+				return
+			}
+
+			problems, err = md.Check(maildir.CheckOption{})
+			if err != nil {
+				t.Fatalf("should not be error but %v", err)
+			}
+			got = nil
+			for _, p := range problems {
+				got = append(got, p.Path+": "+p.Type.String())
+			}
+			// the problems which cannot be repaired safely remain.
+			remain := []string{
+				"cur/5.5.host,S=1:2,S: wrong size",
+				"cur/README: unparsable file name",
+				"new/8.8.host: duplicate key",
+			}
+			if !reflect.DeepEqual(got, remain) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, remain)
+			}
+			if _, err := os.Stat(filepath.Join(md.Path, "cur", "6.6.host:2,F")); err != nil {
+				t.Fatalf("flagged message should be moved into cur but %v", err)
+			}
+		})
+	}
+}
+
+func Test_Maildir_Quota(t *testing.T) {
+	md := newTestMaildir(t, map[string]string{
+		"cur/1.1.host,S=10:2,S": "0123456789",
+	})
+	if u, err := md.QuotaUsage(); err != nil || u != nil {
+		t.Fatalf("should be no quota but %v, %v", u, err)
+	}
+
+	q, err := maildir.ParseQuota("30S,2C")
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if _, err := md.SetQuota(q); err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+
+	opt := maildir.DeliverOption{EnforceQuota: true}
+	k, err := md.Deliver(strings.NewReader("0123456789"), opt)
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if _, err := md.Deliver(strings.NewReader("0"), opt); err != maildir.ErrQuotaExceeded {
+		t.Fatalf("should be quota exceeded by count but %v", err)
+	}
+	if err := md.Remove(k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.Deliver(strings.NewReader(strings.Repeat("0", 21)), opt); err != maildir.ErrQuotaExceeded {
+		t.Fatalf("should be quota exceeded by size but %v", err)
+	}
+	// the delivery without the enforcement goes over the quota.
+	if _, err := md.Deliver(strings.NewReader(strings.Repeat("0", 21)), maildir.DeliverOption{}); err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+
+	u, err := md.QuotaUsage()
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	want := maildir.Usage{Quota: maildir.Quota{Bytes: 30, Messages: 2}, Bytes: 31, Messages: 2}
+	if *u != want {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", *u, want)
+	}
+	if !u.Exceeded() {
+		t.Fatalf("should be exceeded")
+	}
+	if got := u.Quota.String(); got != "30S,2C" {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", got, "30S,2C")
+	}
+}
+
+func Test_Maildir_Deliver_brokenQuota(t *testing.T) {
+	md := newTestMaildir(t, map[string]string{})
+	// the delivered message is not refused for maildirsize unwritable.
+	if err := os.Mkdir(filepath.Join(md.Path, "maildirsize"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	k, err := md.Deliver(strings.NewReader(testMessage), maildir.DeliverOption{})
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(md.Path, "new", k.String())); err != nil {
+		t.Fatalf("should be delivered but %v", err)
+	}
+	if err := md.Remove(k); err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+}
+
+func Test_Open(t *testing.T) {
+	md := newTestMaildir(t, map[string]string{})
+	root := filepath.Dir(md.Path)
+	plain := filepath.Join(md.Path, "cur")
+
+	cases := map[string]struct {
+		path       string
+		wantErr    bool
+		notMaildir bool
+		notExist   bool
+	}{
+		"(valid)maildir": {
+			path: md.Path,
+		},
+		"(invalid)not maildir": {
+			path:       plain,
+			wantErr:    true,
+			notMaildir: true,
+		},
+		"(invalid)not exist": {
+			path:     filepath.Join(root, "nowhere-maildir"),
+			wantErr:  true,
+			notExist: true,
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			got, err := maildir.Open(tt.path)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("should not be error for %v but %v", tt.path, err)
+				}
+				if got.Path != tt.path {
+					t.Fatalf("\n\tgot: %v\n\twant: %v", got.Path, tt.path)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("should be error for %v but not", tt.path)
+			}
+			pe, ok := err.(*os.PathError)
+			if !ok {
+				t.Fatalf("should be *os.PathError but %v", err)
+			}
+			if (pe.Err == maildir.ErrNotMaildir) != tt.notMaildir || os.IsNotExist(err) != tt.notExist {
+				t.Fatalf("\n\tgot: %v\n\twant: not maildir %v, not exist %v", err, tt.notMaildir, tt.notExist)
+			}
+		})
+	}
+}
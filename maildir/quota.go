@@ -0,0 +1,220 @@
+package maildir
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrQuotaExceeded is returned when the delivery exceeds the quota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+const (
+	// sizeFile is the Maildir++ quota file.
+	sizeFile = "maildirsize"
+	// sizeFileLimit is the size of maildirsize which triggers the recalculation.
+	sizeFileLimit = 5120
+	// sizeFileTTL is the age of maildirsize recalculated when the quota is exceeded.
+	sizeFileTTL = 15 * time.Minute
+)
+
+// Quota is the Maildir++ quota. Zero means unlimited.
+type Quota struct {
+	Bytes    int64
+	Messages int64
+}
+
+// ParseQuota parses the quota definition such as "1000000S,1000C".
+func ParseQuota(s string) (Quota, error) {
+	var q Quota
+	for _, f := range strings.Split(strings.TrimSpace(s), ",") {
+		if f == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(f[:len(f)-1], 10, 64)
+		if err != nil || n < 0 {
+			return Quota{}, fmt.Errorf("invalid quota: %v", s)
+		}
+		switch f[len(f)-1] {
+		case 'S':
+			q.Bytes = n
+		case 'C':
+			q.Messages = n
+		default:
+			return Quota{}, fmt.Errorf("invalid quota: %v", s)
+		}
+	}
+	return q, nil
+}
+
+func (q Quota) String() string {
+	var fs []string
+	if q.Bytes > 0 {
+		fs = append(fs, fmt.Sprintf("%vS", q.Bytes))
+	}
+	if q.Messages > 0 {
+		fs = append(fs, fmt.Sprintf("%vC", q.Messages))
+	}
+	return strings.Join(fs, ",")
+}
+
+// IsZero reports whether the quota is unlimited.
+func (q Quota) IsZero() bool {
+	return q.Bytes == 0 && q.Messages == 0
+}
+
+// Usage is the usage of the maildir against the quota.
+type Usage struct {
+	Quota    Quota
+	Bytes    int64
+	Messages int64
+}
+
+// Exceeded reports whether the usage is over the quota.
+func (u Usage) Exceeded() bool {
+	return !u.Allows(0, 0)
+}
+
+// Allows reports whether the messages of the size can be added within the quota.
+func (u Usage) Allows(size, count int64) bool {
+	if u.Quota.Bytes > 0 && u.Bytes+size > u.Quota.Bytes {
+		return false
+	}
+	if u.Quota.Messages > 0 && u.Messages+count > u.Quota.Messages {
+		return false
+	}
+	return true
+}
+
+// QuotaUsage returns the usage recorded in maildirsize, recalculating it
+// when the file is large or stale. It returns nil if the maildir has no quota.
+func (md Maildir) QuotaUsage() (*Usage, error) {
+	u, info, err := md.readSizeFile()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() >= sizeFileLimit || u.Exceeded() && time.Since(info.ModTime()) > sizeFileTTL {
+		return md.recalculate(u.Quota)
+	}
+	return u, nil
+}
+
+// SetQuota writes maildirsize with the quota and the usage recalculated.
+// The zero quota removes maildirsize.
+func (md Maildir) SetQuota(q Quota) (*Usage, error) {
+	if q.IsZero() {
+		err := os.Remove(filepath.Join(md.Path, sizeFile))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	return md.recalculate(q)
+}
+
+func (md Maildir) readSizeFile() (*Usage, os.FileInfo, error) {
+	f, err := os.Open(filepath.Join(md.Path, sizeFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := &Usage{}
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		return nil, nil, fmt.Errorf("%v: empty %v", md.Path, sizeFile)
+	}
+	if u.Quota, err = ParseQuota(s.Text()); err != nil {
+		return nil, nil, err
+	}
+	for s.Scan() {
+		fs := strings.Fields(s.Text())
+		if len(fs) != 2 {
+			continue
+		}
+		size, err1 := strconv.ParseInt(fs[0], 10, 64)
+		count, err2 := strconv.ParseInt(fs[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		u.Bytes += size
+		u.Messages += count
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	return u, info, nil
+}
+
+// recalculate counts the messages and rewrites maildirsize atomically.
+func (md Maildir) recalculate(q Quota) (*Usage, error) {
+	u := &Usage{Quota: q}
+	for _, s := range []SubDir{SubDirNew, SubDirCur} {
+		keys, err := md.Keys(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			size, ok := k.Size()
+			if !ok {
+				info, err := os.Stat(filepath.Join(md.Path, s.String(), k.Raw))
+				if err != nil {
+					continue
+				}
+				size = info.Size()
+			}
+			u.Bytes += size
+			u.Messages++
+		}
+	}
+
+	tmp := filepath.Join(md.Path, SubDirTmp.String(), fmt.Sprintf("%v.%v", sizeFile, uniqueName(time.Now())))
+	content := fmt.Sprintf("%v\n%v %v\n", q, u.Bytes, u.Messages)
+	if err := ioutil.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filepath.Join(md.Path, sizeFile)); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return u, nil
+}
+
+// updateQuota appends the change of the usage to maildirsize if it exists.
+// It is called after the message is changed, so the error is only logged.
+func (md Maildir) updateQuota(size, count int64) {
+	if err := md.appendQuota(size, count); err != nil {
+		log.Printf("maildir: %v: update %v: %v", md.Path, sizeFile, err)
+	}
+}
+
+func (md Maildir) appendQuota(size, count int64) error {
+	f, err := os.OpenFile(filepath.Join(md.Path, sizeFile), os.O_WRONLY|os.O_APPEND, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%v %v\n", size, count); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,53 @@
+package goem_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tennashi/goem"
+)
+
+func Test_MaildirRoot_Maildirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "goem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dirs := []string{"INBOX/cur", "INBOX/new", "INBOX/tmp", "Broken/new", "Broken/tmp"}
+	files := map[string]string{
+		// the key delivered into new has no info.
+		"INBOX/new/1570000000.M1P1Q1.host":           "Subject: new\n\nbody\n",
+		"INBOX/cur/1570000100.M2P1Q1.host,S=30:2,FS": "Subject: cur\n\nbody\n",
+		"INBOX/cur/.DS_Store":                        "",
+		// cur which cannot be read breaks only its maildir.
+		"Broken/cur":   "",
+		"notmd/README": "",
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := goem.NewMaildirRoot(root).Maildirs()
+	if err != nil {
+		t.Fatalf("should not be error for %v but %v", root, err)
+	}
+	want := []goem.Maildir{{Name: "INBOX", Total: 2, Unread: 1, Flagged: 1, Size: 19 + 30}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n\tgot: %+v\n\twant: %+v", got, want)
+	}
+}
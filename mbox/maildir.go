@@ -0,0 +1,125 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// Import delivers the messages in the mbox into the maildir.
+// Recent messages are delivered into new and the others into cur.
+// It returns the number of the delivered messages.
+func Import(md *maildir.Maildir, r io.Reader, f Format) (int, error) {
+	mr := NewReader(r, f)
+	n := 0
+	for {
+		m, err := mr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		opt := maildir.DeliverOption{
+			SubDir: maildir.SubDirCur,
+			Flags:  m.Flags,
+			Time:   messageDate(m),
+		}
+		if m.Recent {
+			opt.SubDir = maildir.SubDirNew
+		}
+		if _, err := md.Deliver(io.MultiReader(bytes.NewReader(m.Header), m.Body), opt); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func messageDate(m *Message) time.Time {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Header))
+	if err == nil {
+		if t, err := msg.Header.Date(); err == nil {
+			return t
+		}
+	}
+	return m.Date
+}
+
+// Export writes the messages in new and cur of the maildir into the mbox in delivery order.
+// It returns the number of the written messages.
+func Export(w io.Writer, md *maildir.Maildir, f Format) (int, error) {
+	var keys []maildir.Key
+	for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		ks, err := md.Keys(s)
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, ks...)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].Second != keys[j].Second {
+			return keys[i].Second < keys[j].Second
+		}
+		return keys[i].Raw < keys[j].Raw
+	})
+
+	mw := NewWriter(w, f)
+	for i, k := range keys {
+		if err := exportMessage(mw, md, k); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+func exportMessage(mw *Writer, md *maildir.Maildir, k maildir.Key) error {
+	file, err := md.Open(k)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(file)
+	fields, blank, n, err := readHeader(br)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var header bytes.Buffer
+	sender := "MAILER-DAEMON"
+	for _, f := range fields {
+		switch f.name {
+		case "status", "x-status":
+			continue
+		case "content-length", "lines":
+			if mw.format == FormatMboxcl2 {
+				continue
+			}
+		case "return-path":
+			if s := strings.Trim(f.value(), "<>"); s != "" {
+				sender = s
+			}
+		}
+		header.Write(f.raw)
+	}
+	header.Write(blank)
+
+	return mw.WriteMessage(&Message{
+		From:   sender,
+		Date:   time.Unix(int64(k.Second), 0),
+		Flags:  k.Flags,
+		Recent: k.SubDir() == maildir.SubDirNew,
+		Header: header.Bytes(),
+		Body:   br,
+		Size:   info.Size() - n,
+	})
+}
@@ -0,0 +1,159 @@
+package mbox
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// Format is the mbox variant.
+type Format uint8
+
+const (
+	// FormatUnknown is unknown format.
+	FormatUnknown Format = iota
+	// FormatMboxo quotes only "From " lines and cannot be unquoted losslessly.
+	FormatMboxo
+	// FormatMboxrd quotes "From " lines with any number of ">".
+	FormatMboxrd
+	// FormatMboxcl2 delimits messages with the Content-Length header and never quotes.
+	FormatMboxcl2
+)
+
+// NewFormat is create Format instance.
+func NewFormat(str string) Format {
+	switch str {
+	case "mboxo":
+		return FormatMboxo
+	case "mboxrd":
+		return FormatMboxrd
+	case "mboxcl2":
+		return FormatMboxcl2
+	default:
+		return FormatUnknown
+	}
+}
+
+// String is ...
+func (f Format) String() string {
+	switch f {
+	case FormatMboxo:
+		return "mboxo"
+	case FormatMboxrd:
+		return "mboxrd"
+	case FormatMboxcl2:
+		return "mboxcl2"
+	default:
+		return "unknown"
+	}
+}
+
+// fromLineLayout is the date format of the From_ line.
+const fromLineLayout = "Mon Jan _2 15:04:05 2006"
+
+// Message is a message in the mbox.
+type Message struct {
+	// From is the envelope sender of the From_ line.
+	From string
+	// Date is the date of the From_ line.
+	Date time.Time
+	// Flags are the maildir flags converted from the Status and X-Status headers.
+	Flags []string
+	// Recent reports whether no MUA has seen the message, that is, it belongs to new.
+	Recent bool
+	// Header is the raw header block including the blank line without the
+	// Status, X-Status and Content-Length headers.
+	Header []byte
+	// Body is the unquoted body.
+	Body io.Reader
+	// Size is the size of the body, or -1 if it is unknown.
+	Size int64
+}
+
+// statusFlags maps the Status and X-Status letters to the maildir flags.
+var statusFlags = map[string]map[byte]string{
+	"status": {
+		'R': maildir.FlagSeen,
+	},
+	"x-status": {
+		'A': maildir.FlagReplied,
+		'F': maildir.FlagFlagged,
+		'T': maildir.FlagDraft,
+		'D': maildir.FlagTrashed,
+	},
+}
+
+func parseStatus(name, value string, m *Message) {
+	letters := statusFlags[name]
+	for i := 0; i < len(value); i++ {
+		if name == "status" && value[i] == 'O' {
+			m.Recent = false
+		}
+		if f, ok := letters[value[i]]; ok {
+			m.Flags = append(m.Flags, f)
+		}
+	}
+}
+
+func formatStatus(flags []string, recent bool) (string, string) {
+	var status, xStatus bytes.Buffer
+	for _, name := range []string{"status", "x-status"} {
+		for _, c := range []byte("RAFTD") {
+			f, ok := statusFlags[name][c]
+			if !ok || !hasFlag(flags, f) {
+				continue
+			}
+			if name == "status" {
+				status.WriteByte(c)
+			} else {
+				xStatus.WriteByte(c)
+			}
+		}
+	}
+	if !recent {
+		status.WriteByte('O')
+	}
+	return status.String(), xStatus.String()
+}
+
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// isFromLine reports whether the line is the From_ line.
+func isFromLine(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("From "))
+}
+
+// isQuotedFromLine reports whether the line is the From_ line quoted with one or more ">".
+func isQuotedFromLine(line []byte) bool {
+	i := 0
+	for i < len(line) && line[i] == '>' {
+		i++
+	}
+	return i > 0 && isFromLine(line[i:])
+}
+
+func isBlankLine(line []byte) bool {
+	return len(line) == 1 && line[0] == '\n' || len(line) == 2 && line[0] == '\r' && line[1] == '\n'
+}
+
+func parseFromLine(line []byte, m *Message) {
+	s := strings.TrimRight(string(line[len("From "):]), "\r\n")
+	fields := strings.SplitN(strings.TrimSpace(s), " ", 2)
+	m.From = fields[0]
+	if len(fields) < 2 {
+		return
+	}
+	if t, err := time.Parse(fromLineLayout, strings.TrimSpace(fields[1])); err == nil {
+		m.Date = t
+	}
+}
@@ -0,0 +1,160 @@
+package mbox_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/mbox"
+)
+
+type message struct {
+	From   string
+	Flags  []string
+	Recent bool
+	Header string
+	Body   string
+}
+
+func readAll(t *testing.T, r *mbox.Reader) []message {
+	t.Helper()
+	var ms []message
+	for {
+		m, err := r.Next()
+		if err != nil {
+			break
+		}
+		b, err := ioutil.ReadAll(m.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, message{
+			From:   m.From,
+			Flags:  m.Flags,
+			Recent: m.Recent,
+			Header: string(m.Header),
+			Body:   string(b),
+		})
+	}
+	return ms
+}
+
+func Test_Reader(t *testing.T) {
+	cases := map[string]struct {
+		format mbox.Format
+		input  string
+		want   []message
+	}{
+		"(valid)mboxrd": {
+			format: mbox.FormatMboxrd,
+			input: "From alice@example.com Mon Jan  2 15:04:05 2006\n" +
+				"Subject: one\nStatus: RO\nX-Status: F\n\n" +
+				">From here\n>>From there\n\n" +
+				"From bob@example.com Mon Jan  2 15:04:05 2006\n" +
+				"Subject: two\n\nbody\n\n",
+			want: []message{
+				{
+					From:   "alice@example.com",
+					Flags:  []string{maildir.FlagSeen, maildir.FlagFlagged},
+					Header: "Subject: one\n\n",
+					Body:   "From here\n>From there\n",
+				},
+				{
+					From:   "bob@example.com",
+					Recent: true,
+					Header: "Subject: two\n\n",
+					Body:   "body\n",
+				},
+			},
+		},
+		"(valid)mboxo": {
+			format: mbox.FormatMboxo,
+			input: "From alice@example.com Mon Jan  2 15:04:05 2006\n" +
+				"Subject: one\n\n>From here\n>>From there\n\n",
+			want: []message{
+				{
+					From:   "alice@example.com",
+					Recent: true,
+					Header: "Subject: one\n\n",
+					Body:   "From here\n>>From there\n",
+				},
+			},
+		},
+		"(valid)mboxcl2": {
+			format: mbox.FormatMboxcl2,
+			input: "From alice@example.com Mon Jan  2 15:04:05 2006\n" +
+				"Subject: one\nContent-Length: 16\n\nFrom here\n\nlast\n\n" +
+				"From bob@example.com Mon Jan  2 15:04:05 2006\n" +
+				"Subject: two\nContent-Length: 5\n\nbody\n\n",
+			want: []message{
+				{
+					From:   "alice@example.com",
+					Recent: true,
+					Header: "Subject: one\n\n",
+					Body:   "From here\n\nlast\n",
+				},
+				{
+					From:   "bob@example.com",
+					Recent: true,
+					Header: "Subject: two\n\n",
+					Body:   "body\n",
+				},
+			},
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			got := readAll(t, mbox.NewReader(strings.NewReader(tt.input), tt.format))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %#v\n\twant: %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ImportExport(t *testing.T) {
+	input := "From alice@example.com Mon Jan  2 15:04:05 2006\n" +
+		"Return-Path: <alice@example.com>\nDate: Mon, 02 Jan 2006 15:04:05 +0000\n" +
+		"Subject: one\nStatus: RO\nX-Status: AF\n\n" +
+		">From here\n\n" +
+		"From bob@example.com Tue Jan  3 15:04:05 2006\n" +
+		"Return-Path: <bob@example.com>\nDate: Tue, 03 Jan 2006 15:04:05 +0000\n" +
+		"Subject: two\n\nbody\n\n"
+
+	for _, f := range []mbox.Format{mbox.FormatMboxo, mbox.FormatMboxrd, mbox.FormatMboxcl2} {
+		t.Run(f.String(), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "mbox")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			md, err := maildir.Create(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := mbox.Import(md, strings.NewReader(input), mbox.FormatMboxrd)
+			if err != nil || n != 2 {
+				t.Fatalf("import: %v messages, %v", n, err)
+			}
+			cur, _ := md.Keys(maildir.SubDirCur)
+			if len(cur) != 1 || !reflect.DeepEqual(cur[0].Flags, []string{"F", "R", "S"}) {
+				t.Fatalf("cur keys: %v", cur)
+			}
+
+			var out bytes.Buffer
+			if n, err := mbox.Export(&out, md, f); err != nil || n != 2 {
+				t.Fatalf("export: %v messages, %v", n, err)
+			}
+			got := readAll(t, mbox.NewReader(&out, f))
+			want := readAll(t, mbox.NewReader(strings.NewReader(input), mbox.FormatMboxrd))
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("\n\tgot: %#v\n\twant: %#v", got, want)
+			}
+		})
+	}
+}
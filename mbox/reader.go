@@ -0,0 +1,219 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Reader reads the messages from the mbox one by one.
+type Reader struct {
+	br     *bufio.Reader
+	format Format
+	next   []byte
+	body   io.Reader
+}
+
+// NewReader is create Reader instance.
+func NewReader(r io.Reader, f Format) *Reader {
+	return &Reader{
+		br:     bufio.NewReaderSize(r, 64*1024),
+		format: f,
+	}
+}
+
+// Next returns the next message, or io.EOF if there are no more messages.
+// The body of the previous message is discarded.
+func (r *Reader) Next() (*Message, error) {
+	if r.body != nil {
+		if _, err := io.Copy(ioutil.Discard, r.body); err != nil {
+			return nil, err
+		}
+		r.body = nil
+	}
+	if r.next == nil {
+		if err := r.skipToFromLine(); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &Message{Recent: true, Size: -1}
+	parseFromLine(r.next, m)
+	r.next = nil
+
+	fields, blank, _, err := readHeader(r.br)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	contentLength := int64(-1)
+	var header bytes.Buffer
+	for _, f := range fields {
+		switch f.name {
+		case "status", "x-status":
+			parseStatus(f.name, f.value(), m)
+			continue
+		case "content-length", "lines":
+			if r.format != FormatMboxcl2 {
+				break
+			}
+			if f.name == "content-length" {
+				if l, err := strconv.ParseInt(f.value(), 10, 64); err == nil && l >= 0 {
+					contentLength = l
+				}
+			}
+			continue
+		}
+		header.Write(f.raw)
+	}
+	if blank == nil {
+		blank = []byte("\n")
+	}
+	header.Write(blank)
+	m.Header = header.Bytes()
+
+	if contentLength >= 0 {
+		m.Size = contentLength
+		r.body = io.LimitReader(r.br, contentLength)
+	} else {
+		r.body = &bodyReader{r: r, lineStart: true}
+	}
+	m.Body = r.body
+	return m, nil
+}
+
+func (r *Reader) skipToFromLine() error {
+	for {
+		line, err := r.br.ReadBytes('\n')
+		if isFromLine(line) {
+			r.next = line
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// field is a header field with its continuation lines.
+type field struct {
+	name string
+	raw  []byte
+}
+
+func (f field) value() string {
+	v := string(f.raw)
+	if i := strings.Index(v, ":"); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// readHeader reads the header fields up to the blank line.
+// It returns the blank line, nil if the input ends without it, and the number of bytes read.
+func readHeader(br *bufio.Reader) ([]field, []byte, int64, error) {
+	var fields []field
+	var n int64
+	for {
+		line, err := br.ReadBytes('\n')
+		n += int64(len(line))
+		if isBlankLine(line) {
+			return fields, line, n, nil
+		}
+		if len(line) > 0 {
+			if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+				last := &fields[len(fields)-1]
+				last.raw = append(last.raw, line...)
+			} else {
+				name := string(line)
+				if i := strings.Index(name, ":"); i >= 0 {
+					name = name[:i]
+				}
+				fields = append(fields, field{
+					name: strings.ToLower(strings.TrimSpace(name)),
+					raw:  line,
+				})
+			}
+		}
+		if err != nil {
+			return fields, nil, n, err
+		}
+	}
+}
+
+// bodyReader reads the body up to the next From_ line and unquotes it.
+type bodyReader struct {
+	r         *Reader
+	buf       []byte
+	pending   []byte
+	lineStart bool
+	done      bool
+	err       error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if b.done {
+			if b.err != nil {
+				return 0, b.err
+			}
+			return 0, io.EOF
+		}
+		b.fill()
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+func (b *bodyReader) fill() {
+	line, err := b.r.br.ReadSlice('\n')
+	lineEnd := err == nil
+	if err == bufio.ErrBufferFull {
+		err = nil
+	}
+	if err != nil {
+		b.done = true
+		if err != io.EOF {
+			b.err = err
+		}
+		if len(line) == 0 {
+			return
+		}
+	}
+
+	if b.lineStart {
+		switch {
+		case isFromLine(line):
+			b.r.next = append([]byte(nil), line...)
+			if !lineEnd && err == nil {
+				rest, err := b.r.br.ReadBytes('\n')
+				b.r.next = append(b.r.next, rest...)
+				if err != nil && err != io.EOF {
+					b.err = err
+				}
+			}
+			b.done = true
+			b.pending = nil
+			return
+		case isBlankLine(line) && err == nil:
+			b.buf = b.pending
+			b.pending = append([]byte(nil), line...)
+			return
+		case b.r.format == FormatMboxrd && isQuotedFromLine(line):
+			line = line[1:]
+		case b.r.format == FormatMboxo && isQuotedFromLine(line) && line[1] != '>':
+			line = line[1:]
+		}
+	}
+	b.lineStart = lineEnd
+
+	if b.pending != nil {
+		b.buf = append(b.pending, line...)
+		b.pending = nil
+		return
+	}
+	b.buf = line
+}
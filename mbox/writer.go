@@ -0,0 +1,132 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Writer writes the messages into the mbox.
+type Writer struct {
+	w      *bufio.Writer
+	format Format
+}
+
+// NewWriter is create Writer instance.
+func NewWriter(w io.Writer, f Format) *Writer {
+	return &Writer{
+		w:      bufio.NewWriterSize(w, 64*1024),
+		format: f,
+	}
+}
+
+// WriteMessage writes the From_ line, the header with the Status and X-Status
+// headers converted from the flags, and the quoted body.
+func (w *Writer) WriteMessage(m *Message) error {
+	from := m.From
+	if from == "" {
+		from = "MAILER-DAEMON"
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	fmt.Fprintf(w.w, "From %v %v\n", from, date.UTC().Format(fromLineLayout))
+
+	w.w.Write(trimBlankLine(m.Header))
+	status, xStatus := formatStatus(m.Flags, m.Recent)
+	if status != "" {
+		fmt.Fprintf(w.w, "Status: %v\n", status)
+	}
+	if xStatus != "" {
+		fmt.Fprintf(w.w, "X-Status: %v\n", xStatus)
+	}
+
+	body := m.Body
+	if body == nil {
+		body = bytes.NewReader(nil)
+	}
+	var last byte
+	if w.format == FormatMboxcl2 {
+		size := m.Size
+		if size < 0 {
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			size = int64(len(b))
+			body = bytes.NewReader(b)
+		}
+		fmt.Fprintf(w.w, "Content-Length: %v\n\n", size)
+		lw := &lastByteWriter{w: w.w}
+		if _, err := io.CopyN(lw, body, size); err != nil {
+			return err
+		}
+		last = lw.last
+	} else {
+		w.w.WriteString("\n")
+		var err error
+		last, err = w.writeQuoted(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	if last != '\n' {
+		w.w.WriteString("\n")
+	}
+	w.w.WriteString("\n")
+	return w.w.Flush()
+}
+
+func (w *Writer) writeQuoted(r io.Reader) (byte, error) {
+	br := bufio.NewReader(r)
+	lineStart := true
+	var last byte
+	for {
+		line, err := br.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return last, err
+		}
+		if lineStart && (isFromLine(line) || w.format == FormatMboxrd && isQuotedFromLine(line)) {
+			w.w.WriteByte('>')
+		}
+		w.w.Write(line)
+		if len(line) > 0 {
+			last = line[len(line)-1]
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		lineStart = err == nil
+	}
+}
+
+func trimBlankLine(header []byte) []byte {
+	switch {
+	case isBlankLine(header):
+		return nil
+	case bytes.HasSuffix(header, []byte("\r\n\r\n")):
+		return header[:len(header)-2]
+	case bytes.HasSuffix(header, []byte("\n\n")):
+		return header[:len(header)-1]
+	case len(header) == 0, bytes.HasSuffix(header, []byte("\n")):
+		return header
+	}
+	return append(append([]byte(nil), header...), '\n')
+}
+
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (w *lastByteWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.last = p[len(p)-1]
+	}
+	return w.w.Write(p)
+}
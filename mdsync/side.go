@@ -0,0 +1,152 @@
+package mdsync
+
+import (
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// entry is the message found in a root.
+type entry struct {
+	folder string
+	key    maildir.Key
+}
+
+func (e *entry) flags() string {
+	return strings.Join(e.key.Flags, "")
+}
+
+// side is the messages under a root keyed by the unique part of the key.
+type side struct {
+	root    string
+	folders map[string]*maildir.Maildir
+	entries map[string]*entry
+}
+
+func scan(root string) (*side, error) {
+	s := &side{
+		root:    root,
+		folders: map[string]*maildir.Maildir{},
+		entries: map[string]*entry{},
+	}
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		path := filepath.Join(root, info.Name())
+		if !info.IsDir() || !maildir.IsMaildir(path) {
+			continue
+		}
+		md, err := maildir.New(path)
+		if err != nil {
+			return nil, err
+		}
+		s.folders[info.Name()] = md
+		for _, sd := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+			keys, err := md.Keys(sd)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				s.entries[k.Unique()] = &entry{folder: info.Name(), key: k}
+			}
+		}
+	}
+	return s, nil
+}
+
+// maildir returns the maildir of the folder, creating it if needed.
+func (s *side) maildir(folder string) (*maildir.Maildir, error) {
+	if md, ok := s.folders[folder]; ok {
+		return md, nil
+	}
+	md, err := maildir.Create(filepath.Join(s.root, folder))
+	if err != nil {
+		return nil, err
+	}
+	s.folders[folder] = md
+	return md, nil
+}
+
+// messageID returns the Message-ID of the message, or "" if it has none.
+func (s *side) messageID(e *entry) string {
+	f, err := s.folders[e.folder].Open(e.key)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	m, err := mail.ReadMessage(f)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(m.Header.Get("Message-Id"))
+}
+
+// copy delivers the message into dst keeping the unique part of the key,
+// the sub directory, the flags and the modification time.
+func (s *side) copy(e *entry, dst *side, folder string) (*entry, error) {
+	md, err := dst.maildir(folder)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.folders[e.folder].Open(e.key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	k, err := md.Deliver(f, maildir.DeliverOption{
+		SubDir: e.key.SubDir(),
+		Flags:  e.key.Flags,
+		Time:   info.ModTime(),
+		Unique: e.key.Unique(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ne := &entry{folder: folder, key: k}
+	dst.entries[k.Unique()] = ne
+	return ne, nil
+}
+
+func (s *side) move(e *entry, folder string) error {
+	md, err := s.maildir(folder)
+	if err != nil {
+		return err
+	}
+	k, err := s.folders[e.folder].Move(e.key, *md)
+	if err != nil {
+		return err
+	}
+	e.folder, e.key = folder, k
+	return nil
+}
+
+func (s *side) setFlags(e *entry, flags string) error {
+	var fs []string
+	if flags != "" {
+		fs = strings.Split(flags, "")
+	}
+	k, err := s.folders[e.folder].SetFlags(e.key, fs)
+	if err != nil {
+		return err
+	}
+	e.key = k
+	return nil
+}
+
+func (s *side) remove(e *entry) error {
+	if err := s.folders[e.folder].Remove(e.key); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.entries, e.key.Unique())
+	return nil
+}
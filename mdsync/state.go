@@ -0,0 +1,62 @@
+package mdsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// state is the snapshot of the messages paired at the last synchronization.
+type state struct {
+	Remote  string    `json:"remote"`
+	Records []*record `json:"records"`
+}
+
+// record is the pair of the messages synchronized at the last time.
+type record struct {
+	// Local and Remote are the unique parts of the keys on each side.
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	// Folder is the maildir name the messages were in.
+	Folder string `json:"folder"`
+	// Flags are the maildir flags the messages had.
+	Flags string `json:"flags"`
+}
+
+// stateFile returns the path of the state in the local root for the remote root.
+func stateFile(local, remote string) string {
+	h := fnv.New32a()
+	h.Write([]byte(remote))
+	return filepath.Join(local, fmt.Sprintf(".goem-mdsync-%08x.json", h.Sum32()))
+}
+
+func loadState(path string) (*state, error) {
+	st := &state{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// save writes the state into the temporary file and renames it.
+func (st *state) save(path string) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,271 @@
+// Package mdsync synchronizes two roots of maildirs in both directions.
+package mdsync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// Result is the summary of the synchronization.
+type Result struct {
+	// Copied is the number of the messages copied to the other side.
+	Copied int
+	// Moved is the number of the messages moved to another folder.
+	Moved int
+	// Removed is the number of the messages removed.
+	Removed int
+	// Updated is the number of the messages whose flags were changed.
+	Updated int
+	// Conflicts describe the changes made on both sides. The local one wins
+	// except that a changed message is kept rather than removed.
+	Conflicts []string
+}
+
+// Syncer synchronizes the local root with the remote root.
+//
+// The messages are paired by the unique part of the key, or by Message-ID
+// when first seen, and the changes since the last synchronization are
+// detected against the snapshot stored in the local root.
+type Syncer struct {
+	local  string
+	remote string
+}
+
+// New is ...
+func New(local, remote string) *Syncer {
+	return &Syncer{
+		local:  local,
+		remote: remote,
+	}
+}
+
+// Sync propagates the new messages, the flag changes, the moves between the
+// folders and the removals in both directions.
+func (s *Syncer) Sync() (res *Result, err error) {
+	local, err := scan(s.local)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := scan(s.remote)
+	if err != nil {
+		return nil, err
+	}
+	statePath := stateFile(s.local, s.remote)
+	st, err := loadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	if st.Remote != "" && st.Remote != s.remote {
+		return nil, fmt.Errorf("state %v is for %v", statePath, st.Remote)
+	}
+
+	res = &Result{}
+	next := &state{Remote: s.remote}
+	done := 0
+	defer func() {
+		if err != nil && done < len(st.Records) {
+			// the records not synchronized yet are kept as they were.
+			next.Records = append(next.Records, st.Records[done:]...)
+		}
+		if serr := next.save(statePath); err == nil {
+			err = serr
+		}
+	}()
+
+	paired := map[*entry]bool{}
+	for _, r := range st.Records {
+		l, rm := local.entries[r.Local], remote.entries[r.Remote]
+		nr, err := s.syncRecord(res, local, remote, r, l, rm)
+		if err != nil {
+			return res, err
+		}
+		if nr != nil {
+			next.Records = append(next.Records, nr)
+			paired[local.entries[nr.Local]] = true
+			paired[remote.entries[nr.Remote]] = true
+		}
+		done++
+	}
+
+	// the messages unknown at the last time.
+	newLocal := unpaired(local, paired)
+	newRemote := unpaired(remote, paired)
+	byMessageID := map[string]*entry{}
+	for _, e := range newRemote {
+		if id := remote.messageID(e); id != "" {
+			byMessageID[id] = e
+		}
+	}
+	for _, l := range newLocal {
+		rm, ok := remote.entries[l.key.Unique()]
+		if !ok || paired[rm] {
+			rm = byMessageID[local.messageID(l)]
+		}
+		if rm != nil && !paired[rm] {
+			r, err := s.pair(res, local, remote, l, rm)
+			if err != nil {
+				return res, err
+			}
+			next.Records = append(next.Records, r)
+			paired[rm] = true
+			continue
+		}
+		rm, err := local.copy(l, remote, l.folder)
+		if err != nil {
+			return res, err
+		}
+		res.Copied++
+		paired[rm] = true
+		next.Records = append(next.Records, newRecord(l, rm))
+	}
+	for _, rm := range newRemote {
+		if paired[rm] {
+			continue
+		}
+		l, err := remote.copy(rm, local, rm.folder)
+		if err != nil {
+			return res, err
+		}
+		res.Copied++
+		next.Records = append(next.Records, newRecord(l, rm))
+	}
+	return res, nil
+}
+
+// syncRecord propagates the changes of the paired messages and returns the
+// record for the next time, or nil if the messages are gone.
+func (s *Syncer) syncRecord(res *Result, local, remote *side, r *record, l, rm *entry) (*record, error) {
+	switch {
+	case l == nil && rm == nil:
+		return nil, nil
+	case l == nil:
+		if rm.folder == r.Folder && rm.flags() == r.Flags {
+			res.Removed++
+			return nil, remote.remove(rm)
+		}
+		res.Conflicts = append(res.Conflicts, fmt.Sprintf("%v: removed locally but changed remotely", rm.key.Unique()))
+		l, err := remote.copy(rm, local, rm.folder)
+		if err != nil {
+			return nil, err
+		}
+		res.Copied++
+		return newRecord(l, rm), nil
+	case rm == nil:
+		if l.folder == r.Folder && l.flags() == r.Flags {
+			res.Removed++
+			return nil, local.remove(l)
+		}
+		res.Conflicts = append(res.Conflicts, fmt.Sprintf("%v: removed remotely but changed locally", l.key.Unique()))
+		rm, err := local.copy(l, remote, l.folder)
+		if err != nil {
+			return nil, err
+		}
+		res.Copied++
+		return newRecord(l, rm), nil
+	}
+
+	switch {
+	case l.folder == rm.folder:
+	case rm.folder == r.Folder:
+		if err := remote.move(rm, l.folder); err != nil {
+			return nil, err
+		}
+		res.Moved++
+	case l.folder == r.Folder:
+		if err := local.move(l, rm.folder); err != nil {
+			return nil, err
+		}
+		res.Moved++
+	default:
+		res.Conflicts = append(res.Conflicts, fmt.Sprintf("%v: moved to %v locally and to %v remotely", l.key.Unique(), l.folder, rm.folder))
+		if err := remote.move(rm, l.folder); err != nil {
+			return nil, err
+		}
+		res.Moved++
+	}
+
+	if err := s.syncFlags(res, local, remote, l, rm, mergeFlags(r.Flags, l.flags(), rm.flags())); err != nil {
+		return nil, err
+	}
+	return newRecord(l, rm), nil
+}
+
+// pair pairs the messages found on both sides for the first time.
+func (s *Syncer) pair(res *Result, local, remote *side, l, rm *entry) (*record, error) {
+	if l.folder != rm.folder {
+		res.Conflicts = append(res.Conflicts, fmt.Sprintf("%v: in %v locally and in %v remotely", l.key.Unique(), l.folder, rm.folder))
+		if err := remote.move(rm, l.folder); err != nil {
+			return nil, err
+		}
+		res.Moved++
+	}
+	if err := s.syncFlags(res, local, remote, l, rm, mergeFlags("", l.flags(), rm.flags())); err != nil {
+		return nil, err
+	}
+	return newRecord(l, rm), nil
+}
+
+func (s *Syncer) syncFlags(res *Result, local, remote *side, l, rm *entry, flags string) error {
+	// a message moved from new into cur has been seen by the user.
+	inCur := l.key.SubDir() == maildir.SubDirCur || rm.key.SubDir() == maildir.SubDirCur
+	for _, x := range []struct {
+		side *side
+		e    *entry
+	}{{local, l}, {remote, rm}} {
+		if x.e.flags() == flags && (x.e.key.SubDir() == maildir.SubDirCur || !inCur) {
+			continue
+		}
+		if err := x.side.setFlags(x.e, flags); err != nil {
+			return err
+		}
+		res.Updated++
+	}
+	return nil
+}
+
+func newRecord(l, rm *entry) *record {
+	return &record{
+		Local:  l.key.Unique(),
+		Remote: rm.key.Unique(),
+		Folder: l.folder,
+		Flags:  l.flags(),
+	}
+}
+
+// unpaired returns the messages not paired yet in the order of the keys.
+func unpaired(s *side, paired map[*entry]bool) []*entry {
+	var es []*entry
+	for _, e := range s.entries {
+		if !paired[e] {
+			es = append(es, e)
+		}
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].key.Unique() < es[j].key.Unique() })
+	return es
+}
+
+// allFlags are the maildir flags in ASCII order.
+const allFlags = "DFPRST"
+
+// mergeFlags merges the flags changed locally and remotely since base.
+// The result is sorted.
+func mergeFlags(base, local, remote string) string {
+	var b strings.Builder
+	for _, f := range allFlags {
+		inBase := strings.ContainsRune(base, f)
+		inLocal := strings.ContainsRune(local, f)
+		if inLocal != inBase {
+			if inLocal {
+				b.WriteRune(f)
+			}
+			continue
+		}
+		if strings.ContainsRune(remote, f) {
+			b.WriteRune(f)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,263 @@
+package mdsync_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/mdsync"
+)
+
+func deliver(t *testing.T, root, folder, body string, flags ...string) maildir.Key {
+	t.Helper()
+	md, err := maildir.Create(filepath.Join(root, folder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := maildir.DeliverOption{SubDir: maildir.SubDirNew, Flags: flags}
+	if len(flags) > 0 {
+		opt.SubDir = maildir.SubDirCur
+	}
+	k, err := md.Deliver(strings.NewReader(body), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// snapshot returns the folder, the sub directory and the flags of the messages keyed by the bodies.
+func snapshot(t *testing.T, root string) map[string]string {
+	t.Helper()
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := map[string]string{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		md, _ := maildir.New(filepath.Join(root, info.Name()))
+		for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+			keys, err := md.Keys(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range keys {
+				f, _ := md.Open(k)
+				b, _ := ioutil.ReadAll(f)
+				f.Close()
+				ms[string(b)] = info.Name() + "/" + s.String() + ":" + strings.Join(k.Flags, "")
+			}
+		}
+	}
+	return ms
+}
+
+func find(t *testing.T, root, folder, body string) (*maildir.Maildir, maildir.Key) {
+	t.Helper()
+	md, _ := maildir.New(filepath.Join(root, folder))
+	for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		keys, _ := md.Keys(s)
+		for _, k := range keys {
+			f, _ := md.Open(k)
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			if string(b) == body {
+				return md, k
+			}
+		}
+	}
+	t.Fatalf("%q not found in %v", body, folder)
+	return nil, maildir.Key{}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "mdsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func Test_Syncer_Sync(t *testing.T) {
+	local, remote := tempDir(t), tempDir(t)
+	defer os.RemoveAll(local)
+	defer os.RemoveAll(remote)
+
+	const (
+		one   = "Subject: one\n\n1\n"
+		two   = "Subject: two\n\n2\n"
+		three = "Message-ID: <three@example.com>\n\n3\n"
+	)
+	deliver(t, local, "INBOX", one)
+	deliver(t, remote, "INBOX", two, "S")
+	// the same message delivered to both sides independently.
+	deliver(t, local, "INBOX", three)
+	deliver(t, remote, "INBOX", three, "F")
+
+	s := mdsync.New(local, remote)
+	sync := func() *mdsync.Result {
+		t.Helper()
+		res, err := s.Sync()
+		if err != nil {
+			t.Fatalf("should not be error but %v", err)
+		}
+		if got, want := snapshot(t, local), snapshot(t, remote); !reflect.DeepEqual(got, want) {
+			t.Fatalf("roots differ\n\tlocal: %v\n\tremote: %v", got, want)
+		}
+		return res
+	}
+
+	sync()
+	want := map[string]string{
+		one:   "INBOX/new:",
+		two:   "INBOX/cur:S",
+		three: "INBOX/cur:F",
+	}
+	if got := snapshot(t, local); !reflect.DeepEqual(got, want) {
+		t.Fatalf("initial\n\tgot: %v\n\twant: %v", got, want)
+	}
+
+	// flags changed on both sides, a move and a removal.
+	md, k := find(t, local, "INBOX", one)
+	if _, err := md.SetFlags(k, []string{"S"}); err != nil {
+		t.Fatal(err)
+	}
+	md, k = find(t, remote, "INBOX", three)
+	if _, err := md.SetFlags(k, []string{"F", "R"}); err != nil {
+		t.Fatal(err)
+	}
+	md, k = find(t, remote, "INBOX", two)
+	archive, err := maildir.Create(filepath.Join(remote, "Archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.Move(k, *archive); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	want = map[string]string{
+		one:   "INBOX/cur:S",
+		two:   "Archive/cur:S",
+		three: "INBOX/cur:FR",
+	}
+	if got := snapshot(t, local); !reflect.DeepEqual(got, want) {
+		t.Fatalf("changed\n\tgot: %v\n\twant: %v", got, want)
+	}
+
+	// removed locally, and removed remotely while changed locally.
+	md, k = find(t, local, "INBOX", one)
+	if err := md.Remove(k); err != nil {
+		t.Fatal(err)
+	}
+	md, k = find(t, remote, "INBOX", three)
+	if err := md.Remove(k); err != nil {
+		t.Fatal(err)
+	}
+	md, k = find(t, local, "INBOX", three)
+	if _, err := md.SetFlags(k, []string{"F"}); err != nil {
+		t.Fatal(err)
+	}
+	res := sync()
+	want = map[string]string{
+		two:   "Archive/cur:S",
+		three: "INBOX/cur:F",
+	}
+	if got := snapshot(t, local); !reflect.DeepEqual(got, want) {
+		t.Fatalf("removed\n\tgot: %v\n\twant: %v", got, want)
+	}
+	if len(res.Conflicts) != 1 {
+		t.Fatalf("conflicts: %v", res.Conflicts)
+	}
+}
+
+func Test_Syncer_Sync_failure(t *testing.T) {
+	local, remote := tempDir(t), tempDir(t)
+	defer os.RemoveAll(local)
+	defer os.RemoveAll(remote)
+
+	const (
+		one = "Subject: one\n\n1\n"
+		two = "Subject: two\n\n2\n"
+	)
+	deliver(t, local, "INBOX", one, "S")
+	deliver(t, local, "INBOX", two, "S")
+	s := mdsync.New(local, remote)
+	if _, err := s.Sync(); err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+
+	// one moved into X fails since X is a file remotely, before two is
+	// synchronized.
+	md, k := find(t, local, "INBOX", one)
+	x, err := maildir.Create(filepath.Join(local, "X"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.Move(k, *x); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(remote, "X"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	md, k = find(t, local, "INBOX", two)
+	if _, err := md.SetFlags(k, nil); err != nil {
+		t.Fatal(err)
+	}
+	orderRecords(t, local, k.Unique())
+	if _, err := s.Sync(); err == nil {
+		t.Fatalf("should be error for %v but not", filepath.Join(remote, "X"))
+	}
+
+	// the flag removed from two is not restored by the retry.
+	if err := os.Remove(filepath.Join(remote, "X")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sync(); err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	want := map[string]string{
+		one: "X/cur:S",
+		two: "INBOX/cur:",
+	}
+	for name, root := range map[string]string{"local": local, "remote": remote} {
+		if got := snapshot(t, root); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%v\n\tgot: %v\n\twant: %v", name, got, want)
+		}
+	}
+}
+
+// orderRecords moves the record of the local unique part last in the state.
+func orderRecords(t *testing.T, local, unique string) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(local, ".goem-mdsync-*.json"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("state not found: %v %v", paths, err)
+	}
+	b, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st map[string]interface{}
+	if err := json.Unmarshal(b, &st); err != nil {
+		t.Fatal(err)
+	}
+	records := st["records"].([]interface{})
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[j].(map[string]interface{})["local"] == unique
+	})
+	if b, err = json.Marshal(st); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(paths[0], b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+package mh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SequencesFile is the file name of the sequences in the MH folder.
+const SequencesFile = ".mh_sequences"
+
+// Folder is the MH folder.
+type Folder struct {
+	Path      string
+	Sequences map[string][]int
+}
+
+// Open reads the sequences of the MH folder.
+func Open(path string) (*Folder, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
+	}
+	f := &Folder{
+		Path:      path,
+		Sequences: map[string][]int{},
+	}
+
+	file, err := os.Open(filepath.Join(path, SequencesFile))
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ns, err := f.Messages()
+	if err != nil {
+		return nil, err
+	}
+	max := 0
+	if len(ns) > 0 {
+		max = ns[len(ns)-1]
+	}
+	f.Sequences, err = ParseSequences(file, max)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseSequences parses the lines like "unseen: 1-3 5". The ranges are cut
+// at max, the highest message number in the folder.
+func ParseSequences(r io.Reader, max int) (map[string][]int, error) {
+	seqs := map[string][]int{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), ":", 2)
+		if len(kv) < 2 {
+			continue
+		}
+		name := strings.TrimSpace(kv[0])
+		for _, r := range strings.Fields(kv[1]) {
+			ns, err := parseRange(r, max)
+			if err != nil {
+				return nil, err
+			}
+			seqs[name] = append(seqs[name], ns...)
+		}
+	}
+	return seqs, s.Err()
+}
+
+func parseRange(str string, max int) ([]int, error) {
+	bounds := strings.SplitN(str, "-", 2)
+	first, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return nil, err
+	}
+	last := first
+	if len(bounds) == 2 {
+		last, err = strconv.Atoi(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if last < first {
+		return nil, fmt.Errorf("invalid range: %v", str)
+	}
+	if last > max {
+		last = max
+	}
+	if last < first {
+		return nil, nil
+	}
+	ns := make([]int, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// InSequence reports whether the message number is in the sequence.
+func (f *Folder) InSequence(name string, n int) bool {
+	for _, m := range f.Sequences[name] {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Messages returns the message numbers in ascending order.
+func (f *Folder) Messages() ([]int, error) {
+	infos, err := ioutil.ReadDir(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	var ns []int
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		n, err := strconv.Atoi(info.Name())
+		if err != nil || n <= 0 {
+			continue
+		}
+		ns = append(ns, n)
+	}
+	sort.Ints(ns)
+	return ns, nil
+}
+
+// MessagePath returns the path of the message file.
+func (f *Folder) MessagePath(n int) string {
+	return filepath.Join(f.Path, strconv.Itoa(n))
+}
@@ -0,0 +1,68 @@
+package mh_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/mh"
+)
+
+func Test_ParseSequences(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		max   int
+		want  map[string][]int
+		err   bool
+	}{
+		"(valid)ranges": {
+			input: "unseen: 1-3 5\nflagged: 2\ncur: 5\n",
+			max:   5,
+			want: map[string][]int{
+				"unseen":  {1, 2, 3, 5},
+				"flagged": {2},
+				"cur":     {5},
+			},
+			err: false,
+		},
+		"(valid)empty": {
+			input: "",
+			want:  map[string][]int{},
+			err:   false,
+		},
+		"(valid)cut at max": {
+			input: "unseen: 2-2000000000\nflagged: 7-9 3\n",
+			max:   4,
+			want: map[string][]int{
+				"unseen":  {2, 3, 4},
+				"flagged": {3},
+			},
+			err: false,
+		},
+		"(invalid)reversed": {
+			input: "unseen: 5-3\n",
+			max:   5,
+			want:  nil,
+			err:   true,
+		},
+		"(invalid)not number": {
+			input: "unseen: a-b\n",
+			want:  nil,
+			err:   true,
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			got, err := mh.ParseSequences(strings.NewReader(tt.input), tt.max)
+			if !tt.err && err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			if tt.err && err == nil {
+				t.Fatalf("should be error for %v but not", caseName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+}
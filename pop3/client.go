@@ -0,0 +1,160 @@
+package pop3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Error is the -ERR response from the server.
+type Error struct {
+	Text string
+}
+
+func (e *Error) Error() string {
+	return "pop3: " + e.Text
+}
+
+// Client is the POP3 client.
+type Client struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// Dial connects to the server, with TLS if tlsConfig is not nil.
+func Dial(ctx context.Context, addr string, tlsConfig *tls.Config) (*Client, error) {
+	d := &net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient reads the greeting from the connection.
+func NewClient(conn net.Conn) (*Client, error) {
+	c := &Client{conn: conn, text: textproto.NewConn(conn)}
+	if _, err := c.readResponse(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection without QUIT.
+// The messages marked as deleted are not removed.
+func (c *Client) Close() error {
+	return c.text.Close()
+}
+
+func (c *Client) readResponse() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(line, "+OK"):
+		return strings.TrimSpace(line[len("+OK"):]), nil
+	case strings.HasPrefix(line, "-ERR"):
+		return "", &Error{Text: strings.TrimSpace(line[len("-ERR"):])}
+	}
+	return "", fmt.Errorf("pop3: unexpected response: %v", line)
+}
+
+func (c *Client) cmd(format string, args ...interface{}) (string, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.readResponse()
+}
+
+// StartTLS upgrades the connection to TLS with STLS.
+func (c *Client) StartTLS(tlsConfig *tls.Config) error {
+	if _, err := c.cmd("STLS"); err != nil {
+		return err
+	}
+	c.conn = tls.Client(c.conn, tlsConfig)
+	c.text = textproto.NewConn(c.conn)
+	return nil
+}
+
+// Login authenticates with USER and PASS.
+func (c *Client) Login(user, password string) error {
+	if strings.ContainsAny(user+password, "\r\n") {
+		return errors.New("pop3: user name and password cannot contain line breaks")
+	}
+	if _, err := c.cmd("USER %v", user); err != nil {
+		return err
+	}
+	_, err := c.cmd("PASS %v", password)
+	return err
+}
+
+// UIDL returns the unique IDs keyed by the message numbers.
+func (c *Client) UIDL() (map[int]string, error) {
+	if _, err := c.cmd("UIDL"); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	uidls := make(map[int]string, len(lines))
+	for _, l := range lines {
+		fs := strings.Fields(l)
+		if len(fs) != 2 {
+			return nil, fmt.Errorf("pop3: malformed UIDL line: %v", l)
+		}
+		n, err := strconv.Atoi(fs[0])
+		if err != nil {
+			return nil, fmt.Errorf("pop3: malformed UIDL line: %v", l)
+		}
+		uidls[n] = fs[1]
+	}
+	return uidls, nil
+}
+
+// Retr returns the message. The dot-stuffing is removed.
+func (c *Client) Retr(n int) ([]byte, error) {
+	if _, err := c.cmd("RETR %v", n); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// Dele marks the message as deleted.
+func (c *Client) Dele(n int) error {
+	_, err := c.cmd("DELE %v", n)
+	return err
+}
+
+// Quit removes the messages marked as deleted and closes the connection.
+func (c *Client) Quit() error {
+	_, err := c.cmd("QUIT")
+	if cerr := c.Close(); err == nil && cerr != nil && cerr != io.EOF {
+		err = cerr
+	}
+	return err
+}
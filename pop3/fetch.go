@@ -0,0 +1,199 @@
+package pop3
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tennashi/goem/maildir"
+)
+
+// Account is the configuration of the POP3 account.
+type Account struct {
+	Name string `toml:"name"`
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+	// TLS is "tls", "starttls" or "none". The default is "tls".
+	TLS      string `toml:"tls"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// Folder is the maildir name the messages are delivered into.
+	// The default is "INBOX".
+	Folder string `toml:"folder"`
+	// Keep leaves the messages on the server after downloading them.
+	Keep bool `toml:"keep"`
+	// DeleteAfter deletes the messages kept on the server after the days
+	// since downloaded. Zero keeps them forever.
+	DeleteAfter int `toml:"delete_after"`
+}
+
+func (a Account) addr() string {
+	port := a.Port
+	if port == 0 {
+		port = 995
+		if a.TLS == "starttls" || a.TLS == "none" {
+			port = 110
+		}
+	}
+	return net.JoinHostPort(a.Host, strconv.Itoa(port))
+}
+
+// Fetcher downloads the new messages of the POP3 account into the maildir.
+type Fetcher struct {
+	account Account
+	rootDir string
+}
+
+// NewFetcher is ...
+func NewFetcher(account Account, rootDir string) *Fetcher {
+	return &Fetcher{
+		account: account,
+		rootDir: rootDir,
+	}
+}
+
+// Fetch delivers the messages not downloaded yet into new of the maildir
+// and returns the number of them.
+func (f *Fetcher) Fetch(ctx context.Context) (int, error) {
+	if f.account.Name == "" {
+		return 0, errors.New("account name is required")
+	}
+	folder := f.account.Folder
+	if folder == "" {
+		folder = "INBOX"
+	}
+	md, err := maildir.Create(filepath.Join(f.rootDir, folder))
+	if err != nil {
+		return 0, err
+	}
+
+	c, err := f.connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	n, err := f.fetch(c, md)
+	if ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	if err != nil {
+		// closing without QUIT leaves the messages marked as deleted.
+		c.Close()
+		return n, err
+	}
+	return n, c.Quit()
+}
+
+func (f *Fetcher) connect(ctx context.Context) (*Client, error) {
+	a := f.account
+	tlsConfig := &tls.Config{ServerName: a.Host}
+	var c *Client
+	var err error
+	switch a.TLS {
+	case "", "tls":
+		c, err = Dial(ctx, a.addr(), tlsConfig)
+	case "starttls":
+		c, err = Dial(ctx, a.addr(), nil)
+		if err == nil {
+			if err = c.StartTLS(tlsConfig); err != nil {
+				c.Close()
+			}
+		}
+	case "none":
+		c, err = Dial(ctx, a.addr(), nil)
+	default:
+		return nil, fmt.Errorf("unknown tls mode: %v", a.TLS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Login(a.Username, a.Password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (f *Fetcher) fetch(c *Client, md *maildir.Maildir) (n int, err error) {
+	statePath := stateFile(md.Path, f.account.Name)
+	st, err := loadState(statePath)
+	if err != nil {
+		return 0, err
+	}
+	uidls, err := c.UIDL()
+	if err != nil {
+		return 0, err
+	}
+
+	next := state{}
+	defer func() {
+		if err != nil {
+			// the UIDLs not reached yet are still downloaded.
+			for uidl, seen := range st {
+				if _, ok := next[uidl]; !ok {
+					next[uidl] = seen
+				}
+			}
+		}
+		if serr := next.save(statePath); err == nil {
+			err = serr
+		}
+	}()
+
+	nums := make([]int, 0, len(uidls))
+	for num := range uidls {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	now := time.Now()
+	for _, num := range nums {
+		uidl := uidls[num]
+		seen, ok := st[uidl]
+		if !ok {
+			msg, err := c.Retr(num)
+			if err != nil {
+				return n, err
+			}
+			if _, err := md.Deliver(bytes.NewReader(msg), maildir.DeliverOption{SubDir: maildir.SubDirNew}); err != nil {
+				return n, err
+			}
+			n++
+			seen = now.Unix()
+		}
+		// the state is updated before DELE so that the message is never
+		// downloaded twice even if the deletion fails.
+		next[uidl] = seen
+		if f.expired(seen, now) {
+			if err := c.Dele(num); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (f *Fetcher) expired(seen int64, now time.Time) bool {
+	if !f.account.Keep {
+		return true
+	}
+	if f.account.DeleteAfter <= 0 {
+		return false
+	}
+	return now.Sub(time.Unix(seen, 0)) >= time.Duration(f.account.DeleteAfter)*24*time.Hour
+}
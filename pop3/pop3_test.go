@@ -0,0 +1,328 @@
+package pop3_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/pop3"
+)
+
+type fakeMessage struct {
+	uidl string
+	body string
+}
+
+// fakeServer is the POP3 server stand-in which supports the commands the fetcher uses.
+type fakeServer struct {
+	mu       sync.Mutex
+	ln       net.Listener
+	messages []fakeMessage
+	// failing is the UIDL whose RETR fails.
+	failing string
+}
+
+func newFakeServer(t *testing.T, messages ...fakeMessage) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, messages: messages}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) close() {
+	s.ln.Close()
+}
+
+func (s *fakeServer) uidls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uidls []string
+	for _, m := range s.messages {
+		uidls = append(uidls, m.uidl)
+	}
+	sort.Strings(uidls)
+	return uidls
+}
+
+func (s *fakeServer) fail(uidl string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = uidl
+}
+
+func (s *fakeServer) add(m fakeMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	fmt.Fprint(w, "+OK ready\r\n")
+	w.Flush()
+
+	s.mu.Lock()
+	messages := append([]fakeMessage(nil), s.messages...)
+	failing := s.failing
+	s.mu.Unlock()
+	deleted := map[int]bool{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+		n := 0
+		if len(fields) > 1 {
+			n, _ = strconv.Atoi(fields[1])
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "USER", "PASS":
+			fmt.Fprint(w, "+OK\r\n")
+		case "UIDL":
+			fmt.Fprint(w, "+OK\r\n")
+			for i, m := range messages {
+				if !deleted[i+1] {
+					fmt.Fprintf(w, "%v %v\r\n", i+1, m.uidl)
+				}
+			}
+			fmt.Fprint(w, ".\r\n")
+		case "RETR":
+			if n < 1 || n > len(messages) || deleted[n] {
+				fmt.Fprint(w, "-ERR no such message\r\n")
+				break
+			}
+			if messages[n-1].uidl == failing {
+				fmt.Fprint(w, "-ERR unavailable\r\n")
+				break
+			}
+			fmt.Fprint(w, "+OK\r\n")
+			for _, l := range strings.SplitAfter(messages[n-1].body, "\r\n") {
+				if strings.HasPrefix(l, ".") {
+					l = "." + l
+				}
+				fmt.Fprint(w, l)
+			}
+			fmt.Fprint(w, ".\r\n")
+		case "DELE":
+			deleted[n] = true
+			fmt.Fprint(w, "+OK\r\n")
+		case "QUIT":
+			s.mu.Lock()
+			var kept []fakeMessage
+			for _, m := range s.messages {
+				del := false
+				for i := range deleted {
+					if messages[i-1].uidl == m.uidl {
+						del = true
+					}
+				}
+				if !del {
+					kept = append(kept, m)
+				}
+			}
+			s.messages = kept
+			s.mu.Unlock()
+			fmt.Fprint(w, "+OK bye\r\n")
+			w.Flush()
+			return
+		default:
+			fmt.Fprint(w, "-ERR unknown command\r\n")
+		}
+		w.Flush()
+	}
+}
+
+func (s *fakeServer) account() pop3.Account {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return pop3.Account{
+		Name:     "test",
+		Host:     host,
+		Port:     p,
+		TLS:      "none",
+		Username: "user",
+		Password: "pass",
+	}
+}
+
+func newMessages(t *testing.T, path string) []string {
+	t.Helper()
+	md, err := maildir.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := md.Keys(maildir.SubDirNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for _, k := range keys {
+		f, err := md.Open(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(f)
+		f.Close()
+		bodies = append(bodies, string(b))
+	}
+	sort.Strings(bodies)
+	return bodies
+}
+
+func Test_Fetcher_Fetch(t *testing.T) {
+	one := fakeMessage{uidl: "a1", body: "Subject: one\r\n\r\n.dot\r\n"}
+	two := fakeMessage{uidl: "b2", body: "Subject: two\r\n\r\n2\r\n"}
+	three := fakeMessage{uidl: "c3", body: "Subject: three\r\n\r\n3\r\n"}
+
+	cases := map[string]struct {
+		keep        bool
+		deleteAfter int
+		// state is the UIDLs downloaded before with the time first seen.
+		state       string
+		wantFetched int
+		wantNew     []string
+		wantServer  []string
+	}{
+		"(valid)delete": {
+			wantFetched: 2,
+			wantNew:     []string{one.body, two.body},
+			wantServer:  nil,
+		},
+		"(valid)keep": {
+			keep:        true,
+			state:       `{"a1":0}`,
+			wantFetched: 1,
+			wantNew:     []string{two.body},
+			wantServer:  []string{"a1", "b2"},
+		},
+		"(valid)keep with deletion by age": {
+			keep:        true,
+			deleteAfter: 7,
+			state:       `{"a1":0}`,
+			wantFetched: 1,
+			wantNew:     []string{two.body},
+			wantServer:  []string{"b2"},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := newFakeServer(t, one, two)
+			defer srv.close()
+			root, err := ioutil.TempDir("", "pop3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			inbox := filepath.Join(root, "INBOX")
+			if tt.state != "" {
+				if _, err := maildir.Create(inbox); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(inbox, ".goem-pop3-test.json"), []byte(tt.state), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			account := srv.account()
+			account.Keep = tt.keep
+			account.DeleteAfter = tt.deleteAfter
+			f := pop3.NewFetcher(account, root)
+			n, err := f.Fetch(context.Background())
+			if err != nil {
+				t.Fatalf("should not be error but %v", err)
+			}
+			if n != tt.wantFetched {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", n, tt.wantFetched)
+			}
+			if got := newMessages(t, inbox); !reflect.DeepEqual(got, tt.wantNew) {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got, tt.wantNew)
+			}
+			if got := srv.uidls(); !reflect.DeepEqual(got, tt.wantServer) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.wantServer)
+			}
+
+			// only the message arriving later is downloaded.
+			srv.add(three)
+			n, err = f.Fetch(context.Background())
+			if err != nil {
+				t.Fatalf("should not be error but %v", err)
+			}
+			if n != 1 {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", n, 1)
+			}
+		})
+	}
+}
+
+func Test_Fetcher_Fetch_failure(t *testing.T) {
+	one := fakeMessage{uidl: "a1", body: "Subject: one\r\n\r\n1\r\n"}
+	two := fakeMessage{uidl: "b2", body: "Subject: two\r\n\r\n2\r\n"}
+	three := fakeMessage{uidl: "c3", body: "Subject: three\r\n\r\n3\r\n"}
+	srv := newFakeServer(t, one, two, three)
+	defer srv.close()
+	root, err := ioutil.TempDir("", "pop3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	inbox := filepath.Join(root, "INBOX")
+	if _, err := maildir.Create(inbox); err != nil {
+		t.Fatal(err)
+	}
+	// one and three are downloaded before.
+	state := `{"a1":0,"c3":0}`
+	if err := ioutil.WriteFile(filepath.Join(inbox, ".goem-pop3-test.json"), []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	account := srv.account()
+	account.Keep = true
+	f := pop3.NewFetcher(account, root)
+	srv.fail("b2")
+	if _, err := f.Fetch(context.Background()); err == nil {
+		t.Fatalf("should be error for RETR of b2 but not")
+	}
+
+	// three after the failure is not downloaded again.
+	srv.fail("")
+	n, err := f.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("should not be error but %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", n, 1)
+	}
+	if got, want := newMessages(t, inbox), []string{two.body}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("\n\tgot: %q\n\twant: %q", got, want)
+	}
+}
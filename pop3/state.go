@@ -0,0 +1,43 @@
+package pop3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// state maps the UIDLs already downloaded to the time first seen in Unix seconds.
+type state map[string]int64
+
+func stateFile(mdPath, account string) string {
+	return filepath.Join(mdPath, ".goem-pop3-"+account+".json")
+}
+
+func loadState(path string) (state, error) {
+	st := state{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// save writes the state into the temporary file and renames it.
+func (st state) save(path string) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -2,11 +2,20 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
+	"github.com/tennashi/goem/mail"
+	"github.com/tennashi/goem/maildir"
 )
 
 // Handler is ...
@@ -25,17 +34,27 @@ func New(mdr *goem.MaildirRoot) *Handler {
 func (h *Handler) ListMaildir(w http.ResponseWriter, r *http.Request) {
 	mds, err := h.mdr.Maildirs()
 	if err != nil {
-		responseErr(w, err, http.StatusInternalServerError)
+		responseErr(w, err)
 		return
 	}
 
-	type resp struct {
-		Name string `json:"name"`
-	}
-	res := make([]resp, len(mds))
+	res := make([]api.Maildir, len(mds))
 	for i, m := range mds {
-		res[i] = resp{
-			Name: m.Name,
+		res[i] = api.Maildir{
+			Name:    m.Name,
+			Total:   m.Total,
+			Unread:  m.Unread,
+			Flagged: m.Flagged,
+			Size:    m.Size,
+		}
+		if q := m.Quota; q != nil {
+			res[i].Quota = &api.Quota{
+				BytesLimit:    q.Quota.Bytes,
+				MessagesLimit: q.Quota.Messages,
+				Bytes:         q.Bytes,
+				Messages:      q.Messages,
+				Exceeded:      q.Exceeded(),
+			}
 		}
 	}
 	responseJSON(w, res, http.StatusOK)
@@ -44,29 +63,38 @@ func (h *Handler) ListMaildir(w http.ResponseWriter, r *http.Request) {
 // ListMail is ...
 func (h *Handler) ListMail(w http.ResponseWriter, r *http.Request) {
 	dirName := chi.URLParam(r, "dirName")
-	subDirName := r.URL.Query().Get("sub_dir")
+	q := r.URL.Query()
+	subDirName := q.Get(api.QuerySubDir)
 	if subDirName == "" {
 		subDirName = "cur"
 	}
-	ms, err := h.mdr.GetMails(dirName, subDirName)
+	offset, err := intParam(q, api.QueryOffset)
 	if err != nil {
-		responseErr(w, err, http.StatusBadRequest)
+		responseError(w, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
 		return
 	}
-
-	type resp struct {
-		Key     string              `json:"key"`
-		Subject string              `json:"subject"`
-		Headers map[string][]string `json:"headers"`
+	limit, err := intParam(q, api.QueryLimit)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, api.CodeInvalidParameter, err.Error())
+		return
 	}
-	res := make([]resp, len(ms))
+	ms, total, err := h.mdr.GetMailsPage(dirName, subDirName, offset, limit)
+	if err != nil {
+		responseErr(w, err)
+		return
+	}
+
+	res := make([]api.MailSummary, len(ms))
 	for i, m := range ms {
-		res[i] = resp{
-			Key:     m.Key.Raw,
-			Subject: m.Subject,
-			Headers: m.Headers.DecodeAll(),
+		res[i] = api.MailSummary{
+			Key:      m.Key.Raw,
+			Subject:  m.Subject,
+			Size:     m.Size,
+			Headers:  m.Headers.DecodeAll(),
+			Envelope: newEnvelope(m.Headers),
 		}
 	}
+	w.Header().Set(api.HeaderTotalCount, strconv.Itoa(total))
 	responseJSON(w, res, http.StatusOK)
 }
 
@@ -77,39 +105,195 @@ func (h *Handler) GetMail(w http.ResponseWriter, r *http.Request) {
 
 	m, err := h.mdr.GetMail(dirName, key)
 	if err != nil {
-		responseErr(w, err, http.StatusInternalServerError)
+		responseErr(w, err)
 		return
 	}
-	type resp struct {
-		Key     string              `json:"key"`
-		Subject string              `json:"subject"`
-		Body    string              `json:"body"`
-		Headers map[string][]string `json:"headers"`
-	}
 	b, err := ioutil.ReadAll(m.Body)
 	if err != nil {
-		responseErr(w, err, http.StatusInternalServerError)
+		responseErr(w, err)
 		return
 	}
+	p, text := mail.ParseText(m.Headers, b)
 
-	res := resp{
-		Key:     key,
-		Subject: m.Subject,
-		Body:    string(b),
-		Headers: m.Headers.DecodeAll(),
+	res := api.Mail{
+		Key:      key,
+		Subject:  m.Subject,
+		Body:     string(b),
+		Text:     text,
+		Headers:  m.Headers.DecodeAll(),
+		Envelope: newEnvelope(m.Headers),
+	}
+	if p == nil {
+		responseJSON(w, res, http.StatusOK)
+		return
+	}
+	if hp := p.SelectBody(mail.NewPreference(r.URL.Query().Get(api.QueryPrefer))); hp != nil && hp.MediaType == "text/html" {
+		s, err := hp.Text()
+		if err != nil {
+			responseErr(w, err)
+			return
+		}
+		opt := mail.SanitizeOption{
+			AllowRemote: r.URL.Query().Get(api.QueryRemote) == "true",
+			CIDURL: func(cid string) string {
+				cp := p.FindContentID(cid)
+				if cp == nil {
+					return ""
+				}
+				return partURL(dirName, key, cp.ID)
+			},
+		}
+		if res.HTML, err = mail.SanitizeHTML(strings.NewReader(s), opt); err != nil {
+			responseErr(w, err)
+			return
+		}
 	}
 	responseJSON(w, res, http.StatusOK)
 }
 
-func responseErr(w http.ResponseWriter, err error, status int) {
-	type retError struct {
-		Error string
+// GetPart is the handler which returns the decoded content of the MIME part.
+func (h *Handler) GetPart(w http.ResponseWriter, r *http.Request) {
+	dirName := chi.URLParam(r, "dirName")
+	key := chi.URLParam(r, "key")
+	partID := chi.URLParam(r, "partID")
+
+	m, err := h.mdr.GetMail(dirName, key)
+	if err != nil {
+		responseErr(w, err)
+		return
+	}
+	p, err := mail.NewPart(m.Headers, m.Body)
+	if err != nil {
+		responseErr(w, err)
+		return
+	}
+	part := p.Find(partID)
+	if part == nil || part.IsMultipart() {
+		responseError(w, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("part %v doesn't exist", partID))
+		return
+	}
+
+	ct := part.MediaType
+	if ct == "text/html" {
+		// the raw HTML is not sanitized, so never let the browser render it.
+		ct = "text/plain"
+	}
+	if cs := part.Params["charset"]; cs != "" {
+		ct = mime.FormatMediaType(ct, map[string]string{"charset": cs})
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	if name := part.Filename(); name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(part.Body)
+}
+
+// GetRaw is the handler which returns the message as it is on the disk.
+// It supports the Range requests and the conditional requests by the ETag
+// made from the unique part of the key.
+func (h *Handler) GetRaw(w http.ResponseWriter, r *http.Request) {
+	dirName := chi.URLParam(r, "dirName")
+	key := chi.URLParam(r, "key")
+
+	f, err := h.mdr.OpenMail(dirName, key)
+	if err != nil {
+		responseErr(w, err)
+		return
+	}
+	defer f.Close()
+	k, err := maildir.ParseKey(key)
+	if err != nil {
+		responseErr(w, err)
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		responseErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "message/rfc822")
+	w.Header().Set("ETag", `"`+k.Unique()+`"`)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+func partURL(dirName, key, partID string) string {
+	return "/maildir/" + url.PathEscape(dirName) + "/" + url.PathEscape(key) + "/parts/" + partID
+}
+
+func newEnvelope(h mail.Header) api.Envelope {
+	e := api.Envelope{
+		From:       addresses(h, "From"),
+		To:         addresses(h, "To"),
+		Cc:         addresses(h, "Cc"),
+		Bcc:        addresses(h, "Bcc"),
+		ReplyTo:    addresses(h, "Reply-To"),
+		MessageID:  h.MessageID(),
+		References: h.References(),
 	}
+	if e.References == nil {
+		e.References = []string{}
+	}
+	if d, err := h.Date(); err == nil {
+		s := d.Format(time.RFC3339)
+		e.Date = &s
+	}
+	return e
+}
+
+func addresses(h mail.Header, key string) []api.Address {
+	as := h.Addresses(key)
+	ret := make([]api.Address, len(as))
+	for i, a := range as {
+		ret[i] = api.Address{Name: a.Name, Address: a.Address}
+	}
+	return ret
+}
+
+// errorStatus is the status and the code of the response of each kind of
+// the errors.
+var errorStatus = map[goem.ErrorCode]struct {
+	status int
+	code   string
+}{
+	goem.CodeNotFound:         {http.StatusNotFound, api.CodeNotFound},
+	goem.CodeNotMaildir:       {http.StatusBadRequest, api.CodeNotMaildir},
+	goem.CodeInvalidKey:       {http.StatusBadRequest, api.CodeInvalidKey},
+	goem.CodeUnknownSubDir:    {http.StatusBadRequest, api.CodeUnknownSubDir},
+	goem.CodePermissionDenied: {http.StatusForbidden, api.CodePermissionDenied},
+}
+
+// responseErr responds the error with the status and the code of its kind,
+// 500 for the unknown errors.
+func responseErr(w http.ResponseWriter, err error) {
+	s, ok := errorStatus[goem.Code(err)]
+	if !ok {
+		responseError(w, http.StatusInternalServerError, api.CodeInternal, err.Error())
+		return
+	}
+	responseError(w, s.status, s.code, err.Error())
+}
+
+func responseError(w http.ResponseWriter, status int, code, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	r := retError{Error: err.Error()}
-	json.NewEncoder(w).Encode(r)
+	json.NewEncoder(w).Encode(api.ErrorResponse{Error: msg, Code: code})
+}
 
+// intParam returns the non-negative integer of the query parameter, 0 if it is not given.
+func intParam(q url.Values, key string) (int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %v: %v", key, v)
+	}
+	return n, nil
 }
 
 func responseJSON(w http.ResponseWriter, r interface{}, status int) {
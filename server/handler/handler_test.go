@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/server/handler"
+)
+
+func Test_Handler_GetRaw(t *testing.T) {
+	root, err := ioutil.TempDir("", "handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md, err := maildir.Create(filepath.Join(root, "INBOX"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const msg = "Subject: raw\r\n\r\nbody\r\n"
+	k, err := md.Deliver(strings.NewReader(msg), maildir.DeliverOption{SubDir: maildir.SubDirCur})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/maildir/{dirName}/{key}/raw", handler.New(goem.NewMaildirRoot(root)).GetRaw)
+	url := "/maildir/INBOX/" + k.Raw + "/raw"
+	etag := `"` + k.Unique() + `"`
+
+	cases := map[string]struct {
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		"(valid)whole": {
+			wantStatus: http.StatusOK,
+			wantBody:   msg,
+		},
+		"(valid)range": {
+			header:     map[string]string{"Range": "bytes=0-6"},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "Subject",
+		},
+		"(valid)not modified": {
+			header:     map[string]string{"If-None-Match": etag},
+			wantStatus: http.StatusNotModified,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got, tt.wantBody)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, etag)
+			}
+		})
+	}
+}
+
+func Test_Handler_GetMail(t *testing.T) {
+	root, err := ioutil.TempDir("", "handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md, err := maildir.Create(filepath.Join(root, "INBOX"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := chi.NewRouter()
+	r.Get("/maildir/{dirName}/{key}", handler.New(goem.NewMaildirRoot(root)).GetMail)
+
+	cases := map[string]struct {
+		msg      string
+		wantText string
+	}{
+		"(valid)plain": {
+			msg:      "Subject: plain\r\n\r\nbody\r\n",
+			wantText: "body\r\n",
+		},
+		"(valid)truncated multipart": {
+			msg:      "Subject: truncated\r\nContent-Type: multipart/mixed; boundary=b1\r\n\r\n--b1\r\n\r\ntruncated",
+			wantText: "--b1\r\n\r\ntruncated",
+		},
+		"(valid)unknown charset": {
+			msg:      "Subject: charset\r\nContent-Type: text/plain; charset=x-unknown\r\n\r\nraw body\r\n",
+			wantText: "raw body\r\n",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			k, err := md.Deliver(strings.NewReader(tt.msg), maildir.DeliverOption{SubDir: maildir.SubDirCur})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/maildir/INBOX/"+k.Raw, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\n\tgot: %v %v\n\twant: %v", w.Code, w.Body, http.StatusOK)
+			}
+			var got api.Mail
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Text != tt.wantText {
+				t.Fatalf("\n\tgot: %q\n\twant: %q", got.Text, tt.wantText)
+			}
+		})
+	}
+}
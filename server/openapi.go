@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tennashi/goem/api"
+)
+
+// OpenAPIPath is the path of the OpenAPI document of goemd.
+const OpenAPIPath = "/openapi.json"
+
+// OpenAPI returns the OpenAPI 3 document of the routes of NewRouter.
+// The response schemas are made from the types of the api package, which
+// the handlers encode.
+func OpenAPI() *api.Document {
+	s := api.Schemas{}
+	errRes := func(desc string) *api.Response {
+		return &api.Response{
+			Description: desc,
+			Content:     map[string]*api.MediaType{"application/json": {Schema: s.Ref(api.ErrorResponse{})}},
+		}
+	}
+	jsonRes := func(v interface{}) map[string]*api.MediaType {
+		return map[string]*api.MediaType{"application/json": {Schema: s.Ref(v)}}
+	}
+	zero := 0
+	str := &api.Schema{Type: "string"}
+	dirName := api.Parameter{Name: "dirName", In: "path", Required: true, Description: "The name of the maildir.", Schema: str}
+	key := api.Parameter{Name: "key", In: "path", Required: true, Description: "The key of the mail, the file name without the directory.", Schema: str}
+	unauthorized := errRes("The token is missing or wrong.")
+	forbidden := errRes("The maildir or the mail can't be accessed by goemd.")
+	notFound := errRes("The maildir or the mail doesn't exist.")
+	internal := errRes("The other errors.")
+	raw := &api.Schema{Type: "string", Format: "binary"}
+	etag := map[string]*api.Header{"ETag": {Description: "The unique part of the key.", Schema: str}}
+
+	paths := map[string]*api.PathItem{
+		"/maildir/": {Get: &api.Operation{
+			OperationID: "listMaildirs",
+			Summary:     "List the maildirs under the root.",
+			Responses: map[string]*api.Response{
+				"200": {Description: "The maildirs.", Content: jsonRes([]api.Maildir{})},
+				"401": unauthorized,
+				"403": errRes("The root can't be read by goemd."),
+				"500": internal,
+			},
+		}},
+		"/maildir/{dirName}": {Get: &api.Operation{
+			OperationID: "listMails",
+			Summary:     "List the mails in a maildir with the headers.",
+			Parameters: []api.Parameter{
+				dirName,
+				{Name: api.QuerySubDir, In: "query", Description: "The sub directory listed.", Schema: &api.Schema{Type: "string", Enum: []string{"cur", "new"}}},
+				{Name: api.QueryOffset, In: "query", Description: "The number of the mails skipped.", Schema: &api.Schema{Type: "integer", Minimum: &zero}},
+				{Name: api.QueryLimit, In: "query", Description: "The maximum number of the mails, all the mails if 0.", Schema: &api.Schema{Type: "integer", Minimum: &zero}},
+			},
+			Responses: map[string]*api.Response{
+				"200": {
+					Description: "The mails sorted by the keys.",
+					Headers: map[string]*api.Header{
+						api.HeaderTotalCount: {Description: "The number of all the mails regardless of the offset and the limit.", Schema: &api.Schema{Type: "integer"}},
+					},
+					Content: jsonRes([]api.MailSummary{}),
+				},
+				"400": errRes("The directory is not a maildir, or the parameters are invalid."),
+				"401": unauthorized,
+				"403": forbidden,
+				"404": notFound,
+				"500": internal,
+			},
+		}},
+		"/maildir/{dirName}/{key}": {Get: &api.Operation{
+			OperationID: "getMail",
+			Summary:     "Get a mail with the body.",
+			Parameters: []api.Parameter{
+				dirName,
+				key,
+				{Name: api.QueryPrefer, In: "query", Description: "The body preferred among the alternatives.", Schema: &api.Schema{Type: "string", Enum: []string{"html", "plain"}}},
+				{Name: api.QueryRemote, In: "query", Description: "Keep the remote resources in the HTML body.", Schema: &api.Schema{Type: "boolean"}},
+			},
+			Responses: map[string]*api.Response{
+				"200": {Description: "The mail.", Content: jsonRes(api.Mail{})},
+				"400": errRes("The directory is not a maildir, or the key is invalid."),
+				"401": unauthorized,
+				"403": forbidden,
+				"404": notFound,
+				"500": internal,
+			},
+		}},
+		"/maildir/{dirName}/{key}/parts/{partID}": {Get: &api.Operation{
+			OperationID: "getPart",
+			Summary:     "Get the decoded content of a MIME part.",
+			Parameters: []api.Parameter{
+				dirName,
+				key,
+				{Name: "partID", In: "path", Required: true, Description: `The ID of the part such as "1.2".`, Schema: str},
+			},
+			Responses: map[string]*api.Response{
+				"200": {Description: "The content of the part. HTML is returned as text/plain.", Content: map[string]*api.MediaType{"*/*": {Schema: raw}}},
+				"400": errRes("The directory is not a maildir, or the key is invalid."),
+				"401": unauthorized,
+				"403": forbidden,
+				"404": errRes("The maildir, the mail or the part doesn't exist."),
+				"500": internal,
+			},
+		}},
+		"/maildir/{dirName}/{key}/raw": {Get: &api.Operation{
+			OperationID: "getRawMail",
+			Summary:     "Get the mail as it is on the disk. Range and If-None-Match are supported.",
+			Parameters:  []api.Parameter{dirName, key},
+			Responses: map[string]*api.Response{
+				"200": {Description: "The mail.", Headers: etag, Content: map[string]*api.MediaType{"message/rfc822": {Schema: raw}}},
+				"206": {Description: "The range of the mail.", Headers: etag, Content: map[string]*api.MediaType{"message/rfc822": {Schema: raw}}},
+				"304": {Description: "The mail is not modified.", Headers: etag},
+				"400": errRes("The directory is not a maildir, or the key is invalid."),
+				"401": unauthorized,
+				"403": forbidden,
+				"404": notFound,
+				"500": internal,
+			},
+		}},
+	}
+
+	s["ErrorResponse"].Properties["code"].Enum = api.ErrorCodes
+
+	return &api.Document{
+		OpenAPI: "3.0.3",
+		Info:    api.Info{Title: "goemd", Version: "0.1.0"},
+		Paths:   paths,
+		Components: api.Components{
+			Schemas: s,
+			SecuritySchemes: map[string]*api.SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+			},
+		},
+		Security: []map[string][]string{{"bearer": {}}},
+	}
+}
+
+// serveOpenAPI serves the OpenAPI document, which needs no token.
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OpenAPI())
+}
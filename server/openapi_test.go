@@ -0,0 +1,377 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/server"
+)
+
+const testMail = "From: \"Bob, B.\" <bob@example.com>\r\n" +
+	"To: alice@example.com\r\n" +
+	"Subject: =?UTF-8?B?5pel5pys6Kqe?=\r\n" +
+	"Date: Wed, 02 Oct 2019 12:00:00 +0000\r\n" +
+	"Message-ID: <2@example.com>\r\n" +
+	"Content-Type: multipart/alternative; boundary=b\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"plain body\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<p>html body</p>\r\n" +
+	"--b--\r\n"
+
+func setup(t *testing.T, token string) (http.Handler, maildir.Key, func()) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "goemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := maildir.Create(filepath.Join(root, "INBOX"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.SetQuota(maildir.Quota{Bytes: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := maildir.Create(filepath.Join(root, "Trash")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "notmd"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	k, err := md.Deliver(strings.NewReader(testMail), maildir.DeliverOption{SubDir: maildir.SubDirCur, Flags: []string{"S"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.Deliver(strings.NewReader("Subject: new\r\n\r\nbody\r\n"), maildir.DeliverOption{}); err != nil {
+		t.Fatal(err)
+	}
+	return server.NewRouter(goem.NewMaildirRoot(root), token), k, func() { os.RemoveAll(root) }
+}
+
+func Test_OpenAPI_routes(t *testing.T) {
+	r, _, cleanup := setup(t, "")
+	defer cleanup()
+	doc := server.OpenAPI()
+
+	var routes, documented []string
+	err := chi.Walk(r.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != server.OpenAPIPath {
+			routes = append(routes, method+" "+route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, item := range doc.Paths {
+		if item.Get != nil {
+			documented = append(documented, http.MethodGet+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	sort.Strings(documented)
+	if strings.Join(routes, "\n") != strings.Join(documented, "\n") {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", documented, routes)
+	}
+}
+
+func Test_OpenAPI_serve(t *testing.T) {
+	r, _, cleanup := setup(t, "secret")
+	defer cleanup()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, server.OpenAPIPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("\n\tgot: %v\n\twant: %v", w.Code, http.StatusOK)
+	}
+	var doc api.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("should not be error for %v but %v", server.OpenAPIPath, err)
+	}
+	if doc.OpenAPI == "" || len(doc.Paths) != len(server.OpenAPI().Paths) {
+		t.Fatalf("\n\tgot: %+v\n\twant: %+v", doc, server.OpenAPI())
+	}
+}
+
+func Test_OpenAPI_responses(t *testing.T) {
+	r, k, cleanup := setup(t, "secret")
+	defer cleanup()
+	doc := server.OpenAPI()
+	mail := "/maildir/INBOX/" + k.Raw
+
+	cases := map[string]struct {
+		route      string
+		url        string
+		header     map[string]string
+		noToken    bool
+		wantStatus int
+	}{
+		"(valid)list maildirs": {
+			route:      "/maildir/",
+			url:        "/maildir/",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)list mails": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/INBOX",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)list new mails by page": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/INBOX?sub_dir=new&offset=0&limit=1",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)list empty maildir": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/Trash",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)get mail": {
+			route:      "/maildir/{dirName}/{key}",
+			url:        mail,
+			wantStatus: http.StatusOK,
+		},
+		"(valid)get mail preferring html": {
+			route:      "/maildir/{dirName}/{key}",
+			url:        mail + "?prefer=html",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)get part": {
+			route:      "/maildir/{dirName}/{key}/parts/{partID}",
+			url:        mail + "/parts/2",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)get raw": {
+			route:      "/maildir/{dirName}/{key}/raw",
+			url:        mail + "/raw",
+			wantStatus: http.StatusOK,
+		},
+		"(valid)get raw range": {
+			route:      "/maildir/{dirName}/{key}/raw",
+			url:        mail + "/raw",
+			header:     map[string]string{"Range": "bytes=0-3"},
+			wantStatus: http.StatusPartialContent,
+		},
+		"(valid)get raw not modified": {
+			route:      "/maildir/{dirName}/{key}/raw",
+			url:        mail + "/raw",
+			header:     map[string]string{"If-None-Match": `"` + k.Unique() + `"`},
+			wantStatus: http.StatusNotModified,
+		},
+		"(invalid)no token": {
+			route:      "/maildir/",
+			url:        "/maildir/",
+			noToken:    true,
+			wantStatus: http.StatusUnauthorized,
+		},
+		"(invalid)no maildir": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/nowhere",
+			wantStatus: http.StatusNotFound,
+		},
+		"(invalid)not maildir": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/notmd",
+			wantStatus: http.StatusBadRequest,
+		},
+		"(invalid)sub dir": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/INBOX?sub_dir=tmp",
+			wantStatus: http.StatusBadRequest,
+		},
+		"(invalid)limit": {
+			route:      "/maildir/{dirName}",
+			url:        "/maildir/INBOX?limit=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+		"(invalid)no mail": {
+			route:      "/maildir/{dirName}/{key}",
+			url:        "/maildir/INBOX/1.M1P1Q1.nowhere",
+			wantStatus: http.StatusNotFound,
+		},
+		"(invalid)mail key": {
+			route:      "/maildir/{dirName}/{key}",
+			url:        "/maildir/INBOX/nothing",
+			wantStatus: http.StatusBadRequest,
+		},
+		"(invalid)no part": {
+			route:      "/maildir/{dirName}/{key}/parts/{partID}",
+			url:        mail + "/parts/9",
+			wantStatus: http.StatusNotFound,
+		},
+		"(invalid)raw key": {
+			route:      "/maildir/{dirName}/{key}/raw",
+			url:        "/maildir/INBOX/:2,S/raw",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if !tt.noToken {
+				req.Header.Set("Authorization", "Bearer secret")
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("\n\tgot: %v %v\n\twant: %v", w.Code, w.Body, tt.wantStatus)
+			}
+
+			item, ok := doc.Paths[tt.route]
+			if !ok || item.Get == nil {
+				t.Fatalf("%v is not documented", tt.route)
+			}
+			res, ok := item.Get.Responses[strconv.Itoa(w.Code)]
+			if !ok {
+				t.Fatalf("%v of %v is not documented", w.Code, tt.route)
+			}
+			if err := validateResponse(doc, res, w); err != nil {
+				t.Fatalf("should not be error for %v but %v", tt.url, err)
+			}
+		})
+	}
+}
+
+func validateResponse(doc *api.Document, res *api.Response, w *httptest.ResponseRecorder) error {
+	for name := range res.Headers {
+		if w.Header().Get(name) == "" {
+			return fmt.Errorf("no header %v", name)
+		}
+	}
+	if len(res.Content) == 0 {
+		if w.Body.Len() != 0 {
+			return fmt.Errorf("undocumented body %q", w.Body)
+		}
+		return nil
+	}
+
+	mt, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	c, ok := res.Content[mt]
+	if !ok {
+		c, ok = res.Content["*/*"]
+	}
+	if !ok {
+		return fmt.Errorf("undocumented media type %v", mt)
+	}
+	if mt != "application/json" {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		return err
+	}
+	return validate(doc, c.Schema, v, "$")
+}
+
+// validate validates the decoded JSON value by the schema. The properties
+// not in the schema are reported to keep the document complete.
+func validate(doc *api.Document, s *api.Schema, v interface{}, path string) error {
+	if s.Ref != "" {
+		ref, ok := doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%v: unknown $ref %v", path, s.Ref)
+		}
+		return validate(doc, ref, v, path)
+	}
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%v: null", path)
+	}
+	for _, sub := range s.AllOf {
+		if err := validate(doc, sub, v, path); err != nil {
+			return err
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v: %v is not object", path, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%v: no property %v", path, name)
+			}
+		}
+		for name, pv := range obj {
+			ps, ok := s.Properties[name]
+			if !ok {
+				ps = s.AdditionalProperties
+			}
+			if ps == nil {
+				return fmt.Errorf("%v: undocumented property %v", path, name)
+			}
+			if err := validate(doc, ps, pv, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v: %v is not array", path, v)
+		}
+		for i, iv := range a {
+			if err := validate(doc, s.Items, iv, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%v: %v is not string", path, v)
+		}
+		if len(s.Enum) == 0 {
+			return nil
+		}
+		for _, e := range s.Enum {
+			if str == e {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v: %v is not in %v", path, str, s.Enum)
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%v: %v is not integer", path, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%v: %v is not number", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v: %v is not boolean", path, v)
+		}
+	}
+	return nil
+}
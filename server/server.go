@@ -2,43 +2,131 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
 	"github.com/tennashi/goem/server/handler"
 )
 
-// Run is ...
-func Run(ctx context.Context, config *goem.Config) error {
-	s := newServer(config)
-	return s.run(ctx)
+// Run serves the Maildirs of the account.
+func Run(ctx context.Context, config *goem.Config, account *goem.Account) error {
+	s, err := New(config, account)
+	if err != nil {
+		return err
+	}
+	return s.Run(ctx)
+}
+
+// shutdownTimeout is how long the requests in flight are waited for when
+// shutting down.
+const shutdownTimeout = 10 * time.Second
+
+// Server serves the Maildirs of the account with the configuration which
+// can be reloaded while running.
+type Server struct {
+	port string
+	tls  bool
+	// state is the *state the new requests are served with.
+	state atomic.Value
+}
+
+// state is the configuration swapped at once by Reload.
+type state struct {
+	config  *goem.Config
+	account *goem.Account
+	handler http.Handler
+	cert    *tls.Certificate
 }
 
-type server struct {
-	config *goem.Config
+// New returns the server of the account, which serves over TLS if the
+// certificate is configured.
+func New(config *goem.Config, account *goem.Account) (*Server, error) {
+	st, err := newState(config, account)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{port: config.Server.Port, tls: st.cert != nil}
+	s.state.Store(st)
+	return s, nil
 }
 
-func newServer(config *goem.Config) *server {
-	return &server{config}
+func newState(config *goem.Config, account *goem.Account) (*state, error) {
+	st := &state{
+		config:  config,
+		account: account,
+		handler: NewRouter(goem.NewMaildirRoot(account.RootDir), config.Server.Token),
+	}
+	if config.Server.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.Server.CertFile, config.Server.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		st.cert = &cert
+	}
+	return st, nil
 }
 
-func (s *server) run(ctx context.Context) error {
+// Reload swaps the Maildirs, the token and the certificate for the new
+// requests, while the requests in flight finish with the old ones. The
+// port is not changed until restarting, nor whether TLS is used.
+func (s *Server) Reload(config *goem.Config, account *goem.Account) error {
+	st, err := newState(config, account)
+	if err != nil {
+		return err
+	}
+	if (st.cert != nil) != s.tls {
+		return errors.New("server can't turn TLS on or off without restarting")
+	}
+	if config.Server.Port != s.port {
+		log.Printf("server keeps running on port %v until restarting", s.port)
+	}
+	s.state.Store(st)
+	return nil
+}
+
+// ServeHTTP serves the request with the current configuration.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.state.Load().(*state).handler.ServeHTTP(w, r)
+}
+
+func (s *Server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.state.Load().(*state).cert, nil
+}
+
+// Run serves until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
 	log.Println("server intializing")
-	mdr := goem.NewMaildirRoot(s.config.RootDir)
-	r := newRouter(mdr)
+	st := s.state.Load().(*state)
 	hs := &http.Server{
-		Addr:    ":" + s.config.Server.Port,
-		Handler: r,
+		Addr:    ":" + s.port,
+		Handler: s,
+	}
+	if s.tls {
+		hs.TLSConfig = &tls.Config{GetCertificate: s.getCertificate}
 	}
 	log.Println("server intialized")
-	log.Printf("server running on localhost:%v", s.config.Server.Port)
+	log.Printf("server running on localhost:%v for the account %v", s.port, st.account.Name)
 
 	eCh := make(chan error)
 	go func() {
 		defer close(eCh)
-		if err := hs.ListenAndServe(); err != nil {
+		var err error
+		if s.tls {
+			err = hs.ListenAndServeTLS("", "")
+		} else {
+			err = hs.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			eCh <- err
 		}
 	}()
@@ -46,19 +134,51 @@ func (s *server) run(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		log.Println("server shuting down")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
 		return hs.Shutdown(ctx)
 	case err := <-eCh:
 		return err
 	}
 }
 
-func newRouter(mdr *goem.MaildirRoot) *chi.Mux {
+// NewRouter returns the handler of the goemd API on the maildir root.
+// The requests must have the bearer token unless token is empty, except
+// the one of the OpenAPI document.
+func NewRouter(mdr *goem.MaildirRoot, token string) http.Handler {
 	r := chi.NewRouter()
+	r.Get(OpenAPIPath, serveOpenAPI)
 
 	h := handler.New(mdr)
-	r.Get("/maildir/", h.ListMaildir)
-	r.Get("/maildir/{dirName}", h.ListMail)
-	r.Get("/maildir/{dirName}/{key}", h.GetMail)
+	r.Group(func(r chi.Router) {
+		if token != "" {
+			r.Use(requireToken(token))
+		}
+		r.Get("/maildir/", h.ListMaildir)
+		r.Get("/maildir/{dirName}", h.ListMail)
+		r.Get("/maildir/{dirName}/{key}", h.GetMail)
+		r.Get("/maildir/{dirName}/{key}/parts/{partID}", h.GetPart)
+		r.Get("/maildir/{dirName}/{key}/raw", h.GetRaw)
+	})
 
 	return r
 }
+
+// requireToken rejects the requests without "Authorization: Bearer token".
+func requireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			const prefix = "Bearer "
+			if !strings.HasPrefix(auth, prefix) ||
+				subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="goemd"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "invalid token", Code: api.CodeUnauthorized})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
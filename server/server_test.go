@@ -0,0 +1,120 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/api"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/server"
+)
+
+// setupAccount returns the configuration serving the account with the
+// maildir mdName by the token.
+func setupAccount(t *testing.T, mdName, token string) (*goem.Config, func()) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "goemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := maildir.Create(filepath.Join(root, mdName)); err != nil {
+		t.Fatal(err)
+	}
+	config := &goem.Config{
+		Accounts: []goem.Account{{Name: mdName, RootDir: root}},
+		Server:   goem.ServerConfig{Port: goem.DefaultPort, Token: token},
+	}
+	return config, func() { os.RemoveAll(root) }
+}
+
+func listMaildirs(t *testing.T, url, token string) (int, []string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url+"/maildir/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, nil
+	}
+	var mds []api.Maildir
+	if err := json.NewDecoder(res.Body).Decode(&mds); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, md := range mds {
+		names = append(names, md.Name)
+	}
+	return res.StatusCode, names
+}
+
+func Test_Server_Reload(t *testing.T) {
+	oldConfig, cleanup := setupAccount(t, "Old", "old")
+	defer cleanup()
+	newConfig, cleanup := setupAccount(t, "New", "new")
+	defer cleanup()
+
+	s, err := server.New(oldConfig, &oldConfig.Accounts[0])
+	if err != nil {
+		t.Fatalf("should not be error for %v but %v", oldConfig, err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	if code, got := listMaildirs(t, ts.URL, "old"); code != http.StatusOK || len(got) != 1 || got[0] != "Old" {
+		t.Fatalf("\n\tgot: %v %v\n\twant: %v [Old]", code, got, http.StatusOK)
+	}
+	if err := s.Reload(newConfig, &newConfig.Accounts[0]); err != nil {
+		t.Fatalf("should not be error for %v but %v", newConfig, err)
+	}
+
+	cases := map[string]struct {
+		token      string
+		wantStatus int
+		want       []string
+	}{
+		"(valid)new token": {
+			token:      "new",
+			wantStatus: http.StatusOK,
+			want:       []string{"New"},
+		},
+		"(invalid)old token": {
+			token:      "old",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, got := listMaildirs(t, ts.URL, tt.token)
+			if code != tt.wantStatus {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", code, tt.wantStatus)
+			}
+			if len(got) != len(tt.want) || len(got) > 0 && got[0] != tt.want[0] {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("(invalid)turn TLS on", func(t *testing.T) {
+		tlsConfig := *newConfig
+		tlsConfig.Server.CertFile = "nowhere.pem"
+		tlsConfig.Server.KeyFile = "nowhere.key"
+		if err := s.Reload(&tlsConfig, &tlsConfig.Accounts[0]); err == nil {
+			t.Fatalf("should be error for %v", tlsConfig.Server)
+		}
+		if code, _ := listMaildirs(t, ts.URL, "new"); code != http.StatusOK {
+			t.Fatalf("\n\tgot: %v\n\twant: %v", code, http.StatusOK)
+		}
+	})
+}
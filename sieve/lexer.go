@@ -0,0 +1,278 @@
+package sieve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenType uint8
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdentifier
+	tokenTag
+	tokenNumber
+	tokenString
+	tokenLeftBracket
+	tokenRightBracket
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBrace
+	tokenRightBrace
+	tokenComma
+	tokenSemicolon
+)
+
+func (t tokenType) String() string {
+	switch t {
+	case tokenEOF:
+		return "end of script"
+	case tokenIdentifier:
+		return "identifier"
+	case tokenTag:
+		return "tag"
+	case tokenNumber:
+		return "number"
+	case tokenString:
+		return "string"
+	case tokenLeftBracket:
+		return `"["`
+	case tokenRightBracket:
+		return `"]"`
+	case tokenLeftParen:
+		return `"("`
+	case tokenRightParen:
+		return `")"`
+	case tokenLeftBrace:
+		return `"{"`
+	case tokenRightBrace:
+		return `"}"`
+	case tokenComma:
+		return `","`
+	case tokenSemicolon:
+		return `";"`
+	default:
+		return "unknown"
+	}
+}
+
+type token struct {
+	typ  tokenType
+	str  string
+	num  int64
+	line int
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1}
+}
+
+// Error is the syntax or runtime error of the script.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Msg)
+}
+
+func (l *lexer) errorf(format string, a ...interface{}) error {
+	return &Error{Line: l.line, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{typ: tokenEOF, line: l.line}, nil
+	}
+
+	c := l.src[l.pos]
+	switch c {
+	case '[':
+		return l.punct(tokenLeftBracket), nil
+	case ']':
+		return l.punct(tokenRightBracket), nil
+	case '(':
+		return l.punct(tokenLeftParen), nil
+	case ')':
+		return l.punct(tokenRightParen), nil
+	case '{':
+		return l.punct(tokenLeftBrace), nil
+	case '}':
+		return l.punct(tokenRightBrace), nil
+	case ',':
+		return l.punct(tokenComma), nil
+	case ';':
+		return l.punct(tokenSemicolon), nil
+	case '"':
+		return l.quoted()
+	case ':':
+		l.pos++
+		if l.pos >= len(l.src) || !isIdentStart(l.src[l.pos]) {
+			return token{}, l.errorf("invalid tag")
+		}
+		id := l.identifier()
+		return token{typ: tokenTag, str: ":" + strings.ToLower(id), line: l.line}, nil
+	}
+
+	switch {
+	case isDigit(c):
+		return l.number()
+	case isIdentStart(c):
+		line := l.line
+		id := l.identifier()
+		if strings.EqualFold(id, "text") && l.pos < len(l.src) && l.src[l.pos] == ':' {
+			l.pos++
+			return l.multiLine(line)
+		}
+		return token{typ: tokenIdentifier, str: strings.ToLower(id), line: line}, nil
+	}
+	return token{}, l.errorf("unexpected character %q", c)
+}
+
+func (l *lexer) punct(t tokenType) token {
+	l.pos++
+	return token{typ: t, line: l.line}
+}
+
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf("unterminated comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) identifier() string {
+	start := l.pos
+	for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	n, err := strconv.ParseInt(l.src[start:l.pos], 10, 64)
+	if err != nil {
+		return token{}, l.errorf("invalid number %v", l.src[start:l.pos])
+	}
+	if l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case 'K', 'k':
+			n <<= 10
+			l.pos++
+		case 'M', 'm':
+			n <<= 20
+			l.pos++
+		case 'G', 'g':
+			n <<= 30
+			l.pos++
+		}
+	}
+	return token{typ: tokenNumber, num: n, line: l.line}, nil
+}
+
+func (l *lexer) quoted() (token, error) {
+	line := l.line
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{typ: tokenString, str: b.String(), line: line}, nil
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.src) {
+				break
+			}
+			c = l.src[l.pos]
+		case '\n':
+			l.line++
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return token{}, &Error{Line: line, Msg: "unterminated string"}
+}
+
+func (l *lexer) multiLine(line int) (token, error) {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '#' {
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos >= len(l.src) || l.src[l.pos] != '\n' {
+		return token{}, l.errorf("text: must be followed by a line break")
+	}
+	l.pos++
+	l.line++
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.src) - l.pos
+		} else {
+			end++
+		}
+		s := l.src[l.pos : l.pos+end]
+		l.pos += end
+		l.line++
+		if strings.TrimRight(s, "\r\n") == "." {
+			return token{typ: tokenString, str: b.String(), line: line}, nil
+		}
+		if strings.HasPrefix(s, "..") {
+			s = s[1:]
+		}
+		b.WriteString(s)
+	}
+	return token{}, &Error{Line: line, Msg: "unterminated multi-line string"}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,81 @@
+package sieve
+
+import "strings"
+
+type matcher struct {
+	comparator string
+	matchType  string
+}
+
+// any reports whether any value matches any key.
+func (m matcher) any(values, keys []string) bool {
+	for _, v := range values {
+		for _, k := range keys {
+			if m.match(v, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m matcher) match(value, key string) bool {
+	if m.comparator == "i;ascii-casemap" {
+		value = asciiUpper(value)
+		key = asciiUpper(key)
+	}
+	switch m.matchType {
+	case ":contains":
+		return strings.Contains(value, key)
+	case ":matches":
+		return globMatch([]rune(key), []rune(value))
+	}
+	return value == key
+}
+
+func asciiUpper(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, s)
+}
+
+// globMatch matches the value against the pattern where "*" matches any
+// sequence, "?" matches one character and "\" escapes the next character.
+func globMatch(pattern, value []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(value); i++ {
+				if globMatch(pattern, value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(value) == 0 || value[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		value = value[1:]
+	}
+	return len(value) == 0
+}
@@ -0,0 +1,231 @@
+package sieve
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+type argumentType uint8
+
+const (
+	argumentStrings argumentType = iota + 1
+	argumentNumber
+	argumentTag
+)
+
+type argument struct {
+	typ  argumentType
+	strs []string
+	num  int64
+	tag  string
+}
+
+type test struct {
+	name  string
+	args  []argument
+	tests []test
+	line  int
+}
+
+type command struct {
+	name  string
+	args  []argument
+	tests []test
+	block []command
+	line  int
+}
+
+// Script is the parsed sieve script.
+type Script struct {
+	commands []command
+}
+
+// Parse parses the sieve script.
+func Parse(r io.Reader) (*Script, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{lex: newLexer(string(b))}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	cmds, err := p.commands()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.typ != tokenEOF {
+		return nil, p.unexpected()
+	}
+	s := &Script{commands: cmds}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	return &Error{Line: p.tok.line, Msg: "unexpected " + p.tok.typ.String()}
+}
+
+func (p *parser) expect(t tokenType) error {
+	if p.tok.typ != t {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) commands() ([]command, error) {
+	var cmds []command
+	for p.tok.typ == tokenIdentifier {
+		cmd, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func (p *parser) command() (command, error) {
+	cmd := command{name: p.tok.str, line: p.tok.line}
+	if err := p.advance(); err != nil {
+		return command{}, err
+	}
+	var err error
+	cmd.args, cmd.tests, err = p.arguments()
+	if err != nil {
+		return command{}, err
+	}
+
+	switch p.tok.typ {
+	case tokenSemicolon:
+		return cmd, p.advance()
+	case tokenLeftBrace:
+		if err := p.advance(); err != nil {
+			return command{}, err
+		}
+		cmd.block, err = p.commands()
+		if err != nil {
+			return command{}, err
+		}
+		if cmd.block == nil {
+			cmd.block = []command{}
+		}
+		return cmd, p.expect(tokenRightBrace)
+	}
+	return command{}, p.unexpected()
+}
+
+func (p *parser) arguments() ([]argument, []test, error) {
+	var args []argument
+	for {
+		switch p.tok.typ {
+		case tokenString, tokenLeftBracket:
+			strs, err := p.stringList()
+			if err != nil {
+				return nil, nil, err
+			}
+			args = append(args, argument{typ: argumentStrings, strs: strs})
+		case tokenNumber:
+			args = append(args, argument{typ: argumentNumber, num: p.tok.num})
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+		case tokenTag:
+			args = append(args, argument{typ: argumentTag, tag: p.tok.str})
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+		case tokenIdentifier:
+			t, err := p.test()
+			if err != nil {
+				return nil, nil, err
+			}
+			return args, []test{t}, nil
+		case tokenLeftParen:
+			ts, err := p.testList()
+			if err != nil {
+				return nil, nil, err
+			}
+			return args, ts, nil
+		default:
+			return args, nil, nil
+		}
+	}
+}
+
+func (p *parser) stringList() ([]string, error) {
+	if p.tok.typ == tokenString {
+		s := p.tok.str
+		return []string{s}, p.advance()
+	}
+	if err := p.expect(tokenLeftBracket); err != nil {
+		return nil, err
+	}
+	var strs []string
+	for {
+		if p.tok.typ != tokenString {
+			return nil, p.unexpected()
+		}
+		strs = append(strs, p.tok.str)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.typ != tokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return strs, p.expect(tokenRightBracket)
+}
+
+func (p *parser) test() (test, error) {
+	if p.tok.typ != tokenIdentifier {
+		return test{}, p.unexpected()
+	}
+	t := test{name: p.tok.str, line: p.tok.line}
+	if err := p.advance(); err != nil {
+		return test{}, err
+	}
+	var err error
+	t.args, t.tests, err = p.arguments()
+	return t, err
+}
+
+func (p *parser) testList() ([]test, error) {
+	if err := p.expect(tokenLeftParen); err != nil {
+		return nil, err
+	}
+	var ts []test
+	for {
+		t, err := p.test()
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+		if p.tok.typ != tokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return ts, p.expect(tokenRightParen)
+}
@@ -0,0 +1,449 @@
+package sieve
+
+import (
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	goemmail "github.com/tennashi/goem/mail"
+)
+
+// Extensions are the extensions which can be required.
+var Extensions = []string{"fileinto", "envelope", "imap4flags", "vacation", "copy", "mailbox"}
+
+// ActionType is the type of the action.
+type ActionType uint8
+
+const (
+	_ ActionType = iota
+	// ActionKeep stores the message in the mailbox it was delivered to.
+	ActionKeep
+	// ActionDiscard drops the message silently.
+	ActionDiscard
+	// ActionFileInto stores the message in the other mailbox.
+	ActionFileInto
+	// ActionRedirect passes the message to the other address.
+	ActionRedirect
+	// ActionVacation replies to the sender.
+	ActionVacation
+)
+
+// String is ...
+func (t ActionType) String() string {
+	switch t {
+	case ActionKeep:
+		return "keep"
+	case ActionDiscard:
+		return "discard"
+	case ActionFileInto:
+		return "fileinto"
+	case ActionRedirect:
+		return "redirect"
+	case ActionVacation:
+		return "vacation"
+	default:
+		return "unknown"
+	}
+}
+
+// Action is the result of the script.
+type Action struct {
+	Type ActionType
+	// Mailbox is the destination of fileinto.
+	Mailbox string
+	// Create lets fileinto create the mailbox which doesn't exist.
+	Create bool
+	// Address is the destination of redirect.
+	Address string
+	// Flags are the IMAP flags like \Seen for keep and fileinto.
+	Flags []string
+	// Vacation is the reply of vacation.
+	Vacation *Vacation
+}
+
+// Vacation is the parameters of the vacation action.
+type Vacation struct {
+	Days      int
+	Subject   string
+	From      string
+	Addresses []string
+	Handle    string
+	Reason    string
+	MIME      bool
+}
+
+// Message is the message the script is evaluated against.
+type Message struct {
+	Header mail.Header
+	Size   int64
+	// From is the envelope sender.
+	From string
+	// To is the envelope recipient.
+	To string
+}
+
+type execution struct {
+	msg        *Message
+	header     goemmail.Header
+	flags      []string
+	actions    []Action
+	cancelKeep bool
+	kept       bool
+	stopped    bool
+}
+
+// Execute evaluates the script and returns the actions to take.
+// The implicit keep is included unless it is cancelled.
+func (s *Script) Execute(m *Message) ([]Action, error) {
+	e := &execution{
+		msg:    m,
+		header: goemmail.Header(m.Header).DecodeAll(),
+	}
+	if err := e.run(s.commands); err != nil {
+		return nil, err
+	}
+	if !e.cancelKeep && !e.kept {
+		e.actions = append(e.actions, Action{Type: ActionKeep, Flags: e.flags})
+	}
+	return e.actions, nil
+}
+
+func (e *execution) run(cmds []command) error {
+	matched := false
+	for _, c := range cmds {
+		if e.stopped {
+			return nil
+		}
+		args, err := splitArgs(c.line, c.args)
+		if err != nil {
+			return err
+		}
+
+		switch c.name {
+		case "require":
+		case "if", "elsif", "else":
+			if c.name == "if" {
+				matched = false
+			}
+			if matched {
+				continue
+			}
+			ok := true
+			if c.name != "else" {
+				ok, err = e.test(c.tests[0])
+				if err != nil {
+					return err
+				}
+			}
+			if !ok {
+				continue
+			}
+			matched = true
+			if err := e.run(c.block); err != nil {
+				return err
+			}
+		case "stop":
+			e.stopped = true
+		case "keep":
+			e.kept = true
+			e.actions = append(e.actions, Action{Type: ActionKeep, Flags: args.flags(e.flags)})
+		case "discard":
+			e.cancelKeep = true
+		case "fileinto":
+			if !args.has(":copy") {
+				e.cancelKeep = true
+			}
+			e.addAction(Action{
+				Type:    ActionFileInto,
+				Mailbox: args.rest[0].strs[0],
+				Create:  args.has(":create"),
+				Flags:   args.flags(e.flags),
+			})
+		case "redirect":
+			if !args.has(":copy") {
+				e.cancelKeep = true
+			}
+			e.addAction(Action{Type: ActionRedirect, Address: args.rest[0].strs[0]})
+		case "setflag":
+			e.flags = nil
+			e.flags = addFlags(e.flags, args.rest[0].strs)
+		case "addflag":
+			e.flags = addFlags(e.flags, args.rest[0].strs)
+		case "removeflag":
+			e.flags = removeFlags(e.flags, args.rest[0].strs)
+		case "vacation":
+			e.addAction(Action{Type: ActionVacation, Vacation: args.vacation()})
+		default:
+			return &Error{Line: c.line, Msg: "unknown command " + c.name}
+		}
+	}
+	return nil
+}
+
+func (e *execution) addAction(a Action) {
+	for _, b := range e.actions {
+		if a.Type == b.Type && a.Mailbox == b.Mailbox && a.Address == b.Address && a.Type != ActionKeep {
+			return
+		}
+	}
+	e.actions = append(e.actions, a)
+}
+
+func (e *execution) test(t test) (bool, error) {
+	args, err := splitArgs(t.line, t.args)
+	if err != nil {
+		return false, err
+	}
+
+	switch t.name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "not":
+		ok, err := e.test(t.tests[0])
+		return !ok, err
+	case "allof", "anyof":
+		for _, sub := range t.tests {
+			ok, err := e.test(sub)
+			if err != nil {
+				return false, err
+			}
+			if t.name == "anyof" && ok {
+				return true, nil
+			}
+			if t.name == "allof" && !ok {
+				return false, nil
+			}
+		}
+		return t.name == "allof", nil
+	case "exists":
+		for _, name := range args.rest[0].strs {
+			if len(e.header[textproto.CanonicalMIMEHeaderKey(name)]) == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "size":
+		switch {
+		case args.has(":over"):
+			return e.msg.Size > args.rest[0].num, nil
+		case args.has(":under"):
+			return e.msg.Size < args.rest[0].num, nil
+		}
+		return false, &Error{Line: t.line, Msg: "size requires :over or :under"}
+	case "header":
+		m, err := args.matcher(t.line)
+		if err != nil {
+			return false, err
+		}
+		var values []string
+		for _, name := range args.rest[0].strs {
+			values = append(values, e.header[textproto.CanonicalMIMEHeaderKey(name)]...)
+		}
+		return m.any(values, args.rest[1].strs), nil
+	case "address", "envelope":
+		m, err := args.matcher(t.line)
+		if err != nil {
+			return false, err
+		}
+		part := args.addressPart()
+		var values []string
+		for _, name := range args.rest[0].strs {
+			for _, a := range e.addresses(t.name, name) {
+				values = append(values, addressPart(a, part))
+			}
+		}
+		return m.any(values, args.rest[1].strs), nil
+	case "hasflag":
+		m, err := args.matcher(t.line)
+		if err != nil {
+			return false, err
+		}
+		return m.any(e.flags, splitFlags(args.rest[0].strs)), nil
+	}
+	return false, &Error{Line: t.line, Msg: "unknown test " + t.name}
+}
+
+func (e *execution) addresses(test, name string) []string {
+	if test == "envelope" {
+		switch strings.ToLower(name) {
+		case "from":
+			return []string{e.msg.From}
+		case "to":
+			return []string{e.msg.To}
+		}
+		return nil
+	}
+
+	var addrs []string
+	for _, v := range e.msg.Header[textproto.CanonicalMIMEHeaderKey(name)] {
+		list, err := mail.ParseAddressList(v)
+		if err != nil {
+			addrs = append(addrs, strings.TrimSpace(v))
+			continue
+		}
+		for _, a := range list {
+			addrs = append(addrs, a.Address)
+		}
+	}
+	return addrs
+}
+
+func addressPart(addr, part string) string {
+	i := strings.LastIndex(addr, "@")
+	switch part {
+	case ":localpart":
+		if i < 0 {
+			return addr
+		}
+		return addr[:i]
+	case ":domain":
+		if i < 0 {
+			return ""
+		}
+		return addr[i+1:]
+	}
+	return addr
+}
+
+func splitFlags(strs []string) []string {
+	var flags []string
+	for _, s := range strs {
+		flags = append(flags, strings.Fields(s)...)
+	}
+	return flags
+}
+
+func addFlags(flags, strs []string) []string {
+	for _, f := range splitFlags(strs) {
+		if !containsFold(flags, f) {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+func removeFlags(flags, strs []string) []string {
+	remove := splitFlags(strs)
+	var ret []string
+	for _, f := range flags {
+		if !containsFold(remove, f) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func containsFold(strs []string, s string) bool {
+	for _, t := range strs {
+		if strings.EqualFold(t, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// taggedArgs is the arguments split into the tagged and the positional arguments.
+type taggedArgs struct {
+	tags map[string]argument
+	rest []argument
+}
+
+var valuedTags = map[string]argumentType{
+	":comparator": argumentStrings,
+	":flags":      argumentStrings,
+	":days":       argumentNumber,
+	":subject":    argumentStrings,
+	":from":       argumentStrings,
+	":addresses":  argumentStrings,
+	":handle":     argumentStrings,
+}
+
+func splitArgs(line int, args []argument) (taggedArgs, error) {
+	ta := taggedArgs{tags: map[string]argument{}}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a.typ != argumentTag {
+			ta.rest = append(ta.rest, a)
+			continue
+		}
+		typ, ok := valuedTags[a.tag]
+		if !ok {
+			ta.tags[a.tag] = argument{}
+			continue
+		}
+		if i+1 >= len(args) || args[i+1].typ != typ {
+			return taggedArgs{}, &Error{Line: line, Msg: fmt.Sprintf("%v requires a value", a.tag)}
+		}
+		i++
+		ta.tags[a.tag] = args[i]
+	}
+	return ta, nil
+}
+
+func (a taggedArgs) has(tag string) bool {
+	_, ok := a.tags[tag]
+	return ok
+}
+
+func (a taggedArgs) str(tag string) string {
+	if v, ok := a.tags[tag]; ok && len(v.strs) > 0 {
+		return v.strs[0]
+	}
+	return ""
+}
+
+func (a taggedArgs) flags(current []string) []string {
+	if v, ok := a.tags[":flags"]; ok {
+		return splitFlags(v.strs)
+	}
+	return current
+}
+
+func (a taggedArgs) addressPart() string {
+	for _, p := range []string{":localpart", ":domain", ":all"} {
+		if a.has(p) {
+			return p
+		}
+	}
+	return ":all"
+}
+
+func (a taggedArgs) matcher(line int) (matcher, error) {
+	m := matcher{comparator: "i;ascii-casemap", matchType: ":is"}
+	if a.has(":comparator") {
+		m.comparator = a.str(":comparator")
+		if m.comparator != "i;ascii-casemap" && m.comparator != "i;octet" {
+			return matcher{}, &Error{Line: line, Msg: "unsupported comparator " + m.comparator}
+		}
+	}
+	for _, t := range []string{":is", ":contains", ":matches"} {
+		if a.has(t) {
+			m.matchType = t
+		}
+	}
+	return m, nil
+}
+
+func (a taggedArgs) vacation() *Vacation {
+	v := &Vacation{
+		Days:    7,
+		Subject: a.str(":subject"),
+		From:    a.str(":from"),
+		Handle:  a.str(":handle"),
+		Reason:  a.rest[0].strs[0],
+		MIME:    a.has(":mime"),
+	}
+	if d, ok := a.tags[":days"]; ok {
+		v.Days = int(d.num)
+		if v.Days < 1 {
+			v.Days = 1
+		}
+	}
+	if addrs, ok := a.tags[":addresses"]; ok {
+		v.Addresses = addrs.strs
+	}
+	return v
+}
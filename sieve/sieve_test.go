@@ -0,0 +1,144 @@
+package sieve_test
+
+import (
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem/sieve"
+)
+
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.org, carol@example.net\r\n" +
+	"Subject: =?UTF-8?B?5pel5pys6Kqe?= [list] weekly report\r\n" +
+	"List-Id: <dev.example.com>\r\n" +
+	"\r\n" +
+	"body\r\n"
+
+func Test_Script_Execute(t *testing.T) {
+	cases := map[string]struct {
+		script string
+		want   []sieve.Action
+		err    bool
+	}{
+		"(valid)implicit keep": {
+			script: ``,
+			want:   []sieve.Action{{Type: sieve.ActionKeep}},
+		},
+		"(valid)fileinto by header": {
+			script: `require "fileinto";
+if header :contains "subject" "[LIST]" {
+	fileinto "Lists";
+}`,
+			want: []sieve.Action{{Type: sieve.ActionFileInto, Mailbox: "Lists"}},
+		},
+		"(valid)fileinto create": {
+			script: `require ["fileinto", "mailbox"];
+fileinto :create "New";`,
+			want: []sieve.Action{{Type: sieve.ActionFileInto, Mailbox: "New", Create: true}},
+		},
+		"(valid)decoded header": {
+			script: `if header :is "subject" "日本語 [list] weekly report" { discard; }`,
+			want:   nil,
+		},
+		"(valid)address domain with elsif": {
+			script: `require ["fileinto", "copy"];
+if address :domain "to" "example.com" {
+	fileinto "Wrong";
+} elsif address :localpart :matches "to" "car?l" {
+	fileinto :copy "Carol";
+} else {
+	discard;
+}`,
+			want: []sieve.Action{
+				{Type: sieve.ActionFileInto, Mailbox: "Carol"},
+				{Type: sieve.ActionKeep},
+			},
+		},
+		"(valid)envelope and size": {
+			script: `require "envelope";
+if allof (envelope :is "from" "alice@example.com", size :under 1K, exists "list-id") {
+	redirect "archive";
+	stop;
+}
+keep;`,
+			want: []sieve.Action{{Type: sieve.ActionRedirect, Address: "archive"}},
+		},
+		"(valid)imap4flags": {
+			script: `require ["imap4flags", "fileinto"];
+addflag ["\\Seen", "\\Flagged"];
+removeflag "\\Seen";
+if hasflag "\\flagged" {
+	fileinto :flags "\\Answered" "Flagged";
+}
+keep;`,
+			want: []sieve.Action{
+				{Type: sieve.ActionFileInto, Mailbox: "Flagged", Flags: []string{`\Answered`}},
+				{Type: sieve.ActionKeep, Flags: []string{`\Flagged`}},
+			},
+		},
+		"(valid)vacation": {
+			script: `require "vacation";
+vacation :days 3 :subject "Away" text:
+I am away.
+.
+;`,
+			want: []sieve.Action{
+				{Type: sieve.ActionVacation, Vacation: &sieve.Vacation{
+					Days:    3,
+					Subject: "Away",
+					Reason:  "I am away.\n",
+				}},
+				{Type: sieve.ActionKeep},
+			},
+		},
+		"(invalid)extension not required": {
+			script: `fileinto "Lists";`,
+			err:    true,
+		},
+		"(invalid)else without if": {
+			script: `else { keep; }`,
+			err:    true,
+		},
+		"(invalid)missing semicolon": {
+			script: `keep`,
+			err:    true,
+		},
+		"(invalid)size without number": {
+			script: `if size :over "1" { discard; }`,
+			err:    true,
+		},
+	}
+	for caseName, tt := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			s, err := sieve.Parse(strings.NewReader(tt.script))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("should be error for %v but not", caseName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+
+			msg, err := mail.ReadMessage(strings.NewReader(testMessage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Execute(&sieve.Message{
+				Header: msg.Header,
+				Size:   int64(len(testMessage)),
+				From:   "alice@example.com",
+				To:     "bob@example.org",
+			})
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", caseName, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\n\tgot: %#v\n\twant: %#v", got, tt.want)
+			}
+		})
+	}
+}
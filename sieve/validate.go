@@ -0,0 +1,193 @@
+package sieve
+
+import "fmt"
+
+type commandSpec struct {
+	ext   string
+	args  int
+	tests int
+	block bool
+}
+
+var commandSpecs = map[string]commandSpec{
+	"require":    {args: 1},
+	"if":         {tests: 1, block: true},
+	"elsif":      {tests: 1, block: true},
+	"else":       {block: true},
+	"stop":       {},
+	"keep":       {},
+	"discard":    {},
+	"redirect":   {args: 1},
+	"fileinto":   {ext: "fileinto", args: 1},
+	"setflag":    {ext: "imap4flags", args: 1},
+	"addflag":    {ext: "imap4flags", args: 1},
+	"removeflag": {ext: "imap4flags", args: 1},
+	"vacation":   {ext: "vacation", args: 1},
+}
+
+type testSpec struct {
+	ext   string
+	args  int
+	tests int
+}
+
+// tests -1 means one or more tests.
+var testSpecs = map[string]testSpec{
+	"true":     {},
+	"false":    {},
+	"not":      {tests: 1},
+	"allof":    {tests: -1},
+	"anyof":    {tests: -1},
+	"exists":   {args: 1},
+	"size":     {args: 1},
+	"header":   {args: 2},
+	"address":  {args: 2},
+	"envelope": {ext: "envelope", args: 2},
+	"hasflag":  {ext: "imap4flags", args: 1},
+}
+
+var tagExtensions = map[string]string{
+	":copy":   "copy",
+	":flags":  "imap4flags",
+	":create": "mailbox",
+}
+
+func (s *Script) validate() error {
+	v := &validator{required: map[string]bool{}}
+	return v.commands(s.commands, true)
+}
+
+type validator struct {
+	required map[string]bool
+}
+
+func (v *validator) commands(cmds []command, top bool) error {
+	prev := ""
+	requireAllowed := top
+	for _, c := range cmds {
+		spec, ok := commandSpecs[c.name]
+		if !ok {
+			return &Error{Line: c.line, Msg: "unknown command " + c.name}
+		}
+		if err := v.extension(c.line, spec.ext); err != nil {
+			return err
+		}
+		args, err := v.arguments(c.line, c.name, c.args, spec.args)
+		if err != nil {
+			return err
+		}
+
+		switch c.name {
+		case "require":
+			if !requireAllowed {
+				return &Error{Line: c.line, Msg: "require must come before other commands"}
+			}
+			for _, ext := range args.rest[0].strs {
+				if !supported(ext) {
+					return &Error{Line: c.line, Msg: "unsupported extension " + ext}
+				}
+				v.required[ext] = true
+			}
+		case "elsif", "else":
+			if prev != "if" && prev != "elsif" {
+				return &Error{Line: c.line, Msg: c.name + " must follow if or elsif"}
+			}
+		}
+		if c.name != "require" {
+			requireAllowed = false
+		}
+		prev = c.name
+
+		if len(c.tests) != spec.tests {
+			return &Error{Line: c.line, Msg: fmt.Sprintf("%v requires %v test", c.name, spec.tests)}
+		}
+		for _, t := range c.tests {
+			if err := v.test(t); err != nil {
+				return err
+			}
+		}
+		if spec.block != (c.block != nil) {
+			if spec.block {
+				return &Error{Line: c.line, Msg: c.name + " requires a block"}
+			}
+			return &Error{Line: c.line, Msg: c.name + " cannot have a block"}
+		}
+		if err := v.commands(c.block, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) test(t test) error {
+	spec, ok := testSpecs[t.name]
+	if !ok {
+		return &Error{Line: t.line, Msg: "unknown test " + t.name}
+	}
+	if err := v.extension(t.line, spec.ext); err != nil {
+		return err
+	}
+	args, err := v.arguments(t.line, t.name, t.args, spec.args)
+	if err != nil {
+		return err
+	}
+	if t.name == "size" && (args.rest[0].typ != argumentNumber || args.has(":over") == args.has(":under")) {
+		return &Error{Line: t.line, Msg: "size requires :over or :under and a number"}
+	}
+	if _, err := args.matcher(t.line); err != nil {
+		return err
+	}
+
+	switch {
+	case spec.tests < 0 && len(t.tests) == 0:
+		return &Error{Line: t.line, Msg: t.name + " requires tests"}
+	case spec.tests >= 0 && len(t.tests) != spec.tests:
+		return &Error{Line: t.line, Msg: fmt.Sprintf("%v requires %v test", t.name, spec.tests)}
+	}
+	for _, sub := range t.tests {
+		if err := v.test(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) arguments(line int, name string, as []argument, n int) (taggedArgs, error) {
+	args, err := splitArgs(line, as)
+	if err != nil {
+		return taggedArgs{}, err
+	}
+	for tag := range args.tags {
+		if err := v.extension(line, tagExtensions[tag]); err != nil {
+			return taggedArgs{}, err
+		}
+	}
+	if len(args.rest) != n {
+		return taggedArgs{}, &Error{Line: line, Msg: fmt.Sprintf("%v requires %v arguments", name, n)}
+	}
+	for _, a := range args.rest {
+		if name == "size" {
+			continue
+		}
+		if a.typ != argumentStrings || len(a.strs) == 0 {
+			return taggedArgs{}, &Error{Line: line, Msg: name + " requires strings"}
+		}
+	}
+	return args, nil
+}
+
+func (v *validator) extension(line int, ext string) error {
+	if ext == "" || v.required[ext] {
+		return nil
+	}
+	return &Error{Line: line, Msg: fmt.Sprintf("%v extension is not required", ext)}
+}
+
+func supported(ext string) bool {
+	for _, e := range Extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
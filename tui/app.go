@@ -0,0 +1,618 @@
+// Package tui is the full-screen terminal interface on the maildir root.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tennashi/goem"
+	gmail "github.com/tennashi/goem/mail"
+	"github.com/tennashi/goem/maildir"
+)
+
+// refreshInterval is the interval of checking the changes of the maildirs.
+const refreshInterval = time.Second
+
+type pane uint8
+
+const (
+	paneFolders pane = iota
+	paneMessages
+	paneReader
+)
+
+type folder struct {
+	name   string
+	unread int
+}
+
+type message struct {
+	key     maildir.Key
+	date    time.Time
+	from    string
+	subject string
+}
+
+func (m message) unread() bool {
+	return m.key.SubDir() == maildir.SubDirNew || !m.key.HasFlag(maildir.FlagSeen)
+}
+
+// prompt reads a line in the status line.
+type prompt struct {
+	label string
+	input []rune
+	done  func(string) error
+}
+
+// App is the state of the terminal interface.
+type App struct {
+	root string
+	// special are the names of the inbox opened first and the trash into
+	// which the deleted messages are moved.
+	special  goem.Folders
+	folders  []folder
+	folder   int
+	messages []message
+	cursor   int
+	pane     pane
+	// body is the lines of the message opened in the reader.
+	body   []string
+	scroll int
+	prompt *prompt
+	status string
+	// stamp is the modification times of the maildirs at the last load.
+	stamp string
+	quit  bool
+}
+
+// New returns the App on the maildir root with the messages of the inbox
+// loaded. Only Inbox and Trash of the special folders are used.
+func New(rootDir string, special goem.Folders) (*App, error) {
+	a := &App{root: rootDir, special: special, pane: paneMessages}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	for i, f := range a.folders {
+		if f.name == special.Inbox {
+			a.folder = i
+			return a, a.loadMessages()
+		}
+	}
+	return a, nil
+}
+
+// Reload reads the folders and the messages again, keeping the selection.
+func (a *App) Reload() error {
+	current := a.folderName()
+	mds, err := goem.NewMaildirRoot(a.root).Maildirs()
+	if err != nil {
+		return err
+	}
+	a.folders = a.folders[:0]
+	a.folder = 0
+	for i, md := range mds {
+		if md.Name == current {
+			a.folder = i
+		}
+		a.folders = append(a.folders, folder{name: md.Name, unread: md.Unread})
+	}
+	a.stamp = a.modTimes()
+	return a.loadMessages()
+}
+
+func (a *App) folderName() string {
+	if a.folder >= len(a.folders) {
+		return ""
+	}
+	return a.folders[a.folder].name
+}
+
+func (a *App) maildir(name string) maildir.Maildir {
+	return maildir.Maildir{Path: filepath.Join(a.root, name)}
+}
+
+// loadMessages reads the headers of the messages in the current folder,
+// newest first.
+func (a *App) loadMessages() error {
+	current := ""
+	if m := a.current(); m != nil {
+		current = m.key.Unique()
+	}
+	a.messages = a.messages[:0]
+	a.cursor = 0
+	name := a.folderName()
+	if name == "" {
+		a.closeReader()
+		return nil
+	}
+
+	md := a.maildir(name)
+	for _, s := range []maildir.SubDir{maildir.SubDirNew, maildir.SubDirCur} {
+		keys, err := md.Keys(s)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			a.messages = append(a.messages, readMessage(md, k))
+		}
+	}
+	sort.SliceStable(a.messages, func(i, j int) bool {
+		return a.messages[i].date.After(a.messages[j].date)
+	})
+
+	found := false
+	for i, m := range a.messages {
+		if m.key.Unique() == current {
+			a.cursor = i
+			found = true
+		}
+	}
+	if !found {
+		a.closeReader()
+	}
+	return nil
+}
+
+// readMessage reads the header of the message. The message which cannot be
+// read is listed with the key only.
+func readMessage(md maildir.Maildir, k maildir.Key) message {
+	m := message{key: k, date: time.Unix(int64(k.Second), 0), subject: k.Raw}
+	f, err := md.Open(k)
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(bufio.NewReader(f))
+	if err != nil {
+		return m
+	}
+	h := gmail.Header(msg.Header)
+	if d, err := h.Date(); err == nil {
+		m.date = d
+	}
+	if as := h.Addresses("From"); len(as) > 0 {
+		m.from = as[0].Name
+		if m.from == "" {
+			m.from = as[0].Address
+		}
+	}
+	m.subject = h.Get("Subject")
+	return m
+}
+
+// modTimes returns the modification times of the root and the sub
+// directories, which change when the messages are added, removed or renamed.
+func (a *App) modTimes() string {
+	var b strings.Builder
+	paths := []string{a.root}
+	for _, f := range a.folders {
+		for _, s := range []string{"new", "cur"} {
+			paths = append(paths, filepath.Join(a.root, f.name, s))
+		}
+	}
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&b, "%v;", info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+func (a *App) current() *message {
+	if a.cursor >= len(a.messages) {
+		return nil
+	}
+	return &a.messages[a.cursor]
+}
+
+// Run runs the interface on the terminal until it is quit or ctx is done.
+func (a *App) Run(ctx context.Context, in *os.File, out io.Writer) error {
+	t, err := openTerm(in, out)
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	keys := make(chan rune)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			r, err := t.readKey()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case keys <- r:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	w := bufio.NewWriter(out)
+	width, height := t.size()
+	for {
+		a.render(w, width, height)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case r := <-keys:
+			if err := a.HandleKey(r); err != nil {
+				a.status = err.Error()
+			}
+			if a.quit {
+				return nil
+			}
+		case <-ticker.C:
+			if a.modTimes() != a.stamp {
+				if err := a.Reload(); err != nil {
+					a.status = err.Error()
+				}
+			}
+			width, height = t.size()
+		}
+	}
+}
+
+// HandleKey updates the state by the key pressed.
+func (a *App) HandleKey(r rune) error {
+	if a.prompt != nil {
+		return a.promptKey(r)
+	}
+	a.status = ""
+
+	switch r {
+	case keyCtrlC:
+		a.quit = true
+		return nil
+	case keyCtrlL:
+		return a.Reload()
+	case 'q':
+		if a.pane == paneReader {
+			a.closeReader()
+			return nil
+		}
+		a.quit = true
+		return nil
+	case keyTab:
+		switch a.pane {
+		case paneFolders:
+			a.pane = paneMessages
+		case paneMessages:
+			if a.body != nil {
+				a.pane = paneReader
+			} else {
+				a.pane = paneFolders
+			}
+		default:
+			a.pane = paneFolders
+		}
+		return nil
+	}
+
+	switch a.pane {
+	case paneFolders:
+		return a.folderKey(r)
+	case paneMessages:
+		return a.messageKey(r)
+	default:
+		return a.readerKey(r)
+	}
+}
+
+func (a *App) folderKey(r rune) error {
+	i := a.folder
+	switch r {
+	case 'j', keyDown:
+		i++
+	case 'k', keyUp:
+		i--
+	case 'g', keyHome:
+		i = 0
+	case 'G', keyEnd:
+		i = len(a.folders) - 1
+	case keyEnter, 'l', keyRight:
+		a.pane = paneMessages
+		return nil
+	default:
+		return nil
+	}
+	if i < 0 || i >= len(a.folders) || i == a.folder {
+		return nil
+	}
+	a.folder = i
+	a.closeReader()
+	return a.loadMessages()
+}
+
+func (a *App) messageKey(r rune) error {
+	switch r {
+	case 'j', keyDown:
+		a.move(1)
+	case 'k', keyUp:
+		a.move(-1)
+	case keyPageDown, ' ':
+		a.move(10)
+	case keyPageUp:
+		a.move(-10)
+	case 'g', keyHome:
+		a.cursor = 0
+	case 'G', keyEnd:
+		a.cursor = len(a.messages) - 1
+	case 'h', keyLeft:
+		a.pane = paneFolders
+	case keyEnter, 'l', keyRight:
+		return a.open()
+	default:
+		return a.commandKey(r)
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+	return nil
+}
+
+func (a *App) readerKey(r rune) error {
+	switch r {
+	case 'j', keyDown, keyEnter:
+		a.scroll++
+	case 'k', keyUp:
+		a.scroll--
+	case ' ', keyPageDown:
+		a.scroll += 10
+	case keyPageUp, keyBackspace:
+		a.scroll -= 10
+	case 'g', keyHome:
+		a.scroll = 0
+	case 'G', keyEnd:
+		a.scroll = len(a.body)
+	case 'J':
+		a.move(1)
+		return a.open()
+	case 'K':
+		a.move(-1)
+		return a.open()
+	case 'h', keyLeft:
+		a.closeReader()
+	default:
+		return a.commandKey(r)
+	}
+	if a.scroll > len(a.body)-1 {
+		a.scroll = len(a.body) - 1
+	}
+	if a.scroll < 0 {
+		a.scroll = 0
+	}
+	return nil
+}
+
+// commandKey handles the keys changing the current message.
+func (a *App) commandKey(r rune) error {
+	if a.current() == nil {
+		return nil
+	}
+	switch r {
+	case 'r':
+		return a.toggleFlag(maildir.FlagSeen)
+	case 'f':
+		return a.toggleFlag(maildir.FlagFlagged)
+	case 'd':
+		return a.delete()
+	case 'm':
+		a.prompt = &prompt{label: "move to: ", done: a.moveTo}
+	}
+	return nil
+}
+
+func (a *App) promptKey(r rune) error {
+	p := a.prompt
+	switch r {
+	case keyEsc, keyCtrlC:
+		a.prompt = nil
+	case keyEnter:
+		a.prompt = nil
+		return p.done(string(p.input))
+	case keyBackspace, '\b':
+		if len(p.input) > 0 {
+			p.input = p.input[:len(p.input)-1]
+		}
+	default:
+		if r >= ' ' && r < keyUp {
+			p.input = append(p.input, r)
+		}
+	}
+	return nil
+}
+
+func (a *App) move(n int) {
+	a.cursor += n
+	if a.cursor >= len(a.messages) {
+		a.cursor = len(a.messages) - 1
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+}
+
+// open opens the current message in the reader and marks it as read.
+func (a *App) open() error {
+	m := a.current()
+	if m == nil {
+		return nil
+	}
+	md := a.maildir(a.folderName())
+	if !m.key.HasFlag(maildir.FlagSeen) || m.key.SubDir() == maildir.SubDirNew {
+		if err := a.setFlags(m, addFlag(m.key.Flags, maildir.FlagSeen)); err != nil {
+			return err
+		}
+	}
+
+	f, err := md.Open(m.key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return err
+	}
+	h := gmail.Header(msg.Header)
+	p, text := gmail.ParseText(h, b)
+
+	var lines []string
+	for _, key := range []string{"Date", "From", "To", "Cc", "Subject"} {
+		if v := h.Get(key); v != "" {
+			lines = append(lines, key+": "+v)
+		}
+	}
+	var attachments []string
+	if p != nil {
+		p.Walk(func(c *gmail.Part) {
+			if name := c.Filename(); name != "" {
+				attachments = append(attachments, fmt.Sprintf("[%v] %v (%v, %v bytes)", c.ID, name, c.MediaType, len(c.Body)))
+			}
+		})
+	}
+	if len(attachments) > 0 {
+		lines = append(lines, "Attachments:")
+		for _, s := range attachments {
+			lines = append(lines, "  "+s)
+		}
+	}
+	lines = append(lines, "")
+
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	lines = append(lines, strings.Split(strings.TrimRight(text, "\n"), "\n")...)
+
+	a.body = lines
+	a.scroll = 0
+	a.pane = paneReader
+	return nil
+}
+
+func (a *App) closeReader() {
+	a.body = nil
+	a.scroll = 0
+	if a.pane == paneReader {
+		a.pane = paneMessages
+	}
+}
+
+func (a *App) setFlags(m *message, flags []string) error {
+	k, err := a.maildir(a.folderName()).SetFlags(m.key, flags)
+	if err != nil {
+		return err
+	}
+	m.key = k
+	a.refreshUnread()
+	return nil
+}
+
+func (a *App) toggleFlag(flag string) error {
+	m := a.current()
+	if m.key.HasFlag(flag) {
+		return a.setFlags(m, removeFlag(m.key.Flags, flag))
+	}
+	return a.setFlags(m, addFlag(m.key.Flags, flag))
+}
+
+// delete moves the current message into Trash, or removes it in Trash or
+// if there is no Trash.
+func (a *App) delete() error {
+	trash := a.special.Trash
+	if a.folderName() != trash && maildir.IsMaildir(filepath.Join(a.root, trash)) {
+		if err := a.moveTo(trash); err != nil {
+			return err
+		}
+		a.status = "moved to " + trash
+		return nil
+	}
+	if err := a.maildir(a.folderName()).Remove(a.current().key); err != nil {
+		return err
+	}
+	a.status = "deleted"
+	a.removeCurrent()
+	return nil
+}
+
+func (a *App) moveTo(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	if name == a.folderName() {
+		return nil
+	}
+	path := filepath.Join(a.root, name)
+	if !maildir.IsMaildir(path) {
+		return fmt.Errorf("%v is not maildir", name)
+	}
+	if _, err := a.maildir(a.folderName()).Move(a.current().key, maildir.Maildir{Path: path}); err != nil {
+		return err
+	}
+	a.status = "moved to " + name
+	a.removeCurrent()
+	return nil
+}
+
+func (a *App) removeCurrent() {
+	a.messages = append(a.messages[:a.cursor], a.messages[a.cursor+1:]...)
+	a.move(0)
+	a.closeReader()
+	a.refreshUnread()
+}
+
+func (a *App) refreshUnread() {
+	for i, f := range a.folders {
+		if st, err := a.maildir(f.name).Stat(); err == nil {
+			a.folders[i].unread = st.Unread
+		}
+	}
+	a.stamp = a.modTimes()
+}
+
+func addFlag(flags []string, flag string) []string {
+	for _, f := range flags {
+		if f == flag {
+			return flags
+		}
+	}
+	return append(append([]string{}, flags...), flag)
+}
+
+func removeFlag(flags []string, flag string) []string {
+	ret := make([]string, 0, len(flags))
+	for _, f := range flags {
+		if f != flag {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,183 @@
+package tui_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+	"github.com/tennashi/goem/maildir"
+	"github.com/tennashi/goem/tui"
+)
+
+func setup(t *testing.T, folders ...string) (string, func()) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "goem-tui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range folders {
+		if _, err := maildir.Create(filepath.Join(root, f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, func() { os.RemoveAll(root) }
+}
+
+func deliver(t *testing.T, root, folder, subject string) {
+	t.Helper()
+	md := maildir.Maildir{Path: filepath.Join(root, folder)}
+	msg := "From: alice@example.com\r\nSubject: " + subject + "\r\n\r\nbody\r\n"
+	if _, err := md.Deliver(strings.NewReader(msg), maildir.DeliverOption{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// files returns the names of the messages in the folder by the sub directories.
+func files(t *testing.T, root, folder string) map[string][]string {
+	t.Helper()
+	ret := map[string][]string{}
+	for _, s := range []string{"new", "cur"} {
+		infos, err := ioutil.ReadDir(filepath.Join(root, folder, s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, info := range infos {
+			ret[s] = append(ret[s], info.Name())
+		}
+	}
+	return ret
+}
+
+func Test_App_HandleKey(t *testing.T) {
+	cases := map[string]struct {
+		folders []string
+		keys    string
+		check   func(t *testing.T, root string)
+	}{
+		"(valid)open marks seen": {
+			folders: []string{"INBOX"},
+			keys:    "\r",
+			check: func(t *testing.T, root string) {
+				fs := files(t, root, "INBOX")
+				if len(fs["new"]) != 0 || len(fs["cur"]) != 1 || !strings.HasSuffix(fs["cur"][0], ":2,S") {
+					t.Fatalf("\n\tgot: %v\n\twant: a message in cur with S", fs)
+				}
+			},
+		},
+		"(valid)toggle flagged": {
+			folders: []string{"INBOX"},
+			keys:    "ff f",
+			check: func(t *testing.T, root string) {
+				fs := files(t, root, "INBOX")
+				if len(fs["cur"]) != 1 || !strings.HasSuffix(fs["cur"][0], ":2,F") {
+					t.Fatalf("\n\tgot: %v\n\twant: a message in cur with F", fs)
+				}
+			},
+		},
+		"(valid)delete moves into Trash": {
+			folders: []string{"INBOX", "Trash"},
+			keys:    "d",
+			check: func(t *testing.T, root string) {
+				if fs := files(t, root, "INBOX"); len(fs["new"])+len(fs["cur"]) != 0 {
+					t.Fatalf("\n\tgot: %v\n\twant: empty INBOX", fs)
+				}
+				if fs := files(t, root, "Trash"); len(fs["new"]) != 1 {
+					t.Fatalf("\n\tgot: %v\n\twant: a message in Trash", fs)
+				}
+			},
+		},
+		"(valid)delete removes without Trash": {
+			folders: []string{"INBOX"},
+			keys:    "d",
+			check: func(t *testing.T, root string) {
+				if fs := files(t, root, "INBOX"); len(fs["new"])+len(fs["cur"]) != 0 {
+					t.Fatalf("\n\tgot: %v\n\twant: empty INBOX", fs)
+				}
+			},
+		},
+		"(valid)move by prompt": {
+			folders: []string{"INBOX", "archive"},
+			keys:    "marchive\r",
+			check: func(t *testing.T, root string) {
+				if fs := files(t, root, "archive"); len(fs["new"]) != 1 {
+					t.Fatalf("\n\tgot: %v\n\twant: a message in archive", fs)
+				}
+			},
+		},
+		"(valid)cancel prompt": {
+			folders: []string{"INBOX", "archive"},
+			keys:    "marchive\x1b",
+			check: func(t *testing.T, root string) {
+				if fs := files(t, root, "INBOX"); len(fs["new"]) != 1 {
+					t.Fatalf("\n\tgot: %v\n\twant: a message in INBOX", fs)
+				}
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			root, cleanup := setup(t, tt.folders...)
+			defer cleanup()
+			deliver(t, root, "INBOX", "hello")
+
+			app, err := tui.New(root, goem.DefaultFolders)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", root, err)
+			}
+			for _, r := range tt.keys {
+				if err := app.HandleKey(r); err != nil {
+					t.Fatalf("should not be error for %q but %v", r, err)
+				}
+			}
+			tt.check(t, root)
+		})
+	}
+}
+
+func Test_App_HandleKey_moveInvalid(t *testing.T) {
+	root, cleanup := setup(t, "INBOX")
+	defer cleanup()
+	deliver(t, root, "INBOX", "hello")
+
+	app, err := tui.New(root, goem.DefaultFolders)
+	if err != nil {
+		t.Fatalf("should not be error for %v but %v", root, err)
+	}
+	for _, r := range "mnowhere" {
+		if err := app.HandleKey(r); err != nil {
+			t.Fatalf("should not be error for %q but %v", r, err)
+		}
+	}
+	if err := app.HandleKey('\r'); err == nil {
+		t.Fatalf("should be error for moving into the missing folder")
+	}
+}
+
+func Test_App_HandleKey_openUndecodable(t *testing.T) {
+	cases := map[string]string{
+		"(valid)truncated multipart": "Subject: truncated\r\nContent-Type: multipart/mixed; boundary=b1\r\n\r\n--b1\r\n\r\ntruncated",
+		"(valid)unknown charset":     "Subject: charset\r\nContent-Type: text/plain; charset=x-unknown\r\n\r\nraw body\r\n",
+	}
+	for name, msg := range cases {
+		t.Run(name, func(t *testing.T) {
+			root, cleanup := setup(t, "INBOX")
+			defer cleanup()
+			md := maildir.Maildir{Path: filepath.Join(root, "INBOX")}
+			if _, err := md.Deliver(strings.NewReader(msg), maildir.DeliverOption{}); err != nil {
+				t.Fatal(err)
+			}
+
+			app, err := tui.New(root, goem.DefaultFolders)
+			if err != nil {
+				t.Fatalf("should not be error for %v but %v", root, err)
+			}
+			if err := app.HandleKey('\r'); err != nil {
+				t.Fatalf("should not be error for %q but %v", msg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Keys other than the printable characters.
+const (
+	keyUp = iota + 0x110000
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter     = '\r'
+	keyTab       = '\t'
+	keyEsc       = 0x1b
+	keyBackspace = 0x7f
+	keyCtrlL     = 0x0c
+	keyCtrlC     = 0x03
+)
+
+// term is the terminal in the raw mode.
+type term struct {
+	in    *os.File
+	out   io.Writer
+	state *terminal.State
+	keys  *bufio.Reader
+}
+
+func openTerm(in *os.File, out io.Writer) (*term, error) {
+	state, err := terminal.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	t := &term{in: in, out: out, state: state, keys: bufio.NewReader(in)}
+	// use the alternate screen and hide the cursor.
+	io.WriteString(out, "\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+func (t *term) close() error {
+	io.WriteString(t.out, "\x1b[?25h\x1b[?1049l")
+	return terminal.Restore(int(t.in.Fd()), t.state)
+}
+
+func (t *term) size() (int, int) {
+	w, h, err := terminal.GetSize(int(t.in.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// readKey reads a key, decoding the escape sequences of the special keys.
+func (t *term) readKey() (rune, error) {
+	r, _, err := t.keys.ReadRune()
+	if err != nil || r != keyEsc {
+		return r, err
+	}
+	if t.keys.Buffered() == 0 {
+		return keyEsc, nil
+	}
+	b, _ := t.keys.ReadByte()
+	if b != '[' && b != 'O' {
+		return keyEsc, nil
+	}
+	seq := ""
+	for t.keys.Buffered() > 0 {
+		c, _ := t.keys.ReadByte()
+		seq += string(c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch seq {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~":
+		return keyHome, nil
+	case "F", "4~":
+		return keyEnd, nil
+	case "5~":
+		return keyPageUp, nil
+	case "6~":
+		return keyPageDown, nil
+	}
+	return keyEsc, nil
+}
@@ -0,0 +1,268 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/text/width"
+)
+
+const (
+	styleReset    = "\x1b[0m"
+	styleSelected = "\x1b[7m"
+	styleInactive = "\x1b[1m"
+	styleUnread   = "\x1b[1m"
+	styleTitle    = "\x1b[4m"
+)
+
+// folderPaneWidth is the width of the folder pane on the wide terminal.
+const folderPaneWidth = 20
+
+// render draws the whole screen.
+func (a *App) render(w io.Writer, cols, rows int) {
+	if cols < 20 || rows < 4 {
+		fmt.Fprint(w, "\x1b[H\x1b[2Jterminal too small")
+		return
+	}
+	left := folderPaneWidth
+	if cols < 80 {
+		left = cols / 4
+	}
+	right := cols - left - 1
+	height := rows - 1
+
+	folders := a.folderLines(left, height)
+	var pane []string
+	if a.body == nil {
+		pane = a.messageLines(right, height)
+	} else {
+		listHeight := height / 3
+		pane = a.messageLines(right, listHeight)
+		pane = append(pane, strings.Repeat("─", right))
+		pane = append(pane, a.readerLines(right, height-listHeight-1)...)
+	}
+
+	fmt.Fprint(w, "\x1b[H")
+	for i := 0; i < height; i++ {
+		fmt.Fprintf(w, "\x1b[%d;1H%s│%s", i+1, folders[i], pane[i])
+	}
+	fmt.Fprintf(w, "\x1b[%d;1H%s", rows, a.statusLine(cols))
+}
+
+func (a *App) highlight(p pane) string {
+	if a.pane == p {
+		return styleSelected
+	}
+	return styleInactive
+}
+
+func (a *App) folderLines(cols, rows int) []string {
+	lines := make([]string, rows)
+	lines[0] = cell("Folders", cols, styleTitle)
+	top := scrollTop(a.folder, len(a.folders), rows-1)
+	for i := 1; i < rows; i++ {
+		n := top + i - 1
+		if n >= len(a.folders) {
+			lines[i] = cell("", cols, "")
+			continue
+		}
+		f := a.folders[n]
+		s := f.name
+		if f.unread > 0 {
+			count := fmt.Sprintf(" %d", f.unread)
+			s = truncate(s, cols-len(count)) + count
+		}
+		style := ""
+		if n == a.folder {
+			style = a.highlight(paneFolders)
+		}
+		lines[i] = cell(s, cols, style)
+	}
+	return lines
+}
+
+func (a *App) messageLines(cols, rows int) []string {
+	lines := make([]string, rows)
+	lines[0] = cell(fmt.Sprintf("%v (%d)", a.folderName(), len(a.messages)), cols, styleTitle)
+	top := scrollTop(a.cursor, len(a.messages), rows-1)
+	for i := 1; i < rows; i++ {
+		n := top + i - 1
+		if n >= len(a.messages) {
+			lines[i] = cell("", cols, "")
+			continue
+		}
+		m := a.messages[n]
+		s := fmt.Sprintf("%s %s %s %s", messageFlags(m), formatDate(m.date), cell(m.from, 18, ""), m.subject)
+		style := ""
+		switch {
+		case n == a.cursor:
+			style = a.highlight(paneMessages)
+		case m.unread():
+			style = styleUnread
+		}
+		lines[i] = cell(s, cols, style)
+	}
+	return lines
+}
+
+// messageFlags returns the marks of the message: N for unread, F for
+// flagged, R for replied and T for trashed.
+func messageFlags(m message) string {
+	marks := []byte("    ")
+	if m.unread() {
+		marks[0] = 'N'
+	}
+	for i, f := range []string{"F", "R", "T"} {
+		if m.key.HasFlag(f) {
+			marks[i+1] = f[0]
+		}
+	}
+	return string(marks)
+}
+
+func formatDate(t time.Time) string {
+	t = t.Local()
+	now := time.Now()
+	if t.Year() == now.Year() && t.YearDay() == now.YearDay() {
+		return t.Format("     15:04")
+	}
+	if t.Year() == now.Year() {
+		return t.Format("    Jan 02")
+	}
+	return t.Format("2006-01-02")
+}
+
+func (a *App) readerLines(cols, rows int) []string {
+	var wrapped []string
+	for _, l := range a.body {
+		wrapped = append(wrapped, wrap(l, cols)...)
+	}
+	top := a.scroll
+	if max := len(wrapped) - rows; top > max {
+		top = max
+	}
+	if top < 0 {
+		top = 0
+	}
+	lines := make([]string, rows)
+	for i := range lines {
+		s := ""
+		if top+i < len(wrapped) {
+			s = wrapped[top+i]
+		}
+		lines[i] = cell(s, cols, "")
+	}
+	return lines
+}
+
+func (a *App) statusLine(cols int) string {
+	if a.prompt != nil {
+		return cell(a.prompt.label+string(a.prompt.input)+"_", cols, "")
+	}
+	if a.status != "" {
+		return cell(a.status, cols, styleSelected)
+	}
+	help := "q:quit  tab:pane  j/k:move  enter:open  r:read  f:flag  d:delete  m:move  ^L:refresh"
+	return cell(help, cols, styleSelected)
+}
+
+// scrollTop returns the first index shown so that the cursor is visible.
+func scrollTop(cursor, n, rows int) int {
+	if rows <= 0 || n <= rows {
+		return 0
+	}
+	top := cursor - rows/2
+	if top < 0 {
+		top = 0
+	}
+	if top > n-rows {
+		top = n - rows
+	}
+	return top
+}
+
+// cell returns s truncated and padded to the display width with the style.
+func cell(s string, cols int, style string) string {
+	s = truncate(printable(s), cols)
+	s += strings.Repeat(" ", cols-stringWidth(s))
+	if style == "" {
+		return s
+	}
+	return style + s + styleReset
+}
+
+// printable removes the control characters, which could move the cursor or
+// change the terminal settings.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r >= 0x7f && r < 0xa0 {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func truncate(s string, cols int) string {
+	w := 0
+	for i, r := range s {
+		rw := runeWidth(r)
+		if w+rw > cols {
+			return s[:i]
+		}
+		w += rw
+	}
+	return s
+}
+
+// wrap splits s into the lines of the display width.
+func wrap(s string, cols int) []string {
+	s = printable(expandTabs(s))
+	var lines []string
+	for stringWidth(s) > cols {
+		l := truncate(s, cols)
+		if l == "" {
+			break
+		}
+		lines = append(lines, l)
+		s = s[len(l):]
+	}
+	return append(lines, s)
+}
+
+func expandTabs(s string) string {
+	if !strings.ContainsRune(s, '\t') {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col += runeWidth(r)
+	}
+	return b.String()
+}
+
+func stringWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// runeWidth returns the number of the columns of the rune on the terminal.
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,284 @@
+package goem
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// ConfigError is an error in the configuration.
+type ConfigError struct {
+	// At is "file:line:col" of the value, or the environment variable it is
+	// set by.
+	At string
+	// Key is the key of the value such as "accounts[0].root_dir".
+	Key string
+	Msg string
+}
+
+func (e *ConfigError) Error() string {
+	var ss []string
+	for _, s := range []string{e.At, e.Key, e.Msg} {
+		if s != "" {
+			ss = append(ss, s)
+		}
+	}
+	return strings.Join(ss, ": ")
+}
+
+// ConfigErrors is all the errors found in the configuration.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	ss := make([]string, len(e))
+	for i, err := range e {
+		ss[i] = err.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// tomlPosition is the position go-toml puts in front of the messages.
+var tomlPosition = regexp.MustCompile(`^\((\d+), (\d+)\): `)
+
+// positionError converts the error of go-toml into "file:line:col: msg".
+func positionError(path, key string, err error) *ConfigError {
+	msg := err.Error()
+	at := path
+	if m := tomlPosition.FindStringSubmatch(msg); m != nil {
+		at = fmt.Sprintf("%v:%v:%v", path, m[1], m[2])
+		msg = msg[len(m[0]):]
+	}
+	return &ConfigError{At: at, Key: key, Msg: msg}
+}
+
+// locator finds where the values of the configuration are set.
+type locator struct {
+	path string
+	tree *toml.Tree
+	// env is the environment variables overriding the keys.
+	env map[string]string
+	// fixed is the positions of the keys rewritten before decoding.
+	fixed map[string]toml.Position
+}
+
+func newLocator(path string, tree *toml.Tree) *locator {
+	return &locator{path: path, tree: tree, env: map[string]string{}, fixed: map[string]toml.Position{}}
+}
+
+// fix remembers the position of the key before it is rewritten.
+func (l *locator) fix(key string, t *toml.Tree) {
+	l.fixed[key] = t.GetPosition(key)
+}
+
+// at returns where the key in the tree is set.
+func (l *locator) at(t *toml.Tree, key string) string {
+	if t == l.tree {
+		if name, ok := l.env[key]; ok {
+			return "$" + name
+		}
+		if p, ok := l.fixed[key]; ok {
+			return l.format(p)
+		}
+	}
+	p := t.GetPosition(key)
+	if p.Invalid() {
+		// the table of the key missing.
+		p = t.Position()
+	}
+	return l.format(p)
+}
+
+func (l *locator) format(p toml.Position) string {
+	if l.path == "" || p.Invalid() {
+		return l.path
+	}
+	return fmt.Sprintf("%v:%v:%v", l.path, p.Line, p.Col)
+}
+
+// table returns the table at the key in t, or t if it is not a table.
+func table(t *toml.Tree, key string) *toml.Tree {
+	if sub, ok := t.Get(key).(*toml.Tree); ok {
+		return sub
+	}
+	return t
+}
+
+// tables returns the tables of the array of tables at the key.
+func (l *locator) tables(key string) []*toml.Tree {
+	ts, _ := l.tree.Get(key).([]*toml.Tree)
+	return ts
+}
+
+// unknownKeys reports the keys in the tree not in the struct typ.
+func unknownKeys(l *locator, t *toml.Tree, typ reflect.Type, prefix string) ConfigErrors {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.Split(f.Tag.Get("toml"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = f.Type
+		}
+	}
+
+	var errs ConfigErrors
+	keys := t.Keys()
+	sort.Strings(keys)
+	for _, k := range keys {
+		ft, ok := fields[k]
+		if !ok {
+			p := t.GetPositionPath([]string{k})
+			errs = append(errs, &ConfigError{At: l.format(p), Key: prefix + k, Msg: "unknown key"})
+			continue
+		}
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch v := t.GetPath([]string{k}).(type) {
+		case *toml.Tree:
+			if ft.Kind() == reflect.Struct {
+				errs = append(errs, unknownKeys(l, v, ft, prefix+k+".")...)
+			}
+		case []*toml.Tree:
+			if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct {
+				for i, sub := range v {
+					errs = append(errs, unknownKeys(l, sub, ft.Elem(), fmt.Sprintf("%v%v[%v].", prefix, k, i))...)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validator collects the errors of the values.
+type validator struct {
+	l    *locator
+	errs ConfigErrors
+}
+
+func (v *validator) add(t *toml.Tree, prefix, key, format string, a ...interface{}) {
+	v.errs = append(v.errs, &ConfigError{At: v.l.at(t, key), Key: prefix + key, Msg: fmt.Sprintf(format, a...)})
+}
+
+// server validates the settings of the IMAP, POP3 or SMTP server.
+func (v *validator) server(t *toml.Tree, prefix, host string, port int, tls string) {
+	if host == "" {
+		v.add(t, prefix, "host", "host is required")
+	}
+	if port < 0 || port > 65535 {
+		v.add(t, prefix, "port", "invalid port: %v", port)
+	}
+	switch tls {
+	case "", "tls", "starttls", "none":
+	default:
+		v.add(t, prefix, "tls", `tls must be "tls", "starttls" or "none": %v`, tls)
+	}
+}
+
+// file validates the file which must exist if it is set.
+func (v *validator) file(t *toml.Tree, key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.add(t, "", key, "%v", err)
+	}
+}
+
+// dir validates the directory which must exist.
+func (v *validator) dir(t *toml.Tree, prefix, key, path string) {
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		v.add(t, prefix, key, "%v doesn't exist", path)
+	case err != nil:
+		v.add(t, prefix, key, "%v", err)
+	case !info.IsDir():
+		v.add(t, prefix, key, "%v is not a directory", path)
+	}
+}
+
+// validate validates the configuration with the defaults set.
+func (c *Config) validate(l *locator) ConfigErrors {
+	v := &validator{l: l}
+	top := l.tree
+
+	if n, err := strconv.Atoi(c.Server.Port); err != nil || n < 1 || n > 65535 {
+		v.add(top, "", "server.port", "invalid port: %v", c.Server.Port)
+	}
+	if c.Server.Account != "" {
+		if _, err := c.Account(c.Server.Account); err != nil {
+			v.add(top, "", "server.account", "%v", err)
+		}
+	}
+	switch srv := c.Server; {
+	case srv.CertFile != "" && srv.KeyFile == "":
+		v.add(top, "", "server.cert_file", "key_file is required with cert_file")
+	case srv.CertFile == "" && srv.KeyFile != "":
+		v.add(top, "", "server.key_file", "cert_file is required with key_file")
+	}
+	v.file(top, "server.cert_file", c.Server.CertFile)
+	v.file(top, "server.key_file", c.Server.KeyFile)
+	v.file(top, "filter.script", c.Filter.Script)
+	if c.Filter.Interval < 0 {
+		v.add(top, "", "filter.interval", "invalid interval: %v", c.Filter.Interval)
+	}
+	if c.Remote.URL != "" {
+		if u, err := url.Parse(c.Remote.URL); err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			v.add(top, "", "remote.url", "invalid URL: %v", c.Remote.URL)
+		}
+	}
+
+	tables := l.tables("accounts")
+	names := map[string]bool{}
+	for i := range c.Accounts {
+		a := &c.Accounts[i]
+		// the account of root_dir is not in the tables.
+		t, prefix := top, ""
+		if i < len(tables) {
+			t, prefix = tables[i], fmt.Sprintf("accounts[%v].", i)
+		}
+		switch {
+		case a.Name == "":
+			v.add(t, prefix, "name", "name is required")
+		case names[a.Name]:
+			v.add(t, prefix, "name", "account %v is duplicated", a.Name)
+		}
+		names[a.Name] = true
+		if a.RootDir == "" {
+			v.add(t, prefix, "root_dir", "root_dir is required")
+		} else {
+			v.dir(t, prefix, "root_dir", a.RootDir)
+		}
+		if s := a.IMAP; s != nil {
+			v.server(table(t, "imap"), prefix+"imap.", s.Host, s.Port, s.TLS)
+		}
+		if s := a.SMTP; s != nil {
+			v.server(table(t, "smtp"), prefix+"smtp.", s.Host, s.Port, s.TLS)
+		}
+	}
+
+	for i, t := range l.tables("imap") {
+		a := c.IMAP[i]
+		prefix := fmt.Sprintf("imap[%v].", i)
+		if a.Name == "" {
+			v.add(t, prefix, "name", "name is required")
+		}
+		v.server(t, prefix, a.Host, a.Port, a.TLS)
+	}
+	for i, t := range l.tables("pop3") {
+		a := c.POP3[i]
+		prefix := fmt.Sprintf("pop3[%v].", i)
+		if a.Name == "" {
+			v.add(t, prefix, "name", "name is required")
+		}
+		v.server(t, prefix, a.Host, a.Port, a.TLS)
+	}
+	return v.errs
+}
@@ -0,0 +1,167 @@
+package goem_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tennashi/goem"
+)
+
+func Test_LoadConfig_invalid(t *testing.T) {
+	files := map[string]string{
+		"mail/.keep": "",
+		"file":       "",
+	}
+	cases := map[string]struct {
+		config string
+		env    map[string]string
+		// want are the errors with At relative to the directory of the
+		// configuration file unless it is an environment variable.
+		want []goem.ConfigError
+	}{
+		"(invalid)syntax": {
+			config: "root_dir = \"{{dir}}/mail\"\n[server\n",
+			want: []goem.ConfigError{
+				{At: "config.toml:2:2", Msg: "unexpected token unclosed table key, was expecting a table key"},
+			},
+		},
+		"(invalid)unknown keys": {
+			config: `root_dir = "{{dir}}/mail"
+[server]
+prot = 8080
+[[accounts]]
+name = "home"
+root_dir = "{{dir}}/mail"
+rootdir = "{{dir}}/mail"
+`,
+			want: []goem.ConfigError{
+				{At: "config.toml:7:1", Key: "accounts[0].rootdir", Msg: "unknown key"},
+				{At: "config.toml:3:1", Key: "server.prot", Msg: "unknown key"},
+			},
+		},
+		"(invalid)integer port": {
+			config: "root_dir = \"{{dir}}/mail\"\n[server]\nport = 70000\n",
+			want: []goem.ConfigError{
+				{At: "config.toml:3:1", Key: "server.port", Msg: "invalid port: 70000"},
+			},
+		},
+		"(invalid)port by environment": {
+			config: "root_dir = \"{{dir}}/mail\"\n[server]\nport = 8080\n",
+			env:    map[string]string{"GOEM_SERVER_PORT": "http"},
+			want: []goem.ConfigError{
+				{At: "$GOEM_SERVER_PORT", Key: "server.port", Msg: "invalid port: http"},
+			},
+		},
+		"(invalid)root_dir by environment": {
+			config: `root_dir = "{{dir}}/mail"`,
+			env:    map[string]string{"GOEM_ROOT_DIR": "{{dir}}/nowhere"},
+			want: []goem.ConfigError{
+				{At: "$GOEM_ROOT_DIR", Key: "root_dir", Msg: "{{dir}}/nowhere doesn't exist"},
+			},
+		},
+		"(invalid)server": {
+			config: `root_dir = "{{dir}}/mail"
+[server]
+account = "nowhere"
+cert_file = "{{dir}}/nowhere.pem"
+`,
+			want: []goem.ConfigError{
+				{At: "config.toml:3:1", Key: "server.account", Msg: "account nowhere doesn't exist"},
+				{At: "config.toml:4:1", Key: "server.cert_file", Msg: "key_file is required with cert_file"},
+				{At: "config.toml:4:1", Key: "server.cert_file", Msg: "stat {{dir}}/nowhere.pem: no such file or directory"},
+			},
+		},
+		"(invalid)key_file by environment": {
+			config: `root_dir = "{{dir}}/mail"`,
+			env:    map[string]string{"GOEM_SERVER_KEY_FILE": "{{dir}}/file"},
+			want: []goem.ConfigError{
+				{At: "$GOEM_SERVER_KEY_FILE", Key: "server.key_file", Msg: "cert_file is required with key_file"},
+			},
+		},
+		"(invalid)filter and remote": {
+			config: `root_dir = "{{dir}}/mail"
+[filter]
+interval = -1
+[remote]
+url = "ftp://mail.example.com"
+`,
+			want: []goem.ConfigError{
+				{At: "config.toml:3:1", Key: "filter.interval", Msg: "invalid interval: -1"},
+				{At: "config.toml:5:1", Key: "remote.url", Msg: "invalid URL: ftp://mail.example.com"},
+			},
+		},
+		"(invalid)accounts": {
+			config: `[[accounts]]
+root_dir = "{{dir}}/mail"
+
+[[accounts]]
+name = "home"
+root_dir = "{{dir}}/file"
+
+[[accounts]]
+name = "home"
+[accounts.imap]
+port = 993
+`,
+			want: []goem.ConfigError{
+				{At: "config.toml:1:1", Key: "accounts[0].name", Msg: "name is required"},
+				{At: "config.toml:6:1", Key: "accounts[1].root_dir", Msg: "{{dir}}/file is not a directory"},
+				{At: "config.toml:9:1", Key: "accounts[2].name", Msg: "account home is duplicated"},
+				{At: "config.toml:8:1", Key: "accounts[2].root_dir", Msg: "root_dir is required"},
+				{At: "config.toml:10:1", Key: "accounts[2].imap.host", Msg: "host is required"},
+			},
+		},
+		"(invalid)imap and pop3": {
+			config: `root_dir = "{{dir}}/mail"
+[[imap]]
+host = "imap.example.com"
+tls = "ssl"
+
+[[pop3]]
+name = "isp"
+host = "pop.example.com"
+port = 99999
+`,
+			want: []goem.ConfigError{
+				{At: "config.toml:2:1", Key: "imap[0].name", Msg: "name is required"},
+				{At: "config.toml:4:1", Key: "imap[0].tls", Msg: `tls must be "tls", "starttls" or "none": ssl`},
+				{At: "config.toml:9:1", Key: "pop3[0].port", Msg: "invalid port: 99999"},
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			path, dir, cleanup := writeConfig(t, tt.config, files)
+			defer cleanup()
+			env := map[string]string{}
+			for k, v := range tt.env {
+				env[k] = strings.Replace(v, "{{dir}}", dir, -1)
+			}
+			defer setenv(t, env)()
+
+			_, err := goem.LoadConfig(path)
+			errs, ok := err.(goem.ConfigErrors)
+			if !ok {
+				t.Fatalf("should be ConfigErrors for %v but %v", tt.config, err)
+			}
+			var got []goem.ConfigError
+			for _, e := range errs {
+				got = append(got, *e)
+			}
+			var want []goem.ConfigError
+			for _, e := range tt.want {
+				if strings.HasPrefix(e.At, "config.toml") {
+					e.At = filepath.Join(dir, e.At)
+				}
+				e.Msg = strings.Replace(e.Msg, "{{dir}}", dir, -1)
+				want = append(want, e)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("\n\tgot: %v\n\twant: %v", errs, want)
+			}
+		})
+	}
+}